@@ -0,0 +1,169 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Entry records one write made through the API
+type Entry struct {
+	ID           int64            `json:"id"`
+	OccurredAt   time.Time        `json:"occurred_at"`
+	Principal    string           `json:"principal"`
+	AuthMethod   string           `json:"auth_method,omitempty"`
+	IP           string           `json:"ip"`
+	Method       string           `json:"method"`
+	Route        string           `json:"route"`
+	Path         string           `json:"path"`
+	CollectionID string           `json:"collection_id,omitempty"`
+	ItemIDs      []string         `json:"item_ids,omitempty"`
+	BeforeHash   string           `json:"before_hash,omitempty"`
+	AfterHash    string           `json:"after_hash,omitempty"`
+	Diff         *json.RawMessage `json:"diff,omitempty"`
+	Status       int              `json:"status"`
+}
+
+// Filter selects audit entries; zero values match everything
+type Filter struct {
+	CollectionID string
+	ItemID       string
+	Principal    string
+	Start        time.Time
+	End          time.Time
+	Limit        int
+}
+
+var fileMu sync.Mutex
+
+// Enabled reports whether writes are audited to the database or a file
+func Enabled() bool {
+	return viper.GetBool("audit.database") || viper.GetString("audit.file") != ""
+}
+
+// Record stores an entry in the configured sinks. Failures are logged rather
+// than returned so an unavailable audit sink doesn't undo a completed write.
+func Record(ctx context.Context, entry Entry) {
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = time.Now().UTC()
+	}
+
+	if viper.GetBool("audit.database") {
+		if err := insertEntry(ctx, &entry); err != nil {
+			log.Error().Err(err).Str("path", entry.Path).Msg("failed to write audit entry to database")
+		}
+	}
+
+	if path := viper.GetString("audit.file"); path != "" {
+		if err := appendEntry(path, entry); err != nil {
+			log.Error().Err(err).Str("file", path).Str("path", entry.Path).Msg("failed to write audit entry to file")
+		}
+	}
+}
+
+func insertEntry(ctx context.Context, entry *Entry) error {
+	pool := database.GetInstance(ctx)
+	return pool.QueryRow(ctx, `INSERT INTO stac_server.audit_log
+		(occurred_at, principal, auth_method, ip, method, route, path, collection_id, item_ids, before_hash, after_hash, diff, status)
+		VALUES ($1, $2, nullif($3, ''), $4, $5, $6, $7, nullif($8, ''), $9, nullif($10, ''), nullif($11, ''), $12::text::jsonb, $13)
+		RETURNING id`,
+		entry.OccurredAt, entry.Principal, entry.AuthMethod, entry.IP, entry.Method, entry.Route, entry.Path,
+		entry.CollectionID, entry.ItemIDs, entry.BeforeHash, entry.AfterHash, rawString(entry.Diff), entry.Status,
+	).Scan(&entry.ID)
+}
+
+// appendEntry writes the entry as one line of a JSON lines file
+func appendEntry(path string, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	fileMu.Lock()
+	defer fileMu.Unlock()
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Query returns the newest audit entries in the database matching the filter
+func Query(ctx context.Context, filter Filter) ([]Entry, error) {
+	var start, end *time.Time
+	if !filter.Start.IsZero() {
+		start = &filter.Start
+	}
+	if !filter.End.IsZero() {
+		end = &filter.End
+	}
+
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, `SELECT id, occurred_at, principal, coalesce(auth_method, ''), coalesce(ip, ''),
+			method, route, path, coalesce(collection_id, ''), coalesce(item_ids, '{}'),
+			coalesce(before_hash, ''), coalesce(after_hash, ''), diff::text, status
+		FROM stac_server.audit_log
+		WHERE ($1 = '' OR collection_id = $1)
+			AND ($2 = '' OR $2 = ANY(item_ids))
+			AND ($3 = '' OR principal = $3)
+			AND ($4::timestamptz IS NULL OR occurred_at >= $4)
+			AND ($5::timestamptz IS NULL OR occurred_at < $5)
+		ORDER BY id DESC LIMIT $6`,
+		filter.CollectionID, filter.ItemID, filter.Principal, start, end, filter.Limit)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query audit log")
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var entry Entry
+		var diff *string
+		if err := rows.Scan(&entry.ID, &entry.OccurredAt, &entry.Principal, &entry.AuthMethod, &entry.IP,
+			&entry.Method, &entry.Route, &entry.Path, &entry.CollectionID, &entry.ItemIDs,
+			&entry.BeforeHash, &entry.AfterHash, &diff, &entry.Status); err != nil {
+			return nil, err
+		}
+		if diff != nil {
+			raw := json.RawMessage(*diff)
+			entry.Diff = &raw
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func rawString(raw *json.RawMessage) *string {
+	if raw == nil {
+		return nil
+	}
+	s := string(*raw)
+	return &s
+}
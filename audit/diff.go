@@ -0,0 +1,129 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"strings"
+
+	json "github.com/goccy/go-json"
+)
+
+// patchOperation is a JSON Patch (RFC 6902) operation
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	Value *json.RawMessage `json:"value,omitempty"`
+}
+
+// newOperation builds an operation whose value, including null, is kept
+func newOperation(op string, path string, value any) patchOperation {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		raw = []byte("null")
+	}
+	message := json.RawMessage(raw)
+	return patchOperation{Op: op, Path: path, Value: &message}
+}
+
+// Hash returns the SHA-256 of a document, or "" when there is none
+func Hash(document []byte) string {
+	if document == nil {
+		return ""
+	}
+	sum := sha256.Sum256(document)
+	return hex.EncodeToString(sum[:])
+}
+
+// Diff returns the JSON Patch turning before into after. A nil document is
+// treated as absent, so creations and deletions replace the whole document.
+func Diff(before []byte, after []byte) (*json.RawMessage, error) {
+	var from, to any
+	if before != nil {
+		if err := json.Unmarshal(before, &from); err != nil {
+			return nil, err
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &to); err != nil {
+			return nil, err
+		}
+	}
+
+	operations := make([]patchOperation, 0)
+	switch {
+	case before == nil && after == nil:
+	case before == nil:
+		operations = append(operations, newOperation("add", "", to))
+	case after == nil:
+		operations = append(operations, patchOperation{Op: "remove", Path: ""})
+	default:
+		operations = diffValues(operations, "", from, to)
+	}
+
+	raw, err := json.Marshal(operations)
+	if err != nil {
+		return nil, err
+	}
+	patch := json.RawMessage(raw)
+	return &patch, nil
+}
+
+func diffValues(operations []patchOperation, path string, from any, to any) []patchOperation {
+	fromObject, fromIsObject := from.(map[string]any)
+	toObject, toIsObject := to.(map[string]any)
+	if !fromIsObject || !toIsObject {
+		// arrays and scalars are replaced whole
+		if !reflect.DeepEqual(from, to) {
+			operations = append(operations, newOperation("replace", path, to))
+		}
+		return operations
+	}
+
+	keys := make([]string, 0, len(fromObject)+len(toObject))
+	for key := range fromObject {
+		keys = append(keys, key)
+	}
+	for key := range toObject {
+		if _, ok := fromObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := path + "/" + escapePointer(key)
+		fromValue, inFrom := fromObject[key]
+		toValue, inTo := toObject[key]
+		switch {
+		case !inTo:
+			operations = append(operations, patchOperation{Op: "remove", Path: child})
+		case !inFrom:
+			operations = append(operations, newOperation("add", child, toValue))
+		default:
+			operations = diffValues(operations, child, fromValue, toValue)
+		}
+	}
+	return operations
+}
+
+// escapePointer escapes a key for use in a JSON pointer
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,169 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/ingest"
+	json "github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// target is what a write request changes. Documents are only snapshotted
+// for writes to a single collection or item.
+type target struct {
+	collectionID string
+	itemIDs      []string
+	single       bool
+}
+
+// Middleware records every call of the route it is installed on in the
+// audit log, along with hashes of the collection or item before and after
+// the write and, with audit.diffs, a JSON Patch between them
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !Enabled() {
+			return c.Next()
+		}
+
+		ctx := context.Background()
+		principal := auth.FromContext(c)
+		entry := Entry{
+			Principal:  utils.CopyString(principal.Name),
+			AuthMethod: principal.Method,
+			IP:         auth.ClientIP(c),
+			Method:     utils.CopyString(c.Method()),
+			Route:      utils.CopyString(c.Route().Path),
+			Path:       utils.CopyString(c.Path()),
+		}
+
+		t := requestTarget(c)
+		var before []byte
+		if t.single {
+			before = snapshot(ctx, t)
+		}
+
+		err := c.Next()
+
+		entry.Status = c.Response().StatusCode()
+		if err != nil {
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				entry.Status = fiberErr.Code
+			} else {
+				entry.Status = fiber.StatusInternalServerError
+			}
+		}
+		entry.CollectionID = t.collectionID
+		entry.ItemIDs = t.itemIDs
+
+		if t.single {
+			after := before
+			if entry.Status < fiber.StatusBadRequest {
+				after = snapshot(ctx, t)
+			}
+			entry.BeforeHash = Hash(before)
+			entry.AfterHash = Hash(after)
+			if viper.GetBool("audit.diffs") && entry.BeforeHash != entry.AfterHash {
+				diff, diffErr := Diff(before, after)
+				if diffErr != nil {
+					log.Ctx(ctx).Warn().Err(diffErr).Str("path", entry.Path).Msg("could not diff audited documents")
+				}
+				entry.Diff = diff
+			}
+		}
+
+		Record(ctx, entry)
+		return err
+	}
+}
+
+// requestTarget works out which collection and items a write request
+// changes from the route parameters or, for creations, the JSON body.
+// Newline delimited uploads are streamed so their item ids aren't known.
+func requestTarget(c *fiber.Ctx) target {
+	t := target{
+		collectionID: utils.CopyString(c.Params("collectionId")),
+	}
+	if itemID := c.Params("itemId"); itemID != "" {
+		t.itemIDs = []string{utils.CopyString(itemID)}
+		t.single = true
+		return t
+	}
+
+	if ingest.IsStreamMediaType(c.Get(fiber.HeaderContentType)) {
+		return t
+	}
+
+	if c.Method() == fiber.MethodDelete {
+		t.single = t.collectionID != ""
+		return t
+	}
+
+	var body struct {
+		Type     string `json:"type"`
+		ID       string `json:"id"`
+		Features []struct {
+			ID string `json:"id"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return t
+	}
+
+	switch {
+	case t.collectionID == "":
+		// POST or PUT /collections
+		t.collectionID = body.ID
+		t.single = body.ID != ""
+	case body.Type == "Feature" && body.ID != "":
+		t.itemIDs = []string{body.ID}
+		t.single = true
+	case body.Type == "FeatureCollection":
+		for _, feature := range body.Features {
+			t.itemIDs = append(t.itemIDs, feature.ID)
+		}
+	}
+	return t
+}
+
+// snapshot returns the stored collection or item, or nil if it doesn't exist
+func snapshot(ctx context.Context, t target) []byte {
+	pool := database.GetInstance(ctx)
+
+	var document *string
+	var err error
+	if len(t.itemIDs) == 1 {
+		err = pool.QueryRow(ctx, "SELECT pgstac.get_item($1, $2)::text", t.itemIDs[0], t.collectionID).Scan(&document)
+	} else {
+		err = pool.QueryRow(ctx, "SELECT pgstac.get_collection($1)::text", t.collectionID).Scan(&document)
+	}
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("collectionId", t.collectionID).Msg("could not snapshot audited document")
+		return nil
+	}
+	if document == nil {
+		return nil
+	}
+	return []byte(*document)
+}
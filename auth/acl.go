@@ -0,0 +1,145 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"sync"
+
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// NoCollections can never be a collection id, so searching it matches nothing.
+// It is used when every collection a search asked for is hidden.
+const NoCollections = "~"
+
+// collectionACL restricts a collection to the listed principals and roles
+type collectionACL struct {
+	ID         string   `mapstructure:"id"`
+	Principals []string `mapstructure:"principals"`
+	Roles      []string `mapstructure:"roles"`
+}
+
+var (
+	aclOnce sync.Once
+	acls    map[string]collectionACL
+)
+
+// collectionACLs returns the restricted collections from acl.collections in
+// the configuration file keyed by collection id
+func collectionACLs() map[string]collectionACL {
+	aclOnce.Do(func() {
+		var configured []collectionACL
+		if err := viper.UnmarshalKey("acl.collections", &configured); err != nil {
+			log.Error().Err(err).Msg("could not parse acl.collections; no collections are restricted")
+		}
+		acls = make(map[string]collectionACL, len(configured))
+		for _, acl := range configured {
+			acls[acl.ID] = acl
+		}
+	})
+	return acls
+}
+
+// CanAccessCollection reports whether the principal may see a collection.
+// Unrestricted collections are visible to everyone and admins see everything.
+func CanAccessCollection(p *Principal, collectionID string) bool {
+	acl, restricted := collectionACLs()[collectionID]
+	if !restricted || p.HasRole(RoleAdmin) {
+		return true
+	}
+	if p.Anonymous() {
+		return false
+	}
+
+	for _, principal := range acl.Principals {
+		if principal == p.Name {
+			return true
+		}
+	}
+	for _, role := range acl.Roles {
+		if p.HasRole(role) || containsString(p.Groups, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HiddenCollections returns the restricted collections the principal may not see
+func HiddenCollections(p *Principal) []string {
+	hidden := make([]string, 0)
+	for id := range collectionACLs() {
+		if !CanAccessCollection(p, id) {
+			hidden = append(hidden, id)
+		}
+	}
+	return hidden
+}
+
+// RestrictCollections limits a search to the collections the principal may
+// see. An unrestricted search is limited to the visible collections in
+// allCollections.
+func RestrictCollections(p *Principal, cql *stac.CQL, allCollections func() ([]string, error)) error {
+	if len(HiddenCollections(p)) == 0 {
+		return nil
+	}
+
+	requested := cql.Collections
+	if len(requested) == 0 {
+		var err error
+		if requested, err = allCollections(); err != nil {
+			return err
+		}
+	}
+
+	visible := make([]string, 0, len(requested))
+	for _, id := range requested {
+		if CanAccessCollection(p, id) {
+			visible = append(visible, id)
+		}
+	}
+	if len(visible) == 0 {
+		visible = append(visible, NoCollections)
+	}
+	cql.Collections = visible
+	return nil
+}
+
+// RequireCollectionAccess answers 404 for collections the principal may not
+// see so hidden collections can't be discovered by probing
+func RequireCollectionAccess() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if CanAccessCollection(FromContext(c), c.Params("collectionId")) {
+			return c.Next()
+		}
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: "collection not found",
+		})
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid API key")
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// apiKeyPrefix makes keys recognizable, e.g. by secret scanners
+const apiKeyPrefix = "gss_"
+
+// APIKey describes a stored API key; the key itself is never stored
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Roles      []string   `json:"roles"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// configAPIKey is an entry of auth.apiKeys in the configuration file
+type configAPIKey struct {
+	Name  string   `mapstructure:"name"`
+	Hash  string   `mapstructure:"hash"`
+	Roles []string `mapstructure:"roles"`
+}
+
+// HashAPIKey returns the hex encoded SHA-256 hash stored for a key. Keys are
+// random so a fast hash is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey returns a new random key
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CreateAPIKey generates a key, stores its hash and returns the key; it
+// cannot be retrieved again
+func CreateAPIKey(ctx context.Context, name string, roles []string) (string, *APIKey, error) {
+	if err := ValidateRoles(roles); err != nil {
+		return "", nil, err
+	}
+
+	key, err := GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiKey := APIKey{
+		ID:    uuid.NewString(),
+		Name:  name,
+		Roles: roles,
+	}
+	pool := database.GetInstance(ctx)
+	if err := pool.QueryRow(ctx, `INSERT INTO stac_server.api_keys (id, name, key_hash, roles)
+		VALUES ($1, $2, $3, $4) RETURNING created_at`, apiKey.ID, name, HashAPIKey(key), roles).Scan(&apiKey.CreatedAt); err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed to store API key")
+		return "", nil, err
+	}
+	return key, &apiKey, nil
+}
+
+// RevokeAPIKey revokes a stored key by id
+func RevokeAPIKey(ctx context.Context, id string) error {
+	pool := database.GetInstance(ctx)
+	tag, err := pool.Exec(ctx, "UPDATE stac_server.api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to revoke API key")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// ListAPIKeys returns the stored keys, including revoked ones
+func ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, `SELECT id, name, roles, created_at, revoked_at, last_used_at
+		FROM stac_server.api_keys ORDER BY created_at`)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list API keys")
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Roles, &key.CreatedAt, &key.RevokedAt, &key.LastUsedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// lookupAPIKey returns the principal for a key from the configuration file
+// or the database
+func lookupAPIKey(ctx context.Context, key string) (*Principal, error) {
+	hash := HashAPIKey(key)
+
+	var configured []configAPIKey
+	if err := viper.UnmarshalKey("auth.apiKeys", &configured); err != nil {
+		return nil, err
+	}
+	for _, k := range configured {
+		if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+			return &Principal{Name: k.Name, Roles: k.Roles, Method: "apikey"}, nil
+		}
+	}
+
+	principal := Principal{Method: "apikey"}
+	pool := database.GetInstance(ctx)
+	err := pool.QueryRow(ctx, `SELECT name, roles FROM stac_server.api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`, hash).Scan(&principal.Name, &principal.Roles)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed to look up API key")
+		return nil, err
+	}
+
+	touchAPIKey(hash)
+	return &principal, nil
+}
+
+// lastUsedInterval is how often the last use of a key is recorded
+var lastUsedInterval = time.Minute
+
+// lastUsed holds when the last use of each key hash was recorded
+var lastUsed sync.Map
+
+// touchAPIKey records the use of a key at most once per lastUsedInterval.
+// The update runs in the background and a failure is only logged so it never
+// fails the request; read-only servers, whose role may not be allowed to
+// write, don't record it at all.
+func touchAPIKey(hash string) {
+	if common.ReadOnly() {
+		return
+	}
+	now := time.Now()
+	if previous, ok := lastUsed.Load(hash); ok && now.Sub(previous.(time.Time)) < lastUsedInterval {
+		return
+	}
+	lastUsed.Store(hash, now)
+
+	go func() {
+		ctx := context.Background()
+		pool := database.GetInstance(ctx)
+		if _, err := pool.Exec(ctx, "UPDATE stac_server.api_keys SET last_used_at = now() WHERE key_hash = $1", hash); err != nil {
+			log.Warn().Err(err).Msg("could not record API key use")
+		}
+	}()
+}
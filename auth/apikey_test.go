@@ -0,0 +1,116 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+func TestLookupConfiguredAPIKey(t *testing.T) {
+	viper.Set("auth.apiKeys", []map[string]any{
+		{"name": "ci", "hash": HashAPIKey("gss_ci"), "roles": []string{RoleWriter}},
+		{"name": "ops", "hash": HashAPIKey("gss_ops"), "roles": []string{RoleReader, RoleAdmin}},
+	})
+	defer viper.Set("auth.apiKeys", nil)
+
+	tests := []struct {
+		key   string
+		name  string
+		roles []string
+	}{
+		{"gss_ci", "ci", []string{RoleWriter}},
+		{"gss_ops", "ops", []string{RoleReader, RoleAdmin}},
+	}
+	for _, tt := range tests {
+		principal, err := lookupAPIKey(context.Background(), tt.key)
+		if err != nil {
+			t.Fatalf("lookupAPIKey(%q) returned %v", tt.key, err)
+		}
+		if principal.Name != tt.name || !reflect.DeepEqual(principal.Roles, tt.roles) || principal.Method != "apikey" {
+			t.Errorf("lookupAPIKey(%q) = %+v, want name %q, roles %v and method apikey", tt.key, principal, tt.name, tt.roles)
+		}
+	}
+}
+
+func TestAPIKeyFromRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"none", nil, ""},
+		{"header", map[string]string{"X-API-Key": "gss_a"}, "gss_a"},
+		{"authorization", map[string]string{"Authorization": "ApiKey gss_b"}, "gss_b"},
+		{"scheme is case insensitive", map[string]string{"Authorization": "apikey  gss_c"}, "gss_c"},
+		{"header wins", map[string]string{"X-API-Key": "gss_a", "Authorization": "ApiKey gss_b"}, "gss_a"},
+		{"bearer token", map[string]string{"Authorization": "Bearer token"}, ""},
+	}
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(apiKeyFromRequest(c))
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("apiKeyFromRequest = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		roles []string
+		role  string
+		want  bool
+	}{
+		{nil, RoleReader, false},
+		{[]string{RoleReader}, RoleReader, true},
+		{[]string{RoleReader}, RoleWriter, false},
+		{[]string{RoleWriter}, RoleReader, true},
+		{[]string{RoleWriter}, RoleAdmin, false},
+		{[]string{RoleAdmin}, RoleWriter, true},
+		{[]string{"owner"}, RoleReader, false},
+		{[]string{RoleAdmin}, "owner", false},
+	}
+	for _, tt := range tests {
+		p := Principal{Roles: tt.roles}
+		if got := p.HasRole(tt.role); got != tt.want {
+			t.Errorf("Principal{Roles: %v}.HasRole(%q) = %v, want %v", tt.roles, tt.role, got, tt.want)
+		}
+	}
+}
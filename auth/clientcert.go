@@ -0,0 +1,70 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// configClientCert maps a client certificate subject to a principal, e.g.
+//
+//	[[auth.clientCerts]]
+//	subject="ingest-pipeline"
+//	roles=["writer"]
+type configClientCert struct {
+	Subject string   `mapstructure:"subject"`
+	Name    string   `mapstructure:"name"`
+	Roles   []string `mapstructure:"roles"`
+}
+
+// clientCertPrincipal returns the principal of a client certificate that was
+// verified against server.tls.clientCa during the handshake, or nil. The
+// subject is its common name, or its full distinguished name when
+// auth.mtls.subject is dn.
+func clientCertPrincipal(c *fiber.Ctx) *Principal {
+	state := c.Context().TLSConnectionState()
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := state.VerifiedChains[0][0]
+	subject := cert.Subject.CommonName
+	if viper.GetString("auth.mtls.subject") == "dn" {
+		subject = cert.Subject.String()
+	}
+	if subject == "" {
+		return nil
+	}
+
+	var mapped []configClientCert
+	if err := viper.UnmarshalKey("auth.clientCerts", &mapped); err != nil {
+		log.Error().Err(err).Msg("could not parse auth.clientCerts")
+	}
+	for _, m := range mapped {
+		if m.Subject != subject {
+			continue
+		}
+		name := m.Name
+		if name == "" {
+			name = subject
+		}
+		return &Principal{Name: name, Roles: m.Roles, Method: "mtls"}
+	}
+
+	return &Principal{Name: subject, Roles: viper.GetStringSlice("auth.mtls.defaultRoles"), Method: "mtls"}
+}
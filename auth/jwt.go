@@ -0,0 +1,178 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+)
+
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// jwtVerifier validates bearer tokens issued by the configured identity provider
+type jwtVerifier struct {
+	keys         *jwks
+	issuer       string
+	audience     string
+	subjectClaim string
+	rolesClaim   string
+	roleMapping  map[string]string
+	parser       *jwt.Parser
+}
+
+var (
+	verifierOnce sync.Once
+	verifier     *jwtVerifier
+)
+
+// JWTEnabled reports whether a JWKS source is configured
+func JWTEnabled() bool {
+	return viper.GetString("auth.jwt.jwksUrl") != "" || viper.GetString("auth.jwt.jwksFile") != ""
+}
+
+func getVerifier() *jwtVerifier {
+	verifierOnce.Do(func() {
+		if !JWTEnabled() {
+			return
+		}
+
+		options := []jwt.ParserOption{
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(viper.GetDuration("auth.jwt.leeway")),
+		}
+		if issuer := viper.GetString("auth.jwt.issuer"); issuer != "" {
+			options = append(options, jwt.WithIssuer(issuer))
+		}
+		if audience := viper.GetString("auth.jwt.audience"); audience != "" {
+			options = append(options, jwt.WithAudience(audience))
+		}
+
+		subjectClaim := viper.GetString("auth.jwt.subjectClaim")
+		if subjectClaim == "" {
+			subjectClaim = "sub"
+		}
+		rolesClaim := viper.GetString("auth.jwt.rolesClaim")
+		if rolesClaim == "" {
+			rolesClaim = "roles"
+		}
+		refresh := viper.GetDuration("auth.jwt.jwksRefresh")
+		if refresh <= 0 {
+			refresh = time.Hour
+		}
+
+		verifier = &jwtVerifier{
+			keys:         newJWKS(viper.GetString("auth.jwt.jwksUrl"), viper.GetString("auth.jwt.jwksFile"), refresh),
+			issuer:       viper.GetString("auth.jwt.issuer"),
+			audience:     viper.GetString("auth.jwt.audience"),
+			subjectClaim: subjectClaim,
+			rolesClaim:   rolesClaim,
+			roleMapping:  viper.GetStringMapString("auth.jwt.roleMapping"),
+			parser:       jwt.NewParser(options...),
+		}
+	})
+	return verifier
+}
+
+// LoadJWKS loads the identity provider keys ahead of the first request so
+// configuration problems show up on startup
+func LoadJWKS() error {
+	v := getVerifier()
+	if v == nil {
+		return nil
+	}
+	return v.keys.load()
+}
+
+// verifyJWT checks the token signature, issuer, audience and expiry and
+// returns the principal described by its claims
+func verifyJWT(token string) (*Principal, error) {
+	v := getVerifier()
+	if v == nil {
+		return nil, fmt.Errorf("%w: bearer tokens are not configured", ErrInvalidToken)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	subject, _ := claimValue(claims, v.subjectClaim).(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.subjectClaim)
+	}
+
+	groups := claimStrings(claimValue(claims, v.rolesClaim))
+	return &Principal{
+		Name:   subject,
+		Roles:  v.roles(groups),
+		Groups: groups,
+		Method: "jwt",
+	}, nil
+}
+
+// roles maps the values of the roles claim to server roles. Values that
+// are role names are used as is; others are looked up in auth.jwt.roleMapping.
+func (v *jwtVerifier) roles(values []string) []string {
+	roles := make([]string, 0, len(values))
+	for _, value := range values {
+		if roleLevel(value) >= 0 {
+			roles = append(roles, value)
+		} else if role, ok := v.roleMapping[strings.ToLower(value)]; ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// claimStrings returns the values of a space separated or array claim
+func claimStrings(claim any) []string {
+	var values []string
+	switch c := claim.(type) {
+	case string:
+		values = strings.Fields(c)
+	case []any:
+		for _, value := range c {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// claimValue returns a claim by dotted path, e.g. realm_access.roles
+func claimValue(claims jwt.MapClaims, path string) any {
+	var value any = map[string]any(claims)
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
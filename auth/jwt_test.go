@@ -0,0 +1,166 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useTestVerifier replaces the configured verifier with one trusting key
+func useTestVerifier(t *testing.T, key *ecdsa.PrivateKey) {
+	t.Helper()
+	verifierOnce.Do(func() {})
+	previous := verifier
+	verifier = &jwtVerifier{
+		keys: &jwks{
+			keys:     map[string]any{"test": &key.PublicKey},
+			fetched:  time.Now(),
+			interval: time.Hour,
+		},
+		subjectClaim: "sub",
+		rolesClaim:   "realm_access.roles",
+		roleMapping:  map[string]string{"stac-admins": RoleAdmin, "editors": RoleWriter},
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"ES256"}),
+			jwt.WithExpirationRequired(),
+		),
+	}
+	t.Cleanup(func() { verifier = previous })
+}
+
+func signToken(t *testing.T, key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerifyJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestVerifier(t, key)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name   string
+		key    *ecdsa.PrivateKey
+		claims jwt.MapClaims
+		roles  []string
+		groups []string
+		err    bool
+	}{
+		{
+			name:   "role names are used as is",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "alice", "exp": expires, "realm_access": map[string]any{"roles": []any{"writer"}}},
+			roles:  []string{RoleWriter},
+			groups: []string{"writer"},
+		},
+		{
+			name:   "mapped roles are case insensitive",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "bob", "exp": expires, "realm_access": map[string]any{"roles": []any{"STAC-Admins", "Editors"}}},
+			roles:  []string{RoleAdmin, RoleWriter},
+			groups: []string{"STAC-Admins", "Editors"},
+		},
+		{
+			name:   "space separated roles",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "carol", "exp": expires, "realm_access": map[string]any{"roles": "reader editors"}},
+			roles:  []string{RoleReader, RoleWriter},
+			groups: []string{"reader", "editors"},
+		},
+		{
+			name:   "unknown values are only groups",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "dave", "exp": expires, "realm_access": map[string]any{"roles": []any{"offline_access"}}},
+			roles:  []string{},
+			groups: []string{"offline_access"},
+		},
+		{
+			name:   "no roles claim",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "erin", "exp": expires},
+			roles:  []string{},
+		},
+		{
+			name:   "missing subject",
+			key:    key,
+			claims: jwt.MapClaims{"exp": expires},
+			err:    true,
+		},
+		{
+			name:   "expired",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()},
+			err:    true,
+		},
+		{
+			name:   "no expiry",
+			key:    key,
+			claims: jwt.MapClaims{"sub": "alice"},
+			err:    true,
+		},
+		{
+			name:   "signed by another key",
+			key:    otherKey,
+			claims: jwt.MapClaims{"sub": "alice", "exp": expires},
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := verifyJWT(signToken(t, tt.key, tt.claims))
+			if tt.err {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("verifyJWT returned %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyJWT returned %v", err)
+			}
+			if principal.Name != tt.claims["sub"] || principal.Method != "jwt" {
+				t.Errorf("principal = %+v, want name %v and method jwt", principal, tt.claims["sub"])
+			}
+			if !reflect.DeepEqual(principal.Roles, tt.roles) {
+				t.Errorf("roles = %v, want %v", principal.Roles, tt.roles)
+			}
+			if !reflect.DeepEqual(principal.Groups, tt.groups) {
+				t.Errorf("groups = %v, want %v", principal.Groups, tt.groups)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+var UnauthorizedError = "Unauthorized"
+var ForbiddenError = "Forbidden"
+
+// apiKeyFromRequest returns the key from the X-API-Key header or an
+// "Authorization: ApiKey <key>" header
+func apiKeyFromRequest(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if scheme, key, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " "); ok && strings.EqualFold(scheme, "ApiKey") {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// bearerTokenFromRequest returns the token of an "Authorization: Bearer <token>" header
+func bearerTokenFromRequest(c *fiber.Ctx) string {
+	if scheme, token, ok := strings.Cut(c.Get(fiber.HeaderAuthorization), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+func unauthorized(c *fiber.Ctx, description string) error {
+	challenge := `ApiKey realm="stac"`
+	if JWTEnabled() {
+		challenge = `Bearer realm="stac"`
+	}
+	c.Set(fiber.HeaderWWWAuthenticate, challenge)
+	c.Status(fiber.StatusUnauthorized)
+	return c.JSON(stac.Message{
+		Code:        UnauthorizedError,
+		Description: description,
+	})
+}
+
+// Authenticate identifies the caller from a bearer token, API key or verified
+// client certificate. Requests without credentials continue as anonymous;
+// invalid credentials are rejected.
+func Authenticate() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token := bearerTokenFromRequest(c); token != "" {
+			principal, err := verifyJWT(token)
+			if err != nil {
+				log.Ctx(c.UserContext()).Warn().Err(err).Str("IP", ClientIP(c)).Msg("request with invalid bearer token")
+				c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="stac", error="invalid_token"`)
+				c.Status(fiber.StatusUnauthorized)
+				return c.JSON(stac.Message{
+					Code:        UnauthorizedError,
+					Description: "invalid bearer token",
+				})
+			}
+			c.Locals(principalKey, principal)
+			return c.Next()
+		}
+
+		key := apiKeyFromRequest(c)
+		if key == "" {
+			// fall back to the identity of a verified client certificate
+			if principal := clientCertPrincipal(c); principal != nil {
+				c.Locals(principalKey, principal)
+			}
+			return c.Next()
+		}
+
+		principal, err := lookupAPIKey(context.Background(), key)
+		if errors.Is(err, ErrInvalidAPIKey) {
+			log.Ctx(c.UserContext()).Warn().Str("IP", ClientIP(c)).Msg("request with invalid API key")
+			return unauthorized(c, "invalid API key")
+		}
+		if err != nil {
+			c.Status(fiber.StatusInternalServerError)
+			return c.JSON(stac.Message{
+				Code:        stac.ServerError,
+				Description: "could not verify credentials",
+			})
+		}
+
+		c.Locals(principalKey, principal)
+		return c.Next()
+	}
+}
+
+// Require rejects requests whose principal lacks role. It has no effect
+// unless auth.enabled is set, and reader routes are only protected when
+// auth.protectReads is also set.
+func Require(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !viper.GetBool("auth.enabled") || (role == RoleReader && !viper.GetBool("auth.protectReads")) {
+			return c.Next()
+		}
+
+		principal := FromContext(c)
+		if principal.Anonymous() {
+			return unauthorized(c, "authentication required")
+		}
+		if !principal.HasRole(role) {
+			log.Ctx(c.UserContext()).Warn().Str("principal", principal.Name).Str("role", role).Str("Path", c.Path()).Msg("principal lacks required role")
+			c.Status(fiber.StatusForbidden)
+			return c.JSON(stac.Message{
+				Code:        ForbiddenError,
+				Description: "the " + role + " role is required",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Permits reports whether Require(role) would let the request through
+func Permits(c *fiber.Ctx, role string) bool {
+	if !viper.GetBool("auth.enabled") || (role == RoleReader && !viper.GetBool("auth.protectReads")) {
+		return true
+	}
+	return FromContext(c).HasRole(role)
+}
@@ -0,0 +1,96 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auth authenticates API requests and checks the roles of the
+// authenticated principal against the route being called.
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	RoleReader = "reader"
+	RoleWriter = "writer"
+	RoleAdmin  = "admin"
+)
+
+// Roles are ordered from least to most privileged; each role includes the ones before it
+var Roles = []string{RoleReader, RoleWriter, RoleAdmin}
+
+// principalKey is the fiber Locals key holding the request's principal
+const principalKey = "auth.principal"
+
+// Principal is the caller of a request
+type Principal struct {
+	// Name identifies the caller in logs and audit records
+	Name string
+	// Roles granted to the caller
+	Roles []string
+	// Groups the caller belongs to according to the identity provider, used by collection ACLs
+	Groups []string
+	// Method is how the caller authenticated, e.g. apikey; empty for anonymous callers
+	Method string
+}
+
+// Anonymous reports whether the request carried no credentials
+func (p *Principal) Anonymous() bool {
+	return p.Method == ""
+}
+
+// HasRole reports whether the principal has role or a more privileged one
+func (p *Principal) HasRole(role string) bool {
+	required := roleLevel(role)
+	if required < 0 {
+		return false
+	}
+	for _, r := range p.Roles {
+		if roleLevel(r) >= required {
+			return true
+		}
+	}
+	return false
+}
+
+func roleLevel(role string) int {
+	for i, r := range Roles {
+		if r == role {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateRoles checks every role is known
+func ValidateRoles(roles []string) error {
+	for _, role := range roles {
+		if roleLevel(role) < 0 {
+			return fmt.Errorf("unknown role '%s'; roles are: %s", role, strings.Join(Roles, ", "))
+		}
+	}
+	return nil
+}
+
+// FromContext returns the principal of the request; callers that didn't
+// authenticate are anonymous and identified by IP address
+func FromContext(c *fiber.Ctx) *Principal {
+	if principal, ok := c.Locals(principalKey).(*Principal); ok {
+		return principal
+	}
+	return &Principal{Name: ClientIP(c)}
+}
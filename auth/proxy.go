@@ -0,0 +1,103 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// proxyHeader carries the client address added by trusted proxies, e.g.
+// X-Forwarded-For; it is ignored when empty
+var proxyHeader string
+
+// trustedProxies are the networks whose proxyHeader is believed
+var trustedProxies []*net.IPNet
+
+// ConfigureProxies sets the header trusted proxies put the client address in
+// and the addresses or CIDR ranges of those proxies. The header is only read
+// from requests sent by a trusted proxy, so a header without proxies is an
+// error rather than a way for any caller to choose its own address.
+func ConfigureProxies(header string, proxies []string) error {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy '%s'", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy '%s': %w", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+	if header != "" && len(networks) == 0 {
+		return errors.New("a proxy header requires at least one trusted proxy")
+	}
+
+	proxyHeader = header
+	trustedProxies = networks
+	return nil
+}
+
+func trustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the caller. Behind trusted proxies it is
+// the right-most address in the proxy header that isn't a trusted proxy, since
+// addresses to the left of it were supplied by the caller and can be forged.
+func ClientIP(c *fiber.Ctx) string {
+	remote := c.Context().RemoteIP()
+	if proxyHeader == "" || !trustedProxy(remote) {
+		return remote.String()
+	}
+
+	addresses := strings.Split(c.Get(proxyHeader), ",")
+	client := remote
+	for idx := len(addresses) - 1; idx >= 0; idx-- {
+		ip := net.ParseIP(strings.TrimSpace(addresses[idx]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return client.String()
+}
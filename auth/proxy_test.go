@@ -0,0 +1,102 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestConfigureProxies(t *testing.T) {
+	defer func() {
+		_ = ConfigureProxies("", nil)
+	}()
+
+	tests := []struct {
+		header  string
+		proxies []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"X-Forwarded-For", []string{"10.0.0.0/8", "192.168.1.1", "fd00::/8", "::1"}, false},
+		{"X-Forwarded-For", nil, true},
+		{"X-Forwarded-For", []string{"10.0.0.0/33"}, true},
+		{"X-Forwarded-For", []string{"proxy.example.com"}, true},
+	}
+	for _, tt := range tests {
+		if err := ConfigureProxies(tt.header, tt.proxies); (err != nil) != tt.wantErr {
+			t.Errorf("ConfigureProxies(%q, %v) error = %v, wantErr %v", tt.header, tt.proxies, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer func() {
+		_ = ConfigureProxies("", nil)
+	}()
+
+	// requests made with app.Test come from 0.0.0.0
+	tests := []struct {
+		name      string
+		header    string
+		proxies   []string
+		forwarded string
+		want      string
+	}{
+		{"no proxy header configured", "", nil, "203.0.113.7", "0.0.0.0"},
+		{"untrusted sender", "X-Forwarded-For", []string{"10.0.0.1"}, "203.0.113.7", "0.0.0.0"},
+		{"trusted sender", "X-Forwarded-For", []string{"0.0.0.0"}, "203.0.113.7", "203.0.113.7"},
+		{"forged addresses are skipped", "X-Forwarded-For", []string{"0.0.0.0"}, "1.2.3.4, 203.0.113.7", "203.0.113.7"},
+		{"proxy chain", "X-Forwarded-For", []string{"0.0.0.0", "10.0.0.0/8"}, "1.2.3.4, 203.0.113.7, 10.1.2.3", "203.0.113.7"},
+		{"only proxies", "X-Forwarded-For", []string{"0.0.0.0", "10.0.0.0/8"}, "10.0.0.2, 10.0.0.3", "10.0.0.2"},
+		{"invalid entry stops the walk", "X-Forwarded-For", []string{"0.0.0.0"}, "203.0.113.7, nonsense", "0.0.0.0"},
+		{"missing header", "X-Forwarded-For", []string{"0.0.0.0"}, "", "0.0.0.0"},
+		{"ipv6 client", "X-Real-Ip", []string{"0.0.0.0/32"}, "2001:db8::1", "2001:db8::1"},
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(ClientIP(c))
+	})
+
+	for _, tt := range tests {
+		if err := ConfigureProxies(tt.header, tt.proxies); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/", nil)
+		if tt.forwarded != "" {
+			header := tt.header
+			if header == "" {
+				header = "X-Forwarded-For"
+			}
+			req.Header.Set(header, tt.forwarded)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(body); got != tt.want {
+			t.Errorf("%s: ClientIP() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
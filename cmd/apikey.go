@@ -0,0 +1,150 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// apikeyCmd manages API keys
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Create, revoke and list API keys",
+	Long: `Manage the API keys accepted in the X-API-Key header. Only a SHA-256 hash of each key is
+stored; the key is printed once when it is created.`,
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an API key",
+	Args:  cobra.ExactArgs(1),
+	Run:   runAPIKeyCreate,
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API key",
+	Args:  cobra.ExactArgs(1),
+	Run:   runAPIKeyRevoke,
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API keys",
+	Args:  cobra.NoArgs,
+	Run:   runAPIKeyList,
+}
+
+func init() {
+	rootCmd.AddCommand(apikeyCmd)
+	apikeyCmd.AddCommand(apikeyCreateCmd, apikeyRevokeCmd, apikeyListCmd)
+
+	apikeyCreateCmd.Flags().StringSlice("role", []string{auth.RoleReader}, "Role(s) granted to the key: "+strings.Join(auth.Roles, ", "))
+	apikeyCreateCmd.Flags().Bool("config", false, "Print a configuration file entry instead of storing the key in the database")
+}
+
+func runAPIKeyCreate(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	roles, _ := cmd.Flags().GetStringSlice("role")
+	configOnly, _ := cmd.Flags().GetBool("config")
+
+	if err := auth.ValidateRoles(roles); err != nil {
+		log.Error().Err(err).Msg("invalid role")
+		os.Exit(1)
+	}
+
+	if configOnly {
+		key, err := auth.GenerateAPIKey()
+		if err != nil {
+			log.Error().Err(err).Msg("could not generate API key")
+			os.Exit(1)
+		}
+		fmt.Printf("key: %s\n\n", key)
+		fmt.Printf("[[auth.apiKeys]]\nname=%q\nhash=%q\nroles=[\"%s\"]\n", args[0], auth.HashAPIKey(key), strings.Join(roles, `", "`))
+		return
+	}
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	if err := database.EnsureSchema(ctx); err != nil {
+		log.Error().Err(err).Msg("could not create server tables")
+		os.Exit(1)
+	}
+
+	key, apiKey, err := auth.CreateAPIKey(ctx, args[0], roles)
+	if err != nil {
+		log.Error().Err(err).Msg("could not create API key")
+		os.Exit(1)
+	}
+	fmt.Printf("id:    %s\nroles: %s\nkey:   %s\n", apiKey.ID, strings.Join(apiKey.Roles, ","), key)
+	fmt.Println("store the key now, it cannot be shown again")
+}
+
+func runAPIKeyRevoke(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	if err := auth.RevokeAPIKey(ctx, args[0]); err != nil {
+		log.Error().Err(err).Str("id", args[0]).Msg("could not revoke API key")
+		os.Exit(1)
+	}
+	fmt.Printf("revoked API key %s\n", args[0])
+}
+
+func runAPIKeyList(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	keys, err := auth.ListAPIKeys(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not list API keys")
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tROLES\tCREATED\tLAST USED\tREVOKED")
+	for _, key := range keys {
+		lastUsed, revoked := "-", "-"
+		if key.LastUsedAt != nil {
+			lastUsed = key.LastUsedAt.Format("2006-01-02 15:04:05Z07:00")
+		}
+		if key.RevokedAt != nil {
+			revoked = key.RevokedAt.Format("2006-01-02 15:04:05Z07:00")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, strings.Join(key.Roles, ","),
+			key.CreatedAt.Format("2006-01-02 15:04:05Z07:00"), lastUsed, revoked)
+	}
+	w.Flush()
+}
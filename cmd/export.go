@@ -0,0 +1,114 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/export"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exportCmd writes collections or search results to a static STAC catalog
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write a collection or search results to a static STAC catalog",
+	Long: `Write the items of one or more collections, or the results of a search, to a
+self-contained static STAC catalog, a gzipped tar archive of the catalog, or newline
+delimited JSON files. --search accepts a POST /search body as JSON or @file.`,
+	Args: cobra.NoArgs,
+	Run:  runExport,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringSlice("collection", nil, "Collection(s) to export")
+	exportCmd.Flags().String("search", "", "Search body (JSON or @file) selecting the items to export")
+	exportCmd.Flags().StringP("output", "o", "", "Directory to write the export to")
+	exportCmd.Flags().String("format", string(export.FormatCatalog), "Output format one of: catalog, tar.gz, ndjson")
+	exportCmd.Flags().Int("page-size", 1000, "Number of items fetched per search request")
+	exportCmd.Flags().Int("max-items", 0, "Maximum number of items to export, 0 for no limit")
+	_ = exportCmd.MarkFlagRequired("output")
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	collections, _ := cmd.Flags().GetStringSlice("collection")
+	search, _ := cmd.Flags().GetString("search")
+	output, _ := cmd.Flags().GetString("output")
+	format, _ := cmd.Flags().GetString("format")
+	pageSize, _ := cmd.Flags().GetInt("page-size")
+	maxItems, _ := cmd.Flags().GetInt("max-items")
+
+	if len(collections) == 0 && search == "" {
+		fmt.Fprintln(os.Stderr, "one of --collection or --search is required")
+		os.Exit(1)
+	}
+
+	var cql stac.CQL
+	if search != "" {
+		body, err := readJSONArg(search)
+		if err != nil {
+			log.Error().Err(err).Str("search", search).Msg("could not read search file")
+			os.Exit(1)
+		}
+		if err := json.Unmarshal(body, &cql); err != nil {
+			log.Error().Err(err).Msg("could not parse search body")
+			os.Exit(1)
+		}
+	}
+	if len(collections) > 0 {
+		cql.Collections = collections
+	}
+	if cql.FilterLang == "" {
+		cql.FilterLang = "cql-json"
+		if cql.Filter != nil {
+			cql.FilterLang = "cql2-json"
+		}
+	}
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	exporter := &export.Exporter{
+		Output:             output,
+		Format:             export.Format(format),
+		PageSize:           pageSize,
+		MaxItems:           maxItems,
+		CatalogID:          viper.GetString("stac.catalog.id"),
+		CatalogTitle:       viper.GetString("stac.catalog.title"),
+		CatalogDescription: viper.GetString("stac.catalog.description"),
+	}
+
+	report, err := exporter.Export(ctx, cql)
+	if err != nil {
+		log.Error().Err(err).Msg("export failed")
+		os.Exit(1)
+	}
+
+	fmt.Printf("exported %d collections and %d items to %s\n", report.Collections, report.Items, report.Path)
+}
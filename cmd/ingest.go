@@ -0,0 +1,130 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/ingest"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// ingestCmd loads a static STAC catalog or newline delimited items from disk
+var ingestCmd = &cobra.Command{
+	Use:   "ingest <path>",
+	Short: "Load a static STAC catalog or NDJSON items into the database",
+	Long: `Load a static STAC catalog from disk. <path> may be a directory containing a catalog.json
+or collection.json, a catalog, collection, item or item collection JSON file, or a newline
+delimited JSON file of items (.ndjson, .jsonl, .geojsonl or .geojsons) which requires --collection.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runIngest,
+}
+
+func init() {
+	rootCmd.AddCommand(ingestCmd)
+
+	ingestCmd.Flags().Bool("upsert", false, "Replace collections and items that already exist")
+	ingestCmd.Flags().Bool("dry-run", false, "Validate collections and items without writing to the database")
+	ingestCmd.Flags().IntP("parallel", "j", 4, "Number of item batches to insert in parallel")
+	ingestCmd.Flags().Int("batch-size", 500, "Number of items inserted per batch")
+	ingestCmd.Flags().String("collection", "", "Collection to load newline delimited items into")
+}
+
+func runIngest(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	path := args[0]
+
+	common.SetupLogging()
+
+	upsert, _ := cmd.Flags().GetBool("upsert")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	collectionID, _ := cmd.Flags().GetString("collection")
+
+	loader := &ingest.Loader{
+		Mode:      ingest.ModeCreate,
+		BatchSize: batchSize,
+		Parallel:  parallel,
+	}
+	switch {
+	case dryRun:
+		loader.Mode = ingest.ModeValidate
+	case upsert:
+		loader.Mode = ingest.ModeUpsert
+	}
+
+	if !dryRun {
+		// fail fast if we cannot connect to the database
+		pool := database.GetInstance(ctx)
+		defer pool.Close()
+	}
+
+	var report *ingest.Report
+	var err error
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".ndjson" || ext == ".jsonl" || ext == ".geojsonl" || ext == ".geojsons" || ingest.IsNDJSON(path):
+		if collectionID == "" {
+			fmt.Fprintln(os.Stderr, "--collection is required when loading newline delimited items")
+			os.Exit(1)
+		}
+		report, err = loader.LoadNDJSONFile(ctx, path, collectionID)
+	default:
+		report, err = loader.LoadCatalog(ctx, path)
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("ingest failed")
+	}
+
+	printIngestReport(report, dryRun)
+
+	if err != nil || len(report.CollectionErrs) > 0 || report.FailedItems > 0 {
+		os.Exit(1)
+	}
+}
+
+func printIngestReport(report *ingest.Report, dryRun bool) {
+	verb := "loaded"
+	if dryRun {
+		verb = "validated"
+	}
+
+	fmt.Printf("%s %d collections and %d items; %d items failed\n", verb, report.Collections, report.Items, report.FailedItems)
+	for _, failure := range report.CollectionErrs {
+		fmt.Printf("collection %s: %s\n", failure.Source, failure.Reason)
+	}
+	for _, failure := range report.ItemErrs {
+		source := failure.Source
+		if failure.ID != "" {
+			source = fmt.Sprintf("%s (%s)", source, failure.ID)
+		}
+		// feature collections and NDJSON files number their items from 1;
+		// files holding a single item have no index
+		if failure.Index > 0 {
+			source = fmt.Sprintf("%s #%d", source, failure.Index)
+		}
+		fmt.Printf("item %s: %s\n", source, failure.Reason)
+	}
+}
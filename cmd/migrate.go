@@ -0,0 +1,113 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// migrateCmd installs or upgrades pgstac and the server's own tables
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Install or upgrade the pgstac schema",
+	Long: `Install or upgrade pgstac using the migration files embedded in this binary, or those in
+--migrations-dir, and create the tables used by go-stac-server. Applied migrations are recorded
+in stac_server.migrations.`,
+	Args: cobra.NoArgs,
+	Run:  runMigrate,
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().String("to", "", "pgstac version to migrate to (default newest available version)")
+	migrateCmd.Flags().Bool("dry-run", false, "Print the migrations that would be applied without applying them")
+	migrateCmd.Flags().String("migrations-dir", "", "Directory of pgstac migration files, such as the migrations directory of pypgstac (default the files embedded in this binary)")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	target, _ := cmd.Flags().GetString("to")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	dir, _ := cmd.Flags().GetString("migrations-dir")
+
+	migrations, err := database.PgstacMigrations(dir)
+	if err != nil {
+		log.Error().Err(err).Str("dir", dir).Msg("could not read pgstac migrations")
+		os.Exit(1)
+	}
+	versions := database.PgstacVersions(migrations)
+	if len(versions) == 0 {
+		if dir == "" {
+			log.Error().Msg("no pgstac migrations are embedded in this binary; use --migrations-dir")
+		} else {
+			log.Error().Str("dir", dir).Msg("no pgstac migrations found")
+		}
+		os.Exit(1)
+	}
+
+	// pgstac may not be installed yet so don't let the pool refuse to start
+	viper.Set("database.versionCheck", "off")
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	current, err := database.PgstacVersion(ctx)
+	if err != nil && !errors.Is(err, database.ErrPgstacMissing) {
+		log.Error().Err(err).Msg("could not determine installed pgstac version")
+		os.Exit(1)
+	}
+	if current == "" {
+		current = "not installed"
+	}
+	fmt.Printf("installed pgstac version: %s\n", current)
+	fmt.Printf("available pgstac versions: %s\n", strings.Join(versions, ", "))
+
+	if !dryRun {
+		if err := database.EnsureSchema(ctx); err != nil {
+			log.Error().Err(err).Msg("could not create server tables")
+			os.Exit(1)
+		}
+	}
+
+	applied, err := database.MigratePgstac(ctx, migrations, target, dryRun)
+	if err != nil {
+		log.Error().Err(err).Msg("migration failed")
+		os.Exit(1)
+	}
+	for _, name := range applied {
+		if dryRun {
+			fmt.Printf("would apply %s\n", name)
+		} else {
+			fmt.Printf("applied %s\n", name)
+		}
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("pgstac is up to date")
+	}
+}
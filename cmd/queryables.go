@@ -0,0 +1,247 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// queryablesCmd manages the pgstac.queryables table
+var queryablesCmd = &cobra.Command{
+	Use:   "queryables",
+	Short: "List, create, update and delete queryables",
+	Long: `Manage the queryables advertised by the filter extension. Queryables without --collection
+apply to every collection. --definition accepts a JSON Schema as JSON or @file.`,
+}
+
+var queryablesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List queryables",
+	Args:  cobra.NoArgs,
+	Run:   runQueryablesList,
+}
+
+var queryablesSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Create or replace a queryable",
+	Args:  cobra.ExactArgs(1),
+	Run:   runQueryablesSet,
+}
+
+var queryablesDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a queryable",
+	Args:  cobra.ExactArgs(1),
+	Run:   runQueryablesDelete,
+}
+
+var queryablesDiscoverCmd = &cobra.Command{
+	Use:   "discover <collection>",
+	Short: "Propose queryables from the properties of a collection's items",
+	Long: `Sample the items of a collection and propose a queryable for each property with a consistent
+JSON type, including enums for low-cardinality strings and numeric ranges. Proposals are
+printed as JSON; --register creates the ones that don't exist yet.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runQueryablesDiscover,
+}
+
+func init() {
+	rootCmd.AddCommand(queryablesCmd)
+	queryablesCmd.AddCommand(queryablesListCmd, queryablesSetCmd, queryablesDeleteCmd, queryablesDiscoverCmd)
+
+	queryablesCmd.PersistentFlags().String("collection", "", "Collection the queryable is scoped to (default all collections)")
+
+	queryablesSetCmd.Flags().String("definition", "", "JSON Schema describing the queryable (JSON or @file)")
+	queryablesSetCmd.Flags().String("path", "", "Path of the property within the item (default properties.<name>)")
+	queryablesSetCmd.Flags().String("wrapper", "", "Function used to cast the property one of: "+strings.Join(stac.PropertyWrappers, ", "))
+	queryablesSetCmd.Flags().String("index", "", "Index type to create one of: "+strings.Join(stac.PropertyIndexTypes, ", "))
+	queryablesSetCmd.Flags().Bool("reindex", false, "Create the requested indexes on every items partition")
+	_ = queryablesSetCmd.MarkFlagRequired("definition")
+
+	queryablesDiscoverCmd.Flags().Int("sample", 1000, "Maximum number of items to sample")
+	queryablesDiscoverCmd.Flags().Int("max-enum", 20, "Largest number of distinct strings reported as an enum")
+	queryablesDiscoverCmd.Flags().Bool("register", false, "Create the proposed queryables")
+}
+
+// readJSONArg returns the value of a flag that accepts either JSON or @file
+func readJSONArg(value string) ([]byte, error) {
+	if strings.HasPrefix(value, "@") {
+		return os.ReadFile(strings.TrimPrefix(value, "@"))
+	}
+	return []byte(value), nil
+}
+
+func optionalFlag(cmd *cobra.Command, name string) *string {
+	value, _ := cmd.Flags().GetString(name)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func runQueryablesList(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	collectionID, _ := cmd.Flags().GetString("collection")
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	queryables, err := stac.ListQueryables(ctx, collectionID)
+	if err != nil {
+		log.Error().Err(err).Msg("could not list queryables")
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCOLLECTIONS\tWRAPPER\tINDEX\tDEFINITION")
+	for _, q := range queryables {
+		collections := "*"
+		if len(q.CollectionIDs) > 0 {
+			collections = strings.Join(q.CollectionIDs, ",")
+		}
+		definition := ""
+		if q.Definition != nil {
+			definition = string(*q.Definition)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", q.Name, collections, valueOr(q.PropertyWrapper, "-"), valueOr(q.PropertyIndexType, "-"), definition)
+	}
+	w.Flush()
+}
+
+func valueOr(value *string, fallback string) string {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+func runQueryablesSet(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	collectionID, _ := cmd.Flags().GetString("collection")
+	definitionArg, _ := cmd.Flags().GetString("definition")
+	reindex, _ := cmd.Flags().GetBool("reindex")
+
+	definition, err := readJSONArg(definitionArg)
+	if err != nil {
+		log.Error().Err(err).Str("definition", definitionArg).Msg("could not read definition file")
+		os.Exit(1)
+	}
+	raw := json.RawMessage(definition)
+
+	queryable := &stac.Queryable{
+		Name:              args[0],
+		Definition:        &raw,
+		PropertyPath:      optionalFlag(cmd, "path"),
+		PropertyWrapper:   optionalFlag(cmd, "wrapper"),
+		PropertyIndexType: optionalFlag(cmd, "index"),
+	}
+	if collectionID != "" {
+		queryable.CollectionIDs = []string{collectionID}
+	}
+	if err := queryable.Validate(); err != nil {
+		log.Error().Err(err).Msg("invalid queryable")
+		os.Exit(1)
+	}
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	err = stac.UpdateQueryable(ctx, queryable)
+	if errors.Is(err, stac.ErrQueryableNotFound) {
+		err = stac.CreateQueryable(ctx, queryable)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("name", queryable.Name).Msg("could not save queryable")
+		os.Exit(1)
+	}
+
+	if reindex {
+		if err := stac.ReindexQueryables(ctx); err != nil {
+			log.Error().Err(err).Msg("could not create queryable indexes")
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("saved queryable %s\n", queryable.Name)
+}
+
+func runQueryablesDelete(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	collectionID, _ := cmd.Flags().GetString("collection")
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	if err := stac.DeleteQueryable(ctx, args[0], collectionID); err != nil {
+		log.Error().Err(err).Str("name", args[0]).Msg("could not delete queryable")
+		os.Exit(1)
+	}
+	fmt.Printf("deleted queryable %s\n", args[0])
+}
+
+func runQueryablesDiscover(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	sample, _ := cmd.Flags().GetInt("sample")
+	maxEnum, _ := cmd.Flags().GetInt("max-enum")
+	register, _ := cmd.Flags().GetBool("register")
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	queryables, err := stac.DiscoverQueryables(ctx, args[0], stac.DiscoverOptions{
+		SampleSize:    sample,
+		MaxEnumValues: maxEnum,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("collection", args[0]).Msg("could not sample collection items")
+		os.Exit(1)
+	}
+
+	out, err := json.MarshalIndent(queryables, "", "  ")
+	if err != nil {
+		log.Error().Err(err).Msg("could not serialize queryables")
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	if register {
+		created, err := stac.RegisterQueryables(ctx, queryables)
+		if err != nil {
+			log.Error().Err(err).Msg("could not register queryables")
+			os.Exit(1)
+		}
+		fmt.Printf("registered %d of %d proposed queryables\n", len(created), len(queryables))
+	}
+}
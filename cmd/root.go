@@ -16,26 +16,14 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
-	"os/signal"
 	"time"
 
-	"github.com/ansrivas/fiberprometheus/v2"
-	"github.com/go-geospatial/go-stac-server/common"
-	"github.com/go-geospatial/go-stac-server/database"
-	"github.com/go-geospatial/go-stac-server/middleware"
-	"github.com/go-geospatial/go-stac-server/router"
-	"github.com/go-geospatial/go-stac-server/static"
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/compress"
-	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/go-geospatial/go-stac-server/auth"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-
-	json "github.com/goccy/go-json"
 )
 
 var cfgFile string
@@ -45,74 +33,7 @@ var rootCmd = &cobra.Command{
 	Use:   "go-stac-server",
 	Short: "Serve STAC API v1.0.0",
 	Long:  `go-stac-server implements a 1.0.0 compliant STAC api backed by a pgstac database`,
-	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
-
-		common.SetupLogging()
-		log.Info().Msg("initialized logging")
-
-		// try connecting to the database early so we fail fast if
-		// we cannot connect to the database
-		pool := database.GetInstance(ctx)
-		defer pool.Close()
-
-		configBaseURL := viper.GetString("server.baseUrl")
-		if configBaseURL != "" {
-			log.Info().Str("BaseUrl", configBaseURL).Msg("using configured base URL")
-		}
-
-		// Create new Fiber instance
-		app := fiber.New(fiber.Config{
-			JSONEncoder: json.Marshal,
-			JSONDecoder: json.Unmarshal,
-		})
-
-		// shutdown cleanly on interrupt
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-		go func() {
-			sig := <-c // block until signal is read
-			fmt.Printf("Received signal: '%s'; shutting down...\n", sig.String())
-			err := app.ShutdownWithTimeout(time.Second * 5)
-			if err != nil {
-				log.Fatal().Err(err).Msg("app shutdown failed")
-			}
-		}()
-
-		// Configure CORS
-		corsConfig := cors.Config{
-			AllowOrigins: "*",
-			AllowHeaders: "Accept, Accept-CH, Accept-Charset, Accept-Datetime, Accept-Encoding, Accept-Ext, Accept-Features, Accept-Language, Accept-Params, Accept-Ranges, Access-Control-Allow-Credentials, Access-Control-Allow-Headers, Access-Control-Allow-Methods, Access-Control-Allow-Origin, Access-Control-Expose-Headers, Access-Control-Max-Age, Access-Control-Request-Headers, Access-Control-Request-Method, Age, Allow, Alternates, Authentication-Info, Authorization, C-Ext, C-Man, C-Opt, C-PEP, C-PEP-Info, CONNECT, Cache-Control, Compliance, Connection, Content-Base, Content-Disposition, Content-Encoding, Content-ID, Content-Language, Content-Length, Content-Location, Content-MD5, Content-Range, Content-Script-Type, Content-Security-Policy, Content-Style-Type, Content-Transfer-Encoding, Content-Type, Content-Version, Cookie, Cost, DAV, DELETE, DNT, DPR, Date, Default-Style, Delta-Base, Depth, Derived-From, Destination, Differential-ID, Digest, ETag, Expect, Expires, Ext, From, GET, GetProfile, HEAD, HTTP-date, Host, IM, If, If-Match, If-Modified-Since, If-None-Match, If-Range, If-Unmodified-Since, Keep-Alive, Label, Last-Event-ID, Last-Modified, Link, Location, Lock-Token, MIME-Version, Man, Max-Forwards, Media-Range, Message-ID, Meter, Negotiate, Non-Compliance, OPTION, OPTIONS, OWS, Opt, Optional, Ordering-Type, Origin, Overwrite, P3P, PEP, PICS-Label, POST, PUT, Pep-Info, Permanent, Position, Pragma, ProfileObject, Protocol, Protocol-Query, Protocol-Request, Proxy-Authenticate, Proxy-Authentication-Info, Proxy-Authorization, Proxy-Features, Proxy-Instruction, Public, RWS, Range, Referer, Refresh, Resolution-Hint, Resolver-Location, Retry-After, Safe, Sec-Websocket-Extensions, Sec-Websocket-Key, Sec-Websocket-Origin, Sec-Websocket-Protocol, Sec-Websocket-Version, Security-Scheme, Server, Set-Cookie, Set-Cookie2, SetProfile, SoapAction, Status, Status-URI, Strict-Transport-Security, SubOK, Subst, Surrogate-Capability, Surrogate-Control, TCN, TE, TRACE, Timeout, Title, Trailer, Transfer-Encoding, UA-Color, UA-Media, UA-Pixels, UA-Resolution, UA-Windowpixels, URI, Upgrade, User-Agent, Variant-Vary, Vary, Version, Via, Viewport-Width, WWW-Authenticate, Want-Digest, Warning, Width, X-Content-Duration, X-Content-Security-Policy, X-Content-Type-Options, X-CustomHeader, X-DNSPrefetch-Control, X-Forwarded-For, X-Forwarded-Port, X-Forwarded-Proto, X-Frame-Options, X-Modified, X-OTHER, X-PING, X-PINGOTHER, X-Powered-By, X-Requested-With",
-			AllowMethods: "GET,POST,HEAD,PUT,DELETE,PATCH",
-		}
-		app.Use(cors.New(corsConfig))
-
-		// compression
-		app.Use(compress.New(compress.Config{
-			Level: compress.LevelBestSpeed, // 1
-		}))
-
-		// Setup logging middleware
-		app.Use(middleware.NewLogger())
-
-		// Add timing headers
-		app.Use(middleware.Timer())
-
-		prometheus := fiberprometheus.New("go-stac-server")
-		prometheus.RegisterAt(app, "/api/stac/v1/metrics")
-		app.Use(prometheus.Middleware)
-
-		// Setup routes
-		router.SetupRoutes(app)
-
-		// configure static serves
-		static.InitStaticFiles(app)
-
-		err := app.Listen(":" + viper.GetString("server.port"))
-		if err != nil {
-			log.Fatal().Err(err).Msg("app.Listen returned an error")
-		}
-	},
+	Run:   serve,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -146,6 +67,120 @@ func init() {
 		log.Panic().Err(err).Msg("could not bind base-url")
 	}
 
+	if err := viper.BindEnv("server.proxyHeader", "PROXY_HEADER"); err != nil {
+		log.Panic().Err(err).Msg("could not bind PROXY_HEADER")
+	}
+	rootCmd.Flags().String("proxy-header", "", "Header trusted proxies put the client address in, e.g. X-Forwarded-For; requires --trusted-proxies")
+	if err := viper.BindPFlag("server.proxyHeader", rootCmd.Flags().Lookup("proxy-header")); err != nil {
+		log.Panic().Err(err).Msg("could not bind proxy-header")
+	}
+
+	if err := viper.BindEnv("server.trustedProxies", "TRUSTED_PROXIES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TRUSTED_PROXIES")
+	}
+	rootCmd.Flags().StringSlice("trusted-proxies", nil, "Addresses or CIDR ranges of the proxies whose --proxy-header is believed")
+	if err := viper.BindPFlag("server.trustedProxies", rootCmd.Flags().Lookup("trusted-proxies")); err != nil {
+		log.Panic().Err(err).Msg("could not bind trusted-proxies")
+	}
+
+	// health checks
+	if err := viper.BindEnv("server.health.startupGrace", "STARTUP_GRACE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind STARTUP_GRACE")
+	}
+	rootCmd.Flags().Duration("startup-grace", 0, "How long after startup failing readiness checks report STARTING instead of FAILED")
+	if err := viper.BindPFlag("server.health.startupGrace", rootCmd.Flags().Lookup("startup-grace")); err != nil {
+		log.Panic().Err(err).Msg("could not bind startup-grace")
+	}
+
+	if err := viper.BindEnv("server.health.timeout", "HEALTH_TIMEOUT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind HEALTH_TIMEOUT")
+	}
+	rootCmd.Flags().Duration("health-timeout", 2*time.Second, "Time each readiness check may take")
+	if err := viper.BindPFlag("server.health.timeout", rootCmd.Flags().Lookup("health-timeout")); err != nil {
+		log.Panic().Err(err).Msg("could not bind health-timeout")
+	}
+
+	if err := viper.BindEnv("server.health.maxPoolSaturation", "HEALTH_MAX_POOL_SATURATION"); err != nil {
+		log.Panic().Err(err).Msg("could not bind HEALTH_MAX_POOL_SATURATION")
+	}
+	rootCmd.Flags().Float64("health-max-pool-saturation", 0.9, "Fraction of pool connections in use above which the server isn't ready, 0 to disable")
+	if err := viper.BindPFlag("server.health.maxPoolSaturation", rootCmd.Flags().Lookup("health-max-pool-saturation")); err != nil {
+		log.Panic().Err(err).Msg("could not bind health-max-pool-saturation")
+	}
+
+	// TLS
+	if err := viper.BindEnv("server.tls.cert", "TLS_CERT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_CERT")
+	}
+	rootCmd.Flags().String("tls-cert", "", "PEM certificate (chain) to serve HTTPS with")
+	if err := viper.BindPFlag("server.tls.cert", rootCmd.Flags().Lookup("tls-cert")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-cert")
+	}
+
+	if err := viper.BindEnv("server.tls.key", "TLS_KEY"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_KEY")
+	}
+	rootCmd.Flags().String("tls-key", "", "PEM private key of --tls-cert")
+	if err := viper.BindPFlag("server.tls.key", rootCmd.Flags().Lookup("tls-key")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-key")
+	}
+
+	if err := viper.BindEnv("server.tls.minVersion", "TLS_MIN_VERSION"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_MIN_VERSION")
+	}
+	rootCmd.Flags().String("tls-min-version", "1.2", "Minimum TLS version: 1.2 or 1.3")
+	if err := viper.BindPFlag("server.tls.minVersion", rootCmd.Flags().Lookup("tls-min-version")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-min-version")
+	}
+
+	if err := viper.BindEnv("server.tls.cipherSuites", "TLS_CIPHER_SUITES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_CIPHER_SUITES")
+	}
+	rootCmd.Flags().StringSlice("tls-cipher-suites", nil, "TLS 1.2 cipher suites to allow, defaults to Go's secure suites")
+	if err := viper.BindPFlag("server.tls.cipherSuites", rootCmd.Flags().Lookup("tls-cipher-suites")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-cipher-suites")
+	}
+
+	if err := viper.BindEnv("server.tls.clientCa", "TLS_CLIENT_CA"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_CLIENT_CA")
+	}
+	rootCmd.Flags().String("tls-client-ca", "", "PEM CA bundle verifying client certificates (mutual TLS)")
+	if err := viper.BindPFlag("server.tls.clientCa", rootCmd.Flags().Lookup("tls-client-ca")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-client-ca")
+	}
+
+	if err := viper.BindEnv("server.tls.clientAuth", "TLS_CLIENT_AUTH"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_CLIENT_AUTH")
+	}
+	rootCmd.Flags().String("tls-client-auth", "require", "Whether clients must present a certificate when --tls-client-ca is set: require or optional")
+	if err := viper.BindPFlag("server.tls.clientAuth", rootCmd.Flags().Lookup("tls-client-auth")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-client-auth")
+	}
+
+	if err := viper.BindEnv("server.tls.reloadInterval", "TLS_RELOAD_INTERVAL"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TLS_RELOAD_INTERVAL")
+	}
+	rootCmd.Flags().Duration("tls-reload-interval", 30*time.Second, "How often certificate files are checked for changes, 0 to reload only on SIGHUP")
+	if err := viper.BindPFlag("server.tls.reloadInterval", rootCmd.Flags().Lookup("tls-reload-interval")); err != nil {
+		log.Panic().Err(err).Msg("could not bind tls-reload-interval")
+	}
+
+	if err := viper.BindEnv("auth.mtls.subject", "AUTH_MTLS_SUBJECT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_MTLS_SUBJECT")
+	}
+	rootCmd.Flags().String("auth-mtls-subject", "cn", "Client certificate subject used as the principal name: cn or dn")
+	if err := viper.BindPFlag("auth.mtls.subject", rootCmd.Flags().Lookup("auth-mtls-subject")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-mtls-subject")
+	}
+
+	if err := viper.BindEnv("auth.mtls.defaultRoles", "AUTH_MTLS_DEFAULT_ROLES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_MTLS_DEFAULT_ROLES")
+	}
+	rootCmd.Flags().StringSlice("auth-mtls-default-roles", []string{auth.RoleReader}, "Roles of client certificates not listed in auth.clientCerts")
+	if err := viper.BindPFlag("auth.mtls.defaultRoles", rootCmd.Flags().Lookup("auth-mtls-default-roles")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-mtls-default-roles")
+	}
+
 	// GUI flags
 	if err := viper.BindEnv("gui.config", "GUI_CONFIG"); err != nil {
 		log.Panic().Err(err).Msg("could not bind GUI_CONFIG")
@@ -188,6 +223,22 @@ func init() {
 		log.Panic().Err(err).Msg("could not bind log-otlp-url")
 	}
 
+	if err := viper.BindEnv("log.otlp_sample_ratio", "OTLP_SAMPLE_RATIO"); err != nil {
+		log.Panic().Err(err).Msg("could not bind OTLP_SAMPLE_RATIO")
+	}
+	rootCmd.PersistentFlags().Float64("log-otlp-sample-ratio", 1, "Fraction of new traces to sample; incoming traceparent sampling decisions are kept")
+	if err := viper.BindPFlag("log.otlp_sample_ratio", rootCmd.PersistentFlags().Lookup("log-otlp-sample-ratio")); err != nil {
+		log.Panic().Err(err).Msg("could not bind log-otlp-sample-ratio")
+	}
+
+	if err := viper.BindEnv("log.otlp_service_name", "OTLP_SERVICE_NAME"); err != nil {
+		log.Panic().Err(err).Msg("could not bind OTLP_SERVICE_NAME")
+	}
+	rootCmd.PersistentFlags().String("log-otlp-service-name", "go-stac-server", "Service name reported with traces")
+	if err := viper.BindPFlag("log.otlp_service_name", rootCmd.PersistentFlags().Lookup("log-otlp-service-name")); err != nil {
+		log.Panic().Err(err).Msg("could not bind log-otlp-service-name")
+	}
+
 	if err := viper.BindEnv("log.pretty", "LOG_PRETTY"); err != nil {
 		log.Panic().Err(err).Msg("could not bind LOG_PRETTY")
 	}
@@ -205,6 +256,498 @@ func init() {
 		log.Panic().Err(err).Msg("could not bind database.dsn")
 	}
 
+	if err := viper.BindEnv("database.versionCheck", "DATABASE_VERSION_CHECK"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_VERSION_CHECK")
+	}
+	rootCmd.PersistentFlags().String("pgstac-version-check", "warn", "Action when the installed pgstac version is unsupported one of: strict, warn, off")
+	if err := viper.BindPFlag("database.versionCheck", rootCmd.PersistentFlags().Lookup("pgstac-version-check")); err != nil {
+		log.Panic().Err(err).Msg("could not bind pgstac-version-check")
+	}
+
+	if err := viper.BindEnv("database.host", "DATABASE_HOST"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_HOST")
+	}
+	rootCmd.PersistentFlags().String("database-host", "", "Database host, overrides the host of --dsn")
+	if err := viper.BindPFlag("database.host", rootCmd.PersistentFlags().Lookup("database-host")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-host")
+	}
+
+	if err := viper.BindEnv("database.port", "DATABASE_PORT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_PORT")
+	}
+	rootCmd.PersistentFlags().Uint16("database-port", 0, "Database port, overrides the port of --dsn")
+	if err := viper.BindPFlag("database.port", rootCmd.PersistentFlags().Lookup("database-port")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-port")
+	}
+
+	if err := viper.BindEnv("database.user", "DATABASE_USER"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_USER")
+	}
+	rootCmd.PersistentFlags().String("database-user", "", "Database user, overrides the user of --dsn")
+	if err := viper.BindPFlag("database.user", rootCmd.PersistentFlags().Lookup("database-user")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-user")
+	}
+
+	if err := viper.BindEnv("database.name", "DATABASE_NAME"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_NAME")
+	}
+	rootCmd.PersistentFlags().String("database-name", "", "Database name, overrides the database of --dsn")
+	if err := viper.BindPFlag("database.name", rootCmd.PersistentFlags().Lookup("database-name")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-name")
+	}
+
+	// passwords are kept off the command line where other users could see them
+	if err := viper.BindEnv("database.password", "DATABASE_PASSWORD"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_PASSWORD")
+	}
+
+	if err := viper.BindEnv("database.passwordFile", "DATABASE_PASSWORD_FILE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_PASSWORD_FILE")
+	}
+	rootCmd.PersistentFlags().String("database-password-file", "", "File holding the database password, read again for every new connection")
+	if err := viper.BindPFlag("database.passwordFile", rootCmd.PersistentFlags().Lookup("database-password-file")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-password-file")
+	}
+
+	if err := viper.BindEnv("database.maxConns", "DATABASE_MAX_CONNS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_MAX_CONNS")
+	}
+	rootCmd.PersistentFlags().Int32("database-max-conns", 0, "Maximum number of pooled connections, 0 for the larger of 4 and the number of CPUs")
+	if err := viper.BindPFlag("database.maxConns", rootCmd.PersistentFlags().Lookup("database-max-conns")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-max-conns")
+	}
+
+	if err := viper.BindEnv("database.minConns", "DATABASE_MIN_CONNS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_MIN_CONNS")
+	}
+	rootCmd.PersistentFlags().Int32("database-min-conns", 0, "Number of connections the pool keeps open even when idle")
+	if err := viper.BindPFlag("database.minConns", rootCmd.PersistentFlags().Lookup("database-min-conns")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-min-conns")
+	}
+
+	if err := viper.BindEnv("database.maxConnLifetime", "DATABASE_MAX_CONN_LIFETIME"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_MAX_CONN_LIFETIME")
+	}
+	rootCmd.PersistentFlags().Duration("database-max-conn-lifetime", 0, "How long a connection is used before it is replaced, 0 for 1h")
+	if err := viper.BindPFlag("database.maxConnLifetime", rootCmd.PersistentFlags().Lookup("database-max-conn-lifetime")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-max-conn-lifetime")
+	}
+
+	if err := viper.BindEnv("database.maxConnIdleTime", "DATABASE_MAX_CONN_IDLE_TIME"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_MAX_CONN_IDLE_TIME")
+	}
+	rootCmd.PersistentFlags().Duration("database-max-conn-idle-time", 0, "How long a connection may sit idle before it is closed, 0 for 30m")
+	if err := viper.BindPFlag("database.maxConnIdleTime", rootCmd.PersistentFlags().Lookup("database-max-conn-idle-time")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-max-conn-idle-time")
+	}
+
+	if err := viper.BindEnv("database.healthCheckPeriod", "DATABASE_HEALTH_CHECK_PERIOD"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_HEALTH_CHECK_PERIOD")
+	}
+	rootCmd.PersistentFlags().Duration("database-health-check-period", 0, "How often idle connections are checked, 0 for 1m")
+	if err := viper.BindPFlag("database.healthCheckPeriod", rootCmd.PersistentFlags().Lookup("database-health-check-period")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-health-check-period")
+	}
+
+	if err := viper.BindEnv("database.statementCacheMode", "DATABASE_STATEMENT_CACHE_MODE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_STATEMENT_CACHE_MODE")
+	}
+	rootCmd.PersistentFlags().String("database-statement-cache-mode", "", "How queries are prepared one of: cache_statement (default), cache_describe, describe_exec, exec, simple_protocol; use exec or simple_protocol behind PgBouncer in transaction mode")
+	if err := viper.BindPFlag("database.statementCacheMode", rootCmd.PersistentFlags().Lookup("database-statement-cache-mode")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-statement-cache-mode")
+	}
+
+	if err := viper.BindEnv("database.connectRetries", "DATABASE_CONNECT_RETRIES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_CONNECT_RETRIES")
+	}
+	rootCmd.PersistentFlags().Int("database-connect-retries", 5, "Times to retry connecting to the database at startup")
+	if err := viper.BindPFlag("database.connectRetries", rootCmd.PersistentFlags().Lookup("database-connect-retries")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-connect-retries")
+	}
+
+	if err := viper.BindEnv("database.connectBackoff", "DATABASE_CONNECT_BACKOFF"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_CONNECT_BACKOFF")
+	}
+	rootCmd.PersistentFlags().Duration("database-connect-backoff", time.Second, "Wait before the first connection retry, doubled after each attempt")
+	if err := viper.BindPFlag("database.connectBackoff", rootCmd.PersistentFlags().Lookup("database-connect-backoff")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-connect-backoff")
+	}
+
+	if err := viper.BindEnv("database.connectMaxBackoff", "DATABASE_CONNECT_MAX_BACKOFF"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_CONNECT_MAX_BACKOFF")
+	}
+	rootCmd.PersistentFlags().Duration("database-connect-max-backoff", 30*time.Second, "Longest wait between connection retries")
+	if err := viper.BindPFlag("database.connectMaxBackoff", rootCmd.PersistentFlags().Lookup("database-connect-max-backoff")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-connect-max-backoff")
+	}
+
+	// ingest jobs
+	if err := viper.BindEnv("jobs.workers", "JOB_WORKERS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind JOB_WORKERS")
+	}
+	rootCmd.Flags().Int("job-workers", 2, "Number of workers processing asynchronous ingest jobs")
+	if err := viper.BindPFlag("jobs.workers", rootCmd.Flags().Lookup("job-workers")); err != nil {
+		log.Panic().Err(err).Msg("could not bind job-workers")
+	}
+
+	if err := viper.BindEnv("jobs.batchSize", "JOB_BATCH_SIZE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind JOB_BATCH_SIZE")
+	}
+	rootCmd.Flags().Int("job-batch-size", 500, "Number of items inserted per batch by ingest jobs")
+	if err := viper.BindPFlag("jobs.batchSize", rootCmd.Flags().Lookup("job-batch-size")); err != nil {
+		log.Panic().Err(err).Msg("could not bind job-batch-size")
+	}
+
+	if err := viper.BindEnv("jobs.maxBodyBytes", "JOB_MAX_BODY_BYTES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind JOB_MAX_BODY_BYTES")
+	}
+	rootCmd.Flags().Int64("job-max-body-bytes", 256<<20, "Largest Feature or FeatureCollection submitted with async=true in bytes, 0 for no maximum; newline delimited uploads use --ingest-max-body-bytes")
+	if err := viper.BindPFlag("jobs.maxBodyBytes", rootCmd.Flags().Lookup("job-max-body-bytes")); err != nil {
+		log.Panic().Err(err).Msg("could not bind job-max-body-bytes")
+	}
+
+	if err := viper.BindEnv("ingest.batchSize", "INGEST_BATCH_SIZE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind INGEST_BATCH_SIZE")
+	}
+	rootCmd.Flags().Int("ingest-batch-size", 500, "Number of items inserted per batch when streaming newline delimited items")
+	if err := viper.BindPFlag("ingest.batchSize", rootCmd.Flags().Lookup("ingest-batch-size")); err != nil {
+		log.Panic().Err(err).Msg("could not bind ingest-batch-size")
+	}
+
+	if err := viper.BindEnv("ingest.maxBodyBytes", "INGEST_MAX_BODY_BYTES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind INGEST_MAX_BODY_BYTES")
+	}
+	rootCmd.Flags().Int64("ingest-max-body-bytes", 1<<30, "Largest newline delimited item upload in bytes, with or without async=true, 0 for no maximum")
+	if err := viper.BindPFlag("ingest.maxBodyBytes", rootCmd.Flags().Lookup("ingest-max-body-bytes")); err != nil {
+		log.Panic().Err(err).Msg("could not bind ingest-max-body-bytes")
+	}
+
+	if err := viper.BindEnv("queryables.fromSummaries", "QUERYABLES_FROM_SUMMARIES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind QUERYABLES_FROM_SUMMARIES")
+	}
+	rootCmd.Flags().Bool("queryables-from-summaries", true, "Register queryables from collection summaries and item_assets when collections are created or updated")
+	if err := viper.BindPFlag("queryables.fromSummaries", rootCmd.Flags().Lookup("queryables-from-summaries")); err != nil {
+		log.Panic().Err(err).Msg("could not bind queryables-from-summaries")
+	}
+
+	if err := viper.BindEnv("filter.strict", "STRICT_QUERYABLES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind STRICT_QUERYABLES")
+	}
+	rootCmd.Flags().Bool("strict-queryables", false, "Reject filters and query expressions that reference properties which aren't queryable")
+	if err := viper.BindPFlag("filter.strict", rootCmd.Flags().Lookup("strict-queryables")); err != nil {
+		log.Panic().Err(err).Msg("could not bind strict-queryables")
+	}
+
+	if err := viper.BindEnv("stats.cacheTtl", "STATS_CACHE_TTL"); err != nil {
+		log.Panic().Err(err).Msg("could not bind STATS_CACHE_TTL")
+	}
+	rootCmd.Flags().Duration("stats-cache-ttl", 5*time.Minute, "How long collection statistics and item counts are cached, 0 to keep them until refreshed with refresh=true")
+	if err := viper.BindPFlag("stats.cacheTtl", rootCmd.Flags().Lookup("stats-cache-ttl")); err != nil {
+		log.Panic().Err(err).Msg("could not bind stats-cache-ttl")
+	}
+
+	// authentication
+	if err := viper.BindEnv("auth.enabled", "AUTH_ENABLED"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_ENABLED")
+	}
+	rootCmd.Flags().Bool("auth-enabled", false, "Require credentials with the writer or admin role for write and admin routes")
+	if err := viper.BindPFlag("auth.enabled", rootCmd.Flags().Lookup("auth-enabled")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-enabled")
+	}
+
+	if err := viper.BindEnv("auth.protectReads", "AUTH_PROTECT_READS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_PROTECT_READS")
+	}
+	rootCmd.Flags().Bool("auth-protect-reads", false, "Also require credentials with the reader role for read routes")
+	if err := viper.BindPFlag("auth.protectReads", rootCmd.Flags().Lookup("auth-protect-reads")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-protect-reads")
+	}
+
+	if err := viper.BindEnv("auth.jwt.jwksUrl", "AUTH_JWKS_URL"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_JWKS_URL")
+	}
+	rootCmd.Flags().String("auth-jwks-url", "", "URL of the identity provider's JWKS used to verify bearer tokens")
+	if err := viper.BindPFlag("auth.jwt.jwksUrl", rootCmd.Flags().Lookup("auth-jwks-url")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-jwks-url")
+	}
+
+	if err := viper.BindEnv("auth.jwt.jwksFile", "AUTH_JWKS_FILE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_JWKS_FILE")
+	}
+	rootCmd.Flags().String("auth-jwks-file", "", "Local JWKS file used to verify bearer tokens, for offline setups")
+	if err := viper.BindPFlag("auth.jwt.jwksFile", rootCmd.Flags().Lookup("auth-jwks-file")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-jwks-file")
+	}
+
+	if err := viper.BindEnv("auth.jwt.issuer", "AUTH_JWT_ISSUER"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_JWT_ISSUER")
+	}
+	rootCmd.Flags().String("auth-jwt-issuer", "", "Required iss claim of bearer tokens")
+	if err := viper.BindPFlag("auth.jwt.issuer", rootCmd.Flags().Lookup("auth-jwt-issuer")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-jwt-issuer")
+	}
+
+	if err := viper.BindEnv("auth.jwt.audience", "AUTH_JWT_AUDIENCE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_JWT_AUDIENCE")
+	}
+	rootCmd.Flags().String("auth-jwt-audience", "", "Required aud claim of bearer tokens")
+	if err := viper.BindPFlag("auth.jwt.audience", rootCmd.Flags().Lookup("auth-jwt-audience")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-jwt-audience")
+	}
+
+	if err := viper.BindEnv("auth.jwt.rolesClaim", "AUTH_JWT_ROLES_CLAIM"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUTH_JWT_ROLES_CLAIM")
+	}
+	rootCmd.Flags().String("auth-jwt-roles-claim", "roles", "Claim holding the caller's roles, dotted for nested claims e.g. realm_access.roles")
+	if err := viper.BindPFlag("auth.jwt.rolesClaim", rootCmd.Flags().Lookup("auth-jwt-roles-claim")); err != nil {
+		log.Panic().Err(err).Msg("could not bind auth-jwt-roles-claim")
+	}
+
+	// rate limiting
+	if err := viper.BindEnv("ratelimit.search.perMinute", "RATE_LIMIT_SEARCH"); err != nil {
+		log.Panic().Err(err).Msg("could not bind RATE_LIMIT_SEARCH")
+	}
+	rootCmd.Flags().Float64("rate-limit-search", 0, "Search requests allowed per caller per minute, 0 for no limit")
+	if err := viper.BindPFlag("ratelimit.search.perMinute", rootCmd.Flags().Lookup("rate-limit-search")); err != nil {
+		log.Panic().Err(err).Msg("could not bind rate-limit-search")
+	}
+
+	if err := viper.BindEnv("ratelimit.items.perMinute", "RATE_LIMIT_ITEMS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind RATE_LIMIT_ITEMS")
+	}
+	rootCmd.Flags().Float64("rate-limit-items", 0, "Item and item list reads allowed per caller per minute, 0 for no limit")
+	if err := viper.BindPFlag("ratelimit.items.perMinute", rootCmd.Flags().Lookup("rate-limit-items")); err != nil {
+		log.Panic().Err(err).Msg("could not bind rate-limit-items")
+	}
+
+	if err := viper.BindEnv("ratelimit.transactions.perMinute", "RATE_LIMIT_TRANSACTIONS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind RATE_LIMIT_TRANSACTIONS")
+	}
+	rootCmd.Flags().Float64("rate-limit-transactions", 0, "Write requests allowed per caller per minute, 0 for no limit")
+	if err := viper.BindPFlag("ratelimit.transactions.perMinute", rootCmd.Flags().Lookup("rate-limit-transactions")); err != nil {
+		log.Panic().Err(err).Msg("could not bind rate-limit-transactions")
+	}
+
+	if err := viper.BindEnv("ratelimit.authFailures.perMinute", "RATE_LIMIT_AUTH_FAILURES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind RATE_LIMIT_AUTH_FAILURES")
+	}
+	rootCmd.Flags().Float64("rate-limit-auth-failures", 10, "Requests with invalid credentials allowed per IP address per minute, 0 for no limit")
+	if err := viper.BindPFlag("ratelimit.authFailures.perMinute", rootCmd.Flags().Lookup("rate-limit-auth-failures")); err != nil {
+		log.Panic().Err(err).Msg("could not bind rate-limit-auth-failures")
+	}
+
+	if err := viper.BindEnv("ratelimit.shared", "RATE_LIMIT_SHARED"); err != nil {
+		log.Panic().Err(err).Msg("could not bind RATE_LIMIT_SHARED")
+	}
+	rootCmd.Flags().Bool("rate-limit-shared", false, "Keep rate limit state in Postgres so limits hold across replicas")
+	if err := viper.BindPFlag("ratelimit.shared", rootCmd.Flags().Lookup("rate-limit-shared")); err != nil {
+		log.Panic().Err(err).Msg("could not bind rate-limit-shared")
+	}
+
+	// read-only mode and extensions
+	if err := viper.BindEnv("server.readOnly", "READ_ONLY"); err != nil {
+		log.Panic().Err(err).Msg("could not bind READ_ONLY")
+	}
+	rootCmd.Flags().Bool("read-only", false, "Serve without any route that writes to the database")
+	if err := viper.BindPFlag("server.readOnly", rootCmd.Flags().Lookup("read-only")); err != nil {
+		log.Panic().Err(err).Msg("could not bind read-only")
+	}
+
+	if err := viper.BindEnv("extensions.transactions", "TRANSACTIONS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind TRANSACTIONS")
+	}
+	rootCmd.Flags().Bool("transactions", true, "Serve the transaction extension and asynchronous ingest jobs")
+	if err := viper.BindPFlag("extensions.transactions", rootCmd.Flags().Lookup("transactions")); err != nil {
+		log.Panic().Err(err).Msg("could not bind transactions")
+	}
+
+	if err := viper.BindEnv("extensions.admin", "ADMIN_API"); err != nil {
+		log.Panic().Err(err).Msg("could not bind ADMIN_API")
+	}
+	rootCmd.Flags().Bool("admin-api", false, "Serve queryables management, pgstac settings and summary recomputation; requires --auth-enabled")
+	if err := viper.BindPFlag("extensions.admin", rootCmd.Flags().Lookup("admin-api")); err != nil {
+		log.Panic().Err(err).Msg("could not bind admin-api")
+	}
+
+	if err := viper.BindEnv("database.readOnlyRole", "DATABASE_READ_ONLY_ROLE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DATABASE_READ_ONLY_ROLE")
+	}
+	rootCmd.Flags().String("database-read-only-role", "", "Role switched to on every connection in read-only mode, e.g. pgstac_read")
+	if err := viper.BindPFlag("database.readOnlyRole", rootCmd.Flags().Lookup("database-read-only-role")); err != nil {
+		log.Panic().Err(err).Msg("could not bind database-read-only-role")
+	}
+
+	// CORS
+	if err := viper.BindEnv("cors.allowOrigins", "CORS_ORIGINS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_ORIGINS")
+	}
+	rootCmd.Flags().StringSlice("cors-origins", []string{"*"}, "Origins allowed to read, e.g. https://*.example.com for any subdomain")
+	if err := viper.BindPFlag("cors.allowOrigins", rootCmd.Flags().Lookup("cors-origins")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-origins")
+	}
+
+	if err := viper.BindEnv("cors.allowMethods", "CORS_METHODS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_METHODS")
+	}
+	rootCmd.Flags().StringSlice("cors-methods", []string{"GET", "HEAD", "POST"}, "Methods allowed for reads")
+	if err := viper.BindPFlag("cors.allowMethods", rootCmd.Flags().Lookup("cors-methods")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-methods")
+	}
+
+	if err := viper.BindEnv("cors.allowHeaders", "CORS_HEADERS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_HEADERS")
+	}
+	rootCmd.Flags().StringSlice("cors-headers", []string{"Accept", "Accept-Encoding", "Authorization", "Content-Type", "If-Match", "If-None-Match", "Origin", "X-API-Key", "X-Requested-With", "traceparent", "tracestate"}, "Request headers allowed in cross-origin requests")
+	if err := viper.BindPFlag("cors.allowHeaders", rootCmd.Flags().Lookup("cors-headers")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-headers")
+	}
+
+	if err := viper.BindEnv("cors.exposeHeaders", "CORS_EXPOSE_HEADERS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_EXPOSE_HEADERS")
+	}
+	rootCmd.Flags().StringSlice("cors-expose-headers", []string{"ETag", "Location", "Server-Timing", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"}, "Response headers exposed to cross-origin scripts")
+	if err := viper.BindPFlag("cors.exposeHeaders", rootCmd.Flags().Lookup("cors-expose-headers")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-expose-headers")
+	}
+
+	if err := viper.BindEnv("cors.allowCredentials", "CORS_ALLOW_CREDENTIALS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_ALLOW_CREDENTIALS")
+	}
+	rootCmd.Flags().Bool("cors-allow-credentials", false, "Allow cross-origin requests with cookies or HTTP authentication; requires explicit origins")
+	if err := viper.BindPFlag("cors.allowCredentials", rootCmd.Flags().Lookup("cors-allow-credentials")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-allow-credentials")
+	}
+
+	if err := viper.BindEnv("cors.maxAge", "CORS_MAX_AGE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_MAX_AGE")
+	}
+	rootCmd.Flags().Int("cors-max-age", 0, "Seconds browsers may cache preflight responses, 0 to leave unset")
+	if err := viper.BindPFlag("cors.maxAge", rootCmd.Flags().Lookup("cors-max-age")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-max-age")
+	}
+
+	if err := viper.BindEnv("cors.write.allowOrigins", "CORS_WRITE_ORIGINS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_WRITE_ORIGINS")
+	}
+	rootCmd.Flags().StringSlice("cors-write-origins", nil, "Origins allowed to write, defaults to --cors-origins")
+	if err := viper.BindPFlag("cors.write.allowOrigins", rootCmd.Flags().Lookup("cors-write-origins")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-write-origins")
+	}
+
+	if err := viper.BindEnv("cors.write.allowMethods", "CORS_WRITE_METHODS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind CORS_WRITE_METHODS")
+	}
+	rootCmd.Flags().StringSlice("cors-write-methods", []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}, "Methods allowed for writes")
+	if err := viper.BindPFlag("cors.write.allowMethods", rootCmd.Flags().Lookup("cors-write-methods")); err != nil {
+		log.Panic().Err(err).Msg("could not bind cors-write-methods")
+	}
+
+	// audit log
+	if err := viper.BindEnv("audit.database", "AUDIT_DATABASE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUDIT_DATABASE")
+	}
+	rootCmd.Flags().Bool("audit-database", false, "Record transactions in the stac_server.audit_log table")
+	if err := viper.BindPFlag("audit.database", rootCmd.Flags().Lookup("audit-database")); err != nil {
+		log.Panic().Err(err).Msg("could not bind audit-database")
+	}
+
+	if err := viper.BindEnv("audit.file", "AUDIT_FILE"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUDIT_FILE")
+	}
+	rootCmd.Flags().String("audit-file", "", "Append transactions to this JSON lines file")
+	if err := viper.BindPFlag("audit.file", rootCmd.Flags().Lookup("audit-file")); err != nil {
+		log.Panic().Err(err).Msg("could not bind audit-file")
+	}
+
+	if err := viper.BindEnv("audit.diffs", "AUDIT_DIFFS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind AUDIT_DIFFS")
+	}
+	rootCmd.Flags().Bool("audit-diffs", false, "Include a JSON Patch of each audited change alongside the before/after hashes")
+	if err := viper.BindPFlag("audit.diffs", rootCmd.Flags().Lookup("audit-diffs")); err != nil {
+		log.Panic().Err(err).Msg("could not bind audit-diffs")
+	}
+
+	// search limits
+	if err := viper.BindEnv("limits.defaultLimit", "DEFAULT_LIMIT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind DEFAULT_LIMIT")
+	}
+	rootCmd.Flags().Int("default-limit", 10, "Page size of searches and item lists that don't set limit")
+	if err := viper.BindPFlag("limits.defaultLimit", rootCmd.Flags().Lookup("default-limit")); err != nil {
+		log.Panic().Err(err).Msg("could not bind default-limit")
+	}
+
+	if err := viper.BindEnv("limits.maxLimit", "MAX_LIMIT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_LIMIT")
+	}
+	rootCmd.Flags().Int("max-limit", 10_000, "Largest page size a search may request, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxLimit", rootCmd.Flags().Lookup("max-limit")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-limit")
+	}
+
+	if err := viper.BindEnv("limits.rejectOverLimit", "REJECT_OVER_LIMIT"); err != nil {
+		log.Panic().Err(err).Msg("could not bind REJECT_OVER_LIMIT")
+	}
+	rootCmd.Flags().Bool("reject-over-limit", false, "Reject limits above the maximum with 400 instead of lowering them")
+	if err := viper.BindPFlag("limits.rejectOverLimit", rootCmd.Flags().Lookup("reject-over-limit")); err != nil {
+		log.Panic().Err(err).Msg("could not bind reject-over-limit")
+	}
+
+	if err := viper.BindEnv("limits.maxIds", "MAX_IDS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_IDS")
+	}
+	rootCmd.Flags().Int("max-ids", 0, "Most ids a search may request, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxIds", rootCmd.Flags().Lookup("max-ids")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-ids")
+	}
+
+	if err := viper.BindEnv("limits.maxCollections", "MAX_COLLECTIONS"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_COLLECTIONS")
+	}
+	rootCmd.Flags().Int("max-collections", 0, "Most collections a search may request, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxCollections", rootCmd.Flags().Lookup("max-collections")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-collections")
+	}
+
+	if err := viper.BindEnv("limits.maxIntersectsVertices", "MAX_INTERSECTS_VERTICES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_INTERSECTS_VERTICES")
+	}
+	rootCmd.Flags().Int("max-intersects-vertices", 0, "Most vertices in an intersects geometry, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxIntersectsVertices", rootCmd.Flags().Lookup("max-intersects-vertices")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-intersects-vertices")
+	}
+
+	if err := viper.BindEnv("limits.maxFilterDepth", "MAX_FILTER_DEPTH"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_FILTER_DEPTH")
+	}
+	rootCmd.Flags().Int("max-filter-depth", 0, "Deepest nesting of operators in a filter, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxFilterDepth", rootCmd.Flags().Lookup("max-filter-depth")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-filter-depth")
+	}
+
+	if err := viper.BindEnv("limits.maxFilterNodes", "MAX_FILTER_NODES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_FILTER_NODES")
+	}
+	rootCmd.Flags().Int("max-filter-nodes", 0, "Most terms in a filter, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxFilterNodes", rootCmd.Flags().Lookup("max-filter-nodes")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-filter-nodes")
+	}
+
+	if err := viper.BindEnv("limits.maxBodyBytes", "MAX_BODY_BYTES"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_BODY_BYTES")
+	}
+	rootCmd.Flags().Int("max-body-bytes", 4<<20, "Largest request body in bytes, 0 for no maximum; item uploads with async=true or newline delimited items use --job-max-body-bytes and --ingest-max-body-bytes")
+	if err := viper.BindPFlag("limits.maxBodyBytes", rootCmd.Flags().Lookup("max-body-bytes")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-body-bytes")
+	}
+
+	if err := viper.BindEnv("limits.maxBboxArea", "MAX_BBOX_AREA"); err != nil {
+		log.Panic().Err(err).Msg("could not bind MAX_BBOX_AREA")
+	}
+	rootCmd.Flags().Float64("max-bbox-area", 0, "Largest bbox in square degrees for searches not restricted to collections or ids, 0 for no maximum")
+	if err := viper.BindPFlag("limits.maxBboxArea", rootCmd.Flags().Lookup("max-bbox-area")); err != nil {
+		log.Panic().Err(err).Msg("could not bind max-bbox-area")
+	}
+
 	// stac settings
 	if err := viper.BindEnv("stac.catalog.id", "STAC_CATALOG_ID"); err != nil {
 		log.Panic().Err(err).Msg("could not bind STAC_CATALOG_ID")
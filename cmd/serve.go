@@ -0,0 +1,212 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/ansrivas/fiberprometheus/v2"
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/handler"
+	"github.com/go-geospatial/go-stac-server/ingest"
+	"github.com/go-geospatial/go-stac-server/metrics"
+	"github.com/go-geospatial/go-stac-server/middleware"
+	"github.com/go-geospatial/go-stac-server/router"
+	"github.com/go-geospatial/go-stac-server/static"
+	"github.com/go-geospatial/go-stac-server/telemetry"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	json "github.com/goccy/go-json"
+)
+
+// serveCmd runs the STAC API server; running go-stac-server without a
+// subcommand does the same
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve STAC API v1.0.0",
+	Long:  `Serve a 1.0.0 compliant STAC api backed by a pgstac database`,
+	Run:   serve,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	// the root command also serves the API so both share the server flags
+	serveCmd.Flags().AddFlagSet(rootCmd.Flags())
+}
+
+func serve(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+
+	common.SetupLogging()
+	log.Info().Msg("initialized logging")
+
+	// admin routes change queryables and pgstac settings so they are never
+	// served to anonymous callers
+	if common.AdminEnabled() && !viper.GetBool("auth.enabled") {
+		log.Fatal().Msg("the admin API requires --auth-enabled")
+	}
+
+	// try connecting to the database early so we fail fast if
+	// we cannot connect to the database
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	// a read-only server may not be allowed to create tables; its server
+	// tables are created by a writable deployment or the migrate command
+	if common.ReadOnly() {
+		log.Info().Msg("read-only mode: write routes are disabled")
+	} else if err := database.EnsureSchema(ctx); err != nil {
+		log.Error().Err(err).Msg("could not create server tables")
+		os.Exit(66)
+	}
+
+	if err := auth.LoadJWKS(); err != nil {
+		log.Error().Err(err).Msg("could not load JWKS; bearer tokens will be rejected until it can be loaded")
+	}
+
+	// trace requests and database queries when an OTLP endpoint is configured
+	shutdownTracing, err := telemetry.Setup(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not set up tracing")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("could not flush traces")
+		}
+	}()
+
+	// process asynchronous ingest jobs in the background
+	if common.TransactionsEnabled() {
+		ingest.StartWorkers(ctx, viper.GetInt("jobs.workers"), viper.GetInt("jobs.batchSize"))
+	}
+
+	configBaseURL := viper.GetString("server.baseUrl")
+	if configBaseURL != "" {
+		log.Info().Str("BaseUrl", configBaseURL).Msg("using configured base URL")
+	}
+
+	if err := handler.LoadSearchLimits(); err != nil {
+		log.Fatal().Err(err).Msg("could not parse limits.collections")
+	}
+
+	if err := auth.ConfigureProxies(viper.GetString("server.proxyHeader"), viper.GetStringSlice("server.trustedProxies")); err != nil {
+		log.Fatal().Err(err).Msg("invalid proxy configuration")
+	}
+
+	// Create new Fiber instance
+	bodyLimit := viper.GetInt("limits.maxBodyBytes")
+	app := fiber.New(fiber.Config{
+		JSONEncoder: json.Marshal,
+		JSONDecoder: json.Unmarshal,
+		BodyLimit:   bodyLimit,
+		// bodies larger than the body limit are streamed so newline
+		// delimited item uploads don't need to be held in memory
+		StreamRequestBody: true,
+	})
+
+	// shutdown cleanly on interrupt
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		sig := <-c // block until signal is read
+		fmt.Printf("Received signal: '%s'; shutting down...\n", sig.String())
+		err := app.ShutdownWithTimeout(time.Second * 5)
+		if err != nil {
+			log.Fatal().Err(err).Msg("app shutdown failed")
+		}
+	}()
+
+	// Configure CORS, with separate policies for reads and writes
+	corsHandler, err := middleware.CORS()
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid CORS configuration")
+	}
+	app.Use(corsHandler)
+
+	// streamed request bodies are still held to the body limit everywhere
+	// but on newline delimited item uploads
+	app.Use(middleware.BodyLimit(bodyLimit))
+
+	// compression
+	app.Use(compress.New(compress.Config{
+		Level: compress.LevelBestSpeed, // 1
+	}))
+
+	// start a span per request, continuing incoming traceparent headers
+	app.Use(telemetry.Middleware())
+
+	// Setup logging middleware
+	app.Use(middleware.NewLogger())
+
+	// Add timing headers
+	app.Use(middleware.Timer())
+
+	// identify the caller so rate limits apply per API key, token subject
+	// or IP address; addresses presenting too many invalid credentials are
+	// turned away before their credentials are checked
+	app.Use(middleware.AuthFailureLimit())
+	app.Use(auth.Authenticate())
+	app.Use(middleware.RateLimit())
+
+	prometheus := fiberprometheus.New("go-stac-server")
+	prometheus.RegisterAt(app, "/api/stac/v1/metrics")
+	app.Use(prometheus.Middleware)
+
+	// search, ingest and query metrics register themselves; the pool's
+	// statistics are read on every scrape
+	if err := metrics.RegisterPool(pool); err != nil {
+		log.Error().Err(err).Msg("could not register database pool metrics")
+	}
+
+	// Setup routes
+	router.SetupRoutes(app)
+
+	// configure static serves
+	static.InitStaticFiles(app)
+
+	addr := ":" + viper.GetString("server.port")
+	if !common.TLSEnabled() {
+		if err := app.Listen(addr); err != nil {
+			log.Fatal().Err(err).Msg("app.Listen returned an error")
+		}
+		return
+	}
+
+	// serve HTTPS, reloading certificates without dropping connections
+	tlsConfig, err := common.NewTLSConfig(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid TLS configuration")
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal().Err(err).Str("addr", addr).Msg("could not listen")
+	}
+	if err := app.Listener(tls.NewListener(ln, tlsConfig)); err != nil {
+		log.Fatal().Err(err).Msg("app.Listener returned an error")
+	}
+}
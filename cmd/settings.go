@@ -0,0 +1,167 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"text/tabwriter"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// settingsCmd views and changes pgstac settings
+var settingsCmd = &cobra.Command{
+	Use:   "settings",
+	Short: "View and change pgstac settings",
+	Long: `View and change the pgstac_settings rows that control pgstac behavior. Changes are validated
+and recorded in stac_server.settings_audit.`,
+}
+
+var settingsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List pgstac settings",
+	Args:  cobra.NoArgs,
+	Run:   runSettingsList,
+}
+
+var settingsSetCmd = &cobra.Command{
+	Use:   "set <name> <value>",
+	Short: "Change a pgstac setting",
+	Args:  cobra.ExactArgs(2),
+	Run:   runSettingsSet,
+}
+
+var settingsHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent setting changes",
+	Args:  cobra.NoArgs,
+	Run:   runSettingsHistory,
+}
+
+var settingsPartitionTruncCmd = &cobra.Command{
+	Use:   "partition-trunc <collection> <year|month|none>",
+	Short: "Change how a collection's items are partitioned",
+	Args:  cobra.ExactArgs(2),
+	Run:   runSettingsPartitionTrunc,
+}
+
+func init() {
+	rootCmd.AddCommand(settingsCmd)
+	settingsCmd.AddCommand(settingsListCmd, settingsSetCmd, settingsHistoryCmd, settingsPartitionTruncCmd)
+
+	settingsHistoryCmd.Flags().String("name", "", "Only show changes to this setting")
+	settingsHistoryCmd.Flags().Int("limit", 50, "Number of changes to show")
+}
+
+// cliPrincipal identifies the operator in audit records
+func cliPrincipal() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+func runSettingsList(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	settings, err := database.GetSettings(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not list settings")
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE")
+	for _, setting := range settings {
+		fmt.Fprintf(w, "%s\t%s\n", setting.Name, setting.Value)
+	}
+	w.Flush()
+}
+
+func runSettingsSet(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	if err := database.ValidateSetting(args[0], args[1]); err != nil {
+		log.Error().Err(err).Msg("invalid setting")
+		os.Exit(1)
+	}
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	change, err := database.SetSetting(ctx, args[0], args[1], cliPrincipal())
+	if err != nil {
+		log.Error().Err(err).Str("name", args[0]).Msg("could not change setting")
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %s -> %s\n", change.Name, valueOr(change.OldValue, "(unset)"), valueOr(change.NewValue, "(unset)"))
+}
+
+func runSettingsHistory(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	name, _ := cmd.Flags().GetString("name")
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	changes, err := database.SettingHistory(ctx, name, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("could not read setting history")
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGED AT\tNAME\tOLD\tNEW\tCHANGED BY")
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", change.ChangedAt.Format("2006-01-02 15:04:05Z07:00"), change.Name,
+			valueOr(change.OldValue, "-"), valueOr(change.NewValue, "-"), change.ChangedBy)
+	}
+	w.Flush()
+}
+
+func runSettingsPartitionTrunc(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	value := args[1]
+	if value == "none" {
+		value = ""
+	}
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	change, err := database.SetPartitionTrunc(ctx, args[0], value, cliPrincipal())
+	if err != nil {
+		log.Error().Err(err).Str("collection", args[0]).Msg("could not change partition_trunc")
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %s -> %s\n", change.Name, valueOr(change.OldValue, "none"), valueOr(change.NewValue, "none"))
+}
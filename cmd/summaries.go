@@ -0,0 +1,102 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// summariesCmd groups operations on collection summaries
+var summariesCmd = &cobra.Command{
+	Use:   "summaries",
+	Short: "Manage collection summaries",
+}
+
+var summariesRecomputeCmd = &cobra.Command{
+	Use:   "recompute <collection>",
+	Short: "Rebuild a collection's summaries and item_assets from its items",
+	Long: `Scan the items of a collection and rebuild its summaries: ranges for numeric properties and
+value lists for properties with few distinct values. item_assets are inferred from the asset
+keys, media types and roles. The result is printed as JSON; --dry-run leaves the collection
+unchanged. A --sample scan can only be previewed with --dry-run, and a collection without items
+is left unchanged. As with the HTTP endpoint, the new summaries are registered as queryables
+unless queryables.fromSummaries is off.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSummariesRecompute,
+}
+
+func init() {
+	rootCmd.AddCommand(summariesCmd)
+	summariesCmd.AddCommand(summariesRecomputeCmd)
+
+	summariesRecomputeCmd.Flags().Bool("dry-run", false, "Print the summaries without updating the collection")
+	summariesRecomputeCmd.Flags().Int("sample", 0, "Maximum number of items to scan with --dry-run, 0 for all items")
+	summariesRecomputeCmd.Flags().Int("max-enum", 20, "Largest number of distinct values summarized as a list")
+}
+
+func runSummariesRecompute(cmd *cobra.Command, args []string) {
+	ctx := context.Background()
+	common.SetupLogging()
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	sample, _ := cmd.Flags().GetInt("sample")
+	maxEnum, _ := cmd.Flags().GetInt("max-enum")
+
+	pool := database.GetInstance(ctx)
+	defer pool.Close()
+
+	computed, err := stac.RecomputeSummaries(ctx, args[0], stac.DiscoverOptions{
+		SampleSize:    sample,
+		MaxEnumValues: maxEnum,
+	}, dryRun, viper.GetBool("queryables.fromSummaries"))
+	switch {
+	case errors.Is(err, stac.ErrSummariesSampled):
+		fmt.Fprintln(os.Stderr, "--sample can only be used with --dry-run; summaries of a sample would replace those of every item")
+		os.Exit(1)
+	case err != nil && computed == nil:
+		log.Error().Err(err).Str("collection", args[0]).Msg("could not scan collection items")
+		os.Exit(1)
+	}
+
+	out, jsonErr := json.MarshalIndent(computed, "", "  ")
+	if jsonErr != nil {
+		log.Error().Err(jsonErr).Msg("could not serialize summaries")
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	switch {
+	case errors.Is(err, stac.ErrNoItemsScanned):
+		fmt.Fprintf(os.Stderr, "%s has no items; its summaries and item_assets were left unchanged\n", args[0])
+		os.Exit(1)
+	case err != nil:
+		log.Error().Err(err).Str("collection", args[0]).Msg("could not update collection summaries")
+		os.Exit(1)
+	case !dryRun:
+		fmt.Printf("updated summaries of %s from %d items\n", args[0], computed.ItemsScanned)
+	}
+}
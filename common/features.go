@@ -0,0 +1,36 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import "github.com/spf13/viper"
+
+// ReadOnly reports whether the server was started with --read-only, which
+// turns off every route that writes to the database
+func ReadOnly() bool {
+	return viper.GetBool("server.readOnly")
+}
+
+// TransactionsEnabled reports whether the transaction extension (and
+// asynchronous ingest jobs) are served
+func TransactionsEnabled() bool {
+	return !ReadOnly() && viper.GetBool("extensions.transactions")
+}
+
+// AdminEnabled reports whether queryables management, pgstac settings and
+// summary recomputation are served
+func AdminEnabled() bool {
+	return !ReadOnly() && viper.GetBool("extensions.admin")
+}
@@ -87,4 +87,6 @@ func SetupLogging() {
 	// setup stack marshaler
 	//nolint:reassign
 	zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
+	// log.Ctx(ctx) falls back to the global logger outside traced requests
+	zerolog.DefaultContextLogger = &log.Logger
 }
@@ -0,0 +1,223 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// TLSEnabled reports whether the server is configured to serve HTTPS
+func TLSEnabled() bool {
+	return viper.GetString("server.tls.cert") != ""
+}
+
+// certReloader serves the certificate, key and client CA bundle from disk
+// and swaps in new ones when the files change. Connections already
+// established keep the configuration they were accepted with.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	base     *tls.Config
+	current  atomic.Pointer[tls.Config]
+	modTimes map[string]time.Time
+}
+
+// NewTLSConfig builds the TLS configuration of the server from the
+// server.tls.* settings. Certificates are reloaded every
+// server.tls.reloadInterval and on SIGHUP until ctx is done.
+func NewTLSConfig(ctx context.Context) (*tls.Config, error) {
+	base := &tls.Config{}
+
+	switch viper.GetString("server.tls.minVersion") {
+	case "", "1.2":
+		base.MinVersion = tls.VersionTLS12
+	case "1.3":
+		base.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS minimum version %q; use 1.2 or 1.3", viper.GetString("server.tls.minVersion"))
+	}
+
+	ciphers := viper.GetStringSlice("server.tls.cipherSuites")
+	if len(ciphers) > 0 {
+		suites, err := cipherSuites(ciphers)
+		if err != nil {
+			return nil, err
+		}
+		base.CipherSuites = suites
+		if base.MinVersion == tls.VersionTLS13 {
+			log.Warn().Msg("TLS 1.3 cipher suites are not configurable; server.tls.cipherSuites only applies to TLS 1.2")
+		}
+	}
+
+	reloader := &certReloader{
+		certFile: viper.GetString("server.tls.cert"),
+		keyFile:  viper.GetString("server.tls.key"),
+		caFile:   viper.GetString("server.tls.clientCa"),
+		base:     base,
+		modTimes: make(map[string]time.Time),
+	}
+
+	if reloader.caFile != "" {
+		switch viper.GetString("server.tls.clientAuth") {
+		case "", "require":
+			base.ClientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unsupported client auth %q; use require or optional", viper.GetString("server.tls.clientAuth"))
+		}
+	}
+
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	go reloader.watch(ctx, viper.GetDuration("server.tls.reloadInterval"))
+
+	return &tls.Config{
+		MinVersion: base.MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return reloader.current.Load(), nil
+		},
+	}, nil
+}
+
+// Reload reads the certificate, key and client CA bundle from disk
+func (r *certReloader) Reload() error {
+	if r.keyFile == "" {
+		return errors.New("server.tls.key is required with server.tls.cert")
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("could not load TLS certificate: %w", err)
+	}
+
+	config := r.base.Clone()
+	config.Certificates = []tls.Certificate{cert}
+
+	if r.caFile != "" {
+		bundle, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("could not read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("no certificates found in client CA bundle %s", r.caFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	r.current.Store(config)
+	r.modTimes = r.fileModTimes()
+	log.Info().Str("cert", r.certFile).Str("clientCa", r.caFile).Msg("loaded TLS certificates")
+	return nil
+}
+
+// watch reloads the certificates on SIGHUP and whenever one of the files
+// has been modified. A failed reload keeps serving the previous certificates.
+func (r *certReloader) watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info().Msg("received SIGHUP; reloading TLS certificates")
+		case <-tick:
+			if !r.changed() {
+				continue
+			}
+			log.Info().Msg("TLS certificate files changed; reloading")
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Error().Err(err).Msg("could not reload TLS certificates; keeping the current ones")
+		}
+	}
+}
+
+// changed reports whether any file was modified since the last reload
+func (r *certReloader) changed() bool {
+	for file, modTime := range r.fileModTimes() {
+		if !modTime.Equal(r.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) fileModTimes() map[string]time.Time {
+	modTimes := make(map[string]time.Time, 3)
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		// Stat follows symlinks, so Kubernetes secret updates are noticed
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// cipherSuites maps cipher suite names, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, to their ids
+func cipherSuites(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		for _, part := range strings.Split(name, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			id, ok := known[part]
+			if !ok {
+				return nil, fmt.Errorf("unknown or insecure cipher suite %q", part)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -19,9 +19,9 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"regexp"
 	"sync"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -38,23 +38,70 @@ var QueryErrorCode = "DatabaseQueryError"
 
 func GetInstance(ctx context.Context) *pgxpool.Pool {
 	once.Do(func() {
-		// mask DSN password for logging
-		re := regexp.MustCompile(`^postgresql://(\w+)(:(password))?(.*)`)
-		dsnMasked := viper.GetString("database.dsn")
-		parts := re.FindStringSubmatch(viper.GetString("database.dsn"))
-		if len(parts) == 0 {
-			log.Warn().Msg("DSN format not recognized, password masking disabled")
-		} else {
-			dsnMasked = fmt.Sprintf("postgresql://%s:***%s", parts[1], parts[4])
+		log.Info().Str("DSN", RedactDSN(viper.GetString("database.dsn"))).Msg("initializing database pool connection")
+		config, err := poolConfig()
+		if err != nil {
+			log.Error().Err(err).Msg("invalid database configuration")
+			os.Exit(66)
+		}
+
+		// queries made while handling a traced request become child spans
+		config.ConnConfig.Tracer = queryTracer{}
+
+		// per-connection pgstac setting overrides take precedence over pgstac_settings
+		overrides := viper.GetStringMapString("database.settings")
+		for name, value := range overrides {
+			if err := ValidateSetting(name, value); err != nil {
+				log.Error().Err(err).Msg("invalid database.settings override")
+				os.Exit(66)
+			}
+		}
+
+		// in read-only mode searches don't record themselves in pgstac.searches
+		// and may run as a role that can only read
+		readOnly := viper.GetBool("server.readOnly")
+		readOnlyRole := viper.GetString("database.readOnlyRole")
+		if readOnly {
+			overrides["readonly"] = "true"
 		}
 
-		log.Info().Str("DSN", dsnMasked).Msg("initializing database pool connection")
-		var err error
-		instance, err = pgxpool.New(ctx, viper.GetString("database.dsn"))
+		if len(overrides) > 0 || (readOnly && readOnlyRole != "") {
+			config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+				if readOnly && readOnlyRole != "" {
+					if _, err := conn.Exec(ctx, "SET ROLE "+pgx.Identifier{readOnlyRole}.Sanitize()); err != nil {
+						return fmt.Errorf("could not switch to read-only role %s: %w", readOnlyRole, err)
+					}
+				}
+				for name, value := range overrides {
+					if _, err := conn.Exec(ctx, "SELECT set_config('pgstac.' || $1, $2, false)", name, value); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		}
+
+		instance, err = pgxpool.NewWithConfig(ctx, config)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to create a new database pool")
 			os.Exit(66)
 		}
+
+		// the database may still be starting, e.g. when deployed together
+		if err := connect(ctx, instance); err != nil {
+			log.Error().Err(err).Msg("could not connect to database")
+			os.Exit(66)
+		}
+		log.Info().
+			Str("host", config.ConnConfig.Host).
+			Str("database", config.ConnConfig.Database).
+			Int32("maxConns", config.MaxConns).
+			Msg("connected to database")
+
+		if err := checkPgstacVersion(ctx, viper.GetString("database.versionCheck")); err != nil {
+			log.Error().Err(err).Msg("unsupported pgstac version")
+			os.Exit(69)
+		}
 	})
 	return instance
 }
@@ -0,0 +1,230 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed migrations
+var pgstacFiles embed.FS
+
+var ErrNoMigrations = errors.New("no pgstac migrations found")
+
+var baseMigrationRe = regexp.MustCompile(`^pgstac\.(\d+\.\d+\.\d+)\.sql$`)
+var incrementalMigrationRe = regexp.MustCompile(`^pgstac\.(\d+\.\d+\.\d+)-(\d+\.\d+\.\d+)\.sql$`)
+
+// migrationLock is the advisory lock held while migrations run so two
+// servers cannot migrate the same database at once
+var migrationLock = 5_387_417_264
+
+type pgstacMigration struct {
+	name string
+	from string
+	to   string
+}
+
+// execer runs the statements of a migration; pgx.Tx satisfies it
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// PgstacMigrations returns the pgstac migration files in dir, or the files
+// embedded in this binary from database/migrations when dir is empty. dir is
+// typically the migrations directory of a pypgstac install.
+func PgstacMigrations(dir string) (fs.FS, error) {
+	if dir != "" {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, err
+		}
+		return os.DirFS(dir), nil
+	}
+	return fs.Sub(pgstacFiles, "migrations")
+}
+
+// PgstacVersions lists the pgstac versions that can be installed from the
+// migration files in fsys
+func PgstacVersions(fsys fs.FS) []string {
+	versions := make([]string, 0)
+	for _, m := range pgstacMigrationFiles(fsys) {
+		if m.from == "" {
+			versions = append(versions, m.to)
+		}
+	}
+	return versions
+}
+
+// MigratePgstac installs or upgrades pgstac to target using the migration
+// files in fsys. An empty target selects the newest version available. The
+// names of the files applied are returned; with dryRun they are only planned.
+// The files are applied in a single transaction so a failed migration leaves
+// the installed version unchanged.
+func MigratePgstac(ctx context.Context, fsys fs.FS, target string, dryRun bool) ([]string, error) {
+	migrations := pgstacMigrationFiles(fsys)
+	if target == "" {
+		target = latestVersion(migrations)
+	}
+	if target == "" {
+		return nil, ErrNoMigrations
+	}
+
+	pool := GetInstance(ctx)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if !dryRun {
+		if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+			return nil, err
+		}
+	}
+
+	// read the version after taking the lock so a concurrent migration
+	// that just finished is taken into account
+	current, err := PgstacVersion(ctx)
+	if err != nil && !errors.Is(err, ErrPgstacMissing) {
+		return nil, err
+	}
+
+	plan, err := planPgstacMigrations(migrations, current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(plan))
+	for _, m := range plan {
+		names = append(names, m.name)
+	}
+	if dryRun || len(plan) == 0 {
+		return names, nil
+	}
+
+	if err := applyPgstacMigrations(ctx, tx, fsys, plan); err != nil {
+		return nil, err
+	}
+	return names, tx.Commit(ctx)
+}
+
+// applyPgstacMigrations runs each planned file in order and records it in
+// stac_server.migrations with the version it migrates to
+func applyPgstacMigrations(ctx context.Context, db execer, fsys fs.FS, plan []pgstacMigration) error {
+	for _, m := range plan {
+		sql, err := fs.ReadFile(fsys, m.name)
+		if err != nil {
+			return err
+		}
+
+		log.Info().Str("file", m.name).Str("from", m.from).Str("to", m.to).Msg("applying pgstac migration")
+		if _, err := db.Exec(ctx, string(sql)); err != nil {
+			log.Error().Err(err).Str("file", m.name).Msg("pgstac migration failed")
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		if _, err := db.Exec(ctx, `INSERT INTO stac_server.migrations (name, version) VALUES ($1, $2)
+			ON CONFLICT (name) DO UPDATE SET version = EXCLUDED.version, applied_at = now()`, "pgstac/"+m.name, m.to); err != nil {
+			log.Error().Err(err).Str("file", m.name).Msg("failed to record pgstac migration")
+			return err
+		}
+	}
+	return nil
+}
+
+func pgstacMigrationFiles(fsys fs.FS) []pgstacMigration {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil
+	}
+
+	migrations := make([]pgstacMigration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if groups := baseMigrationRe.FindStringSubmatch(name); groups != nil {
+			migrations = append(migrations, pgstacMigration{name: name, to: groups[1]})
+		} else if groups := incrementalMigrationRe.FindStringSubmatch(name); groups != nil {
+			migrations = append(migrations, pgstacMigration{name: name, from: groups[1], to: groups[2]})
+		}
+	}
+	return migrations
+}
+
+func latestVersion(migrations []pgstacMigration) string {
+	latest := ""
+	for _, m := range migrations {
+		if latest == "" || compareVersions(m.to, latest) > 0 {
+			latest = m.to
+		}
+	}
+	return latest
+}
+
+// planPgstacMigrations returns the files to apply to get from current to
+// target. A fresh install uses the newest base file not newer than target and
+// then follows incremental files, always taking the largest step available.
+func planPgstacMigrations(migrations []pgstacMigration, current string, target string) ([]pgstacMigration, error) {
+	plan := make([]pgstacMigration, 0)
+
+	if current == "" {
+		var base *pgstacMigration
+		for idx, m := range migrations {
+			if m.from == "" && compareVersions(m.to, target) <= 0 && (base == nil || compareVersions(m.to, base.to) > 0) {
+				base = &migrations[idx]
+			}
+		}
+		if base == nil {
+			return nil, fmt.Errorf("no pgstac base migration for version %s", target)
+		}
+		plan = append(plan, *base)
+		current = base.to
+	}
+
+	switch cmp := compareVersions(current, target); {
+	case cmp > 0:
+		return nil, fmt.Errorf("installed pgstac %s is newer than target %s", current, target)
+	case cmp == 0:
+		return plan, nil
+	}
+
+	for compareVersions(current, target) < 0 {
+		var step *pgstacMigration
+		for idx, m := range migrations {
+			if m.from == "" || compareVersions(m.from, current) != 0 || compareVersions(m.to, target) > 0 {
+				continue
+			}
+			if step == nil || compareVersions(m.to, step.to) > 0 {
+				step = &migrations[idx]
+			}
+		}
+		if step == nil {
+			return nil, fmt.Errorf("no pgstac migration from %s towards %s", current, target)
+		}
+		plan = append(plan, *step)
+		current = step.to
+	}
+
+	return plan, nil
+}
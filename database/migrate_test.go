@@ -0,0 +1,159 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// testMigrations mirrors the layout of pypgstac's migrations directory
+var testMigrations = fstest.MapFS{
+	"pgstac.0.7.0.sql":       {Data: []byte("-- install 0.7.0")},
+	"pgstac.0.8.1.sql":       {Data: []byte("-- install 0.8.1")},
+	"pgstac.0.7.0-0.7.1.sql": {Data: []byte("-- 0.7.0 to 0.7.1")},
+	"pgstac.0.7.1-0.8.0.sql": {Data: []byte("-- 0.7.1 to 0.8.0")},
+	"pgstac.0.7.0-0.8.0.sql": {Data: []byte("-- 0.7.0 to 0.8.0")},
+	"pgstac.0.8.0-0.8.1.sql": {Data: []byte("-- 0.8.0 to 0.8.1")},
+	"pgstac.unreleased.sql":  {Data: []byte("-- not a release")},
+	"README.md":              {Data: []byte("# pgstac migrations")},
+}
+
+func planNames(plan []pgstacMigration) []string {
+	names := make([]string, 0, len(plan))
+	for _, m := range plan {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+func TestPgstacVersions(t *testing.T) {
+	got := PgstacVersions(testMigrations)
+	want := []string{"0.7.0", "0.8.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PgstacVersions() = %v, want %v", got, want)
+	}
+}
+
+func TestPlanPgstacMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		target  string
+		want    []string
+		wantErr bool
+	}{
+		{"fresh install of a base version", "", "0.8.1", []string{"pgstac.0.8.1.sql"}, false},
+		{"fresh install between base versions", "", "0.8.0", []string{"pgstac.0.7.0.sql", "pgstac.0.7.0-0.8.0.sql"}, false},
+		{"upgrade takes the largest step", "0.7.0", "0.8.1", []string{"pgstac.0.7.0-0.8.0.sql", "pgstac.0.8.0-0.8.1.sql"}, false},
+		{"upgrade stops at the target", "0.7.0", "0.7.1", []string{"pgstac.0.7.0-0.7.1.sql"}, false},
+		{"upgrade from an intermediate version", "0.7.1", "0.8.1", []string{"pgstac.0.7.1-0.8.0.sql", "pgstac.0.8.0-0.8.1.sql"}, false},
+		{"up to date", "0.8.1", "0.8.1", []string{}, false},
+		{"dev suffix of the installed version", "0.8.1-dev", "0.8.1", []string{}, false},
+		{"installed version is newer", "0.8.1", "0.8.0", nil, true},
+		{"no base old enough", "", "0.6.0", nil, true},
+		{"no path to the target", "0.7.2", "0.8.1", nil, true},
+	}
+	migrations := pgstacMigrationFiles(testMigrations)
+	for _, tt := range tests {
+		plan, err := planPgstacMigrations(migrations, tt.current, tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: planPgstacMigrations(%q, %q) error = %v, wantErr %v", tt.name, tt.current, tt.target, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got := planNames(plan); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: planPgstacMigrations(%q, %q) = %v, want %v", tt.name, tt.current, tt.target, got, tt.want)
+		}
+	}
+}
+
+// recordingExecer stands in for the migration transaction
+type recordingExecer struct {
+	statements []string
+	arguments  [][]any
+	failOn     string
+}
+
+func (r *recordingExecer) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	if r.failOn != "" && sql == r.failOn {
+		return pgconn.CommandTag{}, errors.New("syntax error")
+	}
+	r.statements = append(r.statements, sql)
+	r.arguments = append(r.arguments, arguments)
+	return pgconn.CommandTag{}, nil
+}
+
+func TestApplyPgstacMigrations(t *testing.T) {
+	plan, err := planPgstacMigrations(pgstacMigrationFiles(testMigrations), "", "0.8.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &recordingExecer{}
+	if err := applyPgstacMigrations(context.Background(), db, testMigrations, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	// each file is followed by the row recording it
+	want := []struct {
+		sql     string
+		name    string
+		version string
+	}{
+		{"-- install 0.7.0", "pgstac/pgstac.0.7.0.sql", "0.7.0"},
+		{"-- 0.7.0 to 0.8.0", "pgstac/pgstac.0.7.0-0.8.0.sql", "0.8.0"},
+	}
+	if len(db.statements) != 2*len(want) {
+		t.Fatalf("applyPgstacMigrations executed %d statements, want %d: %v", len(db.statements), 2*len(want), db.statements)
+	}
+	for idx, w := range want {
+		if got := db.statements[2*idx]; got != w.sql {
+			t.Errorf("statement %d = %q, want %q", 2*idx, got, w.sql)
+		}
+		record := db.statements[2*idx+1]
+		if !strings.Contains(record, "stac_server.migrations") {
+			t.Errorf("statement %d = %q, want an insert into stac_server.migrations", 2*idx+1, record)
+		}
+		if got := db.arguments[2*idx+1]; !reflect.DeepEqual(got, []any{w.name, w.version}) {
+			t.Errorf("statement %d arguments = %v, want [%s %s]", 2*idx+1, got, w.name, w.version)
+		}
+	}
+}
+
+func TestApplyPgstacMigrationsStopsOnFailure(t *testing.T) {
+	plan, err := planPgstacMigrations(pgstacMigrationFiles(testMigrations), "0.7.0", "0.8.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &recordingExecer{failOn: "-- 0.7.0 to 0.8.0"}
+	err = applyPgstacMigrations(context.Background(), db, testMigrations, plan)
+	if err == nil || !strings.Contains(err.Error(), "pgstac.0.7.0-0.8.0.sql") {
+		t.Fatalf("applyPgstacMigrations() error = %v, want the failing file named", err)
+	}
+	if len(db.statements) != 0 {
+		t.Errorf("applyPgstacMigrations() kept going after a failed file: %v", db.statements)
+	}
+}
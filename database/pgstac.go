@@ -0,0 +1,107 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// MinPgstacVersion and MaxPgstacVersion bound the pgstac versions this server
+// is known to work with. MaxPgstacVersion is exclusive.
+var MinPgstacVersion = "0.7.0"
+var MaxPgstacVersion = "0.9.0"
+
+var ErrPgstacMissing = errors.New("pgstac is not installed")
+
+// PgstacVersion returns the version of pgstac installed in the database
+func PgstacVersion(ctx context.Context) (string, error) {
+	var version string
+	pool := GetInstance(ctx)
+	if err := pool.QueryRow(ctx, "SELECT pgstac.get_version()").Scan(&version); err != nil {
+		// the pgstac schema or get_version function does not exist
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (pgErr.Code == "3F000" || pgErr.Code == "42883") {
+			return "", ErrPgstacMissing
+		}
+		return "", err
+	}
+	return version, nil
+}
+
+// IsSupportedPgstac reports whether version falls in the supported range
+func IsSupportedPgstac(version string) bool {
+	return compareVersions(version, MinPgstacVersion) >= 0 && compareVersions(version, MaxPgstacVersion) < 0
+}
+
+// checkPgstacVersion verifies the installed pgstac version when the pool is
+// created. database.versionCheck is one of strict, warn (default) or off.
+func checkPgstacVersion(ctx context.Context, mode string) error {
+	if mode == "off" {
+		return nil
+	}
+
+	version, err := PgstacVersion(ctx)
+	switch {
+	case errors.Is(err, ErrPgstacMissing):
+		err = fmt.Errorf("%w; run go-stac-server migrate", err)
+	case err != nil:
+		err = fmt.Errorf("could not determine pgstac version: %w", err)
+	case !IsSupportedPgstac(version):
+		err = fmt.Errorf("pgstac %s is not supported; supported versions are >= %s and < %s", version, MinPgstacVersion, MaxPgstacVersion)
+	default:
+		log.Info().Str("version", version).Msg("pgstac version supported")
+		return nil
+	}
+
+	if mode == "strict" {
+		return err
+	}
+	log.Warn().Err(err).Msg("pgstac version check failed")
+	return nil
+}
+
+// compareVersions compares dotted version strings numerically ignoring any
+// pre-release suffix such as -dev
+func compareVersions(a string, b string) int {
+	aParts := versionParts(a)
+	bParts := versionParts(b)
+	for idx := 0; idx < 3; idx++ {
+		if aParts[idx] != bParts[idx] {
+			if aParts[idx] < bParts[idx] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) [3]int {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	version, _, _ = strings.Cut(version, "-")
+	for idx, part := range strings.SplitN(version, ".", 3) {
+		parts[idx], _ = strconv.Atoi(part)
+	}
+	return parts
+}
@@ -0,0 +1,199 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// queryExecModes maps database.statementCacheMode to pgx query exec modes.
+// PgBouncer in transaction pooling mode needs exec or simple_protocol since
+// prepared statements don't survive moving between server connections.
+var queryExecModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// keywordPassword matches the password of a keyword/value connection string,
+// quoted or not
+var keywordPassword = regexp.MustCompile(`(?i)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// urlPassword matches the password of a connection URL that can't be parsed
+var urlPassword = regexp.MustCompile(`^(postgres(?:ql)?://[^:@/]*:)([^/?#]*)@`)
+
+// RedactDSN hides the password of a URL or keyword/value connection string
+// so it can be logged
+func RedactDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return urlPassword.ReplaceAllString(dsn, "${1}xxxxx@")
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+		query := u.Query()
+		if query.Has("password") {
+			query.Set("password", "xxxxx")
+			u.RawQuery = query.Encode()
+		}
+		return u.String()
+	}
+	return keywordPassword.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+// poolConfig builds the pool configuration from database.dsn, the separate
+// connection settings that override its parts and the pool tuning settings
+func poolConfig() (*pgxpool.Config, error) {
+	dsn := viper.GetString("database.dsn")
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		// pgx hides the password in its own message but not in the URL
+		// parsing error it wraps
+		message := err.Error()
+		if match := urlPassword.FindStringSubmatch(dsn); match != nil && match[2] != "" {
+			message = strings.ReplaceAll(message, match[2], "xxxxx")
+		}
+		return nil, errors.New(message)
+	}
+
+	conn := config.ConnConfig
+	if host := viper.GetString("database.host"); host != "" {
+		conn.Host = host
+		conn.Fallbacks = nil
+	}
+	if port := viper.GetUint32("database.port"); port != 0 {
+		conn.Port = uint16(port)
+	}
+	if user := viper.GetString("database.user"); user != "" {
+		conn.User = user
+	}
+	if name := viper.GetString("database.name"); name != "" {
+		conn.Database = name
+	}
+	if password := viper.GetString("database.password"); password != "" {
+		conn.Password = password
+	}
+
+	// the password file is read again for every new connection so rotated
+	// secrets are picked up without a restart
+	if passwordFile := viper.GetString("database.passwordFile"); passwordFile != "" {
+		if _, err := readPasswordFile(passwordFile); err != nil {
+			return nil, err
+		}
+		config.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			password, err := readPasswordFile(passwordFile)
+			if err != nil {
+				return err
+			}
+			cc.Password = password
+			return nil
+		}
+	}
+
+	if maxConns := viper.GetInt32("database.maxConns"); maxConns > 0 {
+		config.MaxConns = maxConns
+	}
+	if minConns := viper.GetInt32("database.minConns"); minConns > 0 {
+		config.MinConns = minConns
+	}
+	if config.MinConns > config.MaxConns {
+		return nil, fmt.Errorf("database.minConns (%d) is larger than database.maxConns (%d)", config.MinConns, config.MaxConns)
+	}
+	if lifetime := viper.GetDuration("database.maxConnLifetime"); lifetime > 0 {
+		config.MaxConnLifetime = lifetime
+	}
+	if idleTime := viper.GetDuration("database.maxConnIdleTime"); idleTime > 0 {
+		config.MaxConnIdleTime = idleTime
+	}
+	if period := viper.GetDuration("database.healthCheckPeriod"); period > 0 {
+		config.HealthCheckPeriod = period
+	}
+
+	if mode := viper.GetString("database.statementCacheMode"); mode != "" {
+		execMode, ok := queryExecModes[strings.ToLower(mode)]
+		if !ok {
+			return nil, fmt.Errorf("unknown database.statementCacheMode '%s'; use cache_statement, cache_describe, describe_exec, exec or simple_protocol", mode)
+		}
+		conn.DefaultQueryExecMode = execMode
+	}
+
+	return config, nil
+}
+
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read database password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// connect pings the database until it answers, waiting exponentially longer
+// between attempts from database.connectBackoff up to
+// database.connectMaxBackoff, for at most database.connectRetries retries
+func connect(ctx context.Context, pool *pgxpool.Pool) error {
+	retries := viper.GetInt("database.connectRetries")
+	backoff := viper.GetDuration("database.connectBackoff")
+	maxBackoff := viper.GetDuration("database.connectMaxBackoff")
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := pool.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return err
+		}
+
+		// jitter keeps replicas started together from retrying in lockstep
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		log.Warn().Err(err).Int("attempt", attempt+1).Int("retries", retries).Dur("wait", wait).Msg("could not connect to database; retrying")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		want string
+	}{
+		{"url", "postgres://user:secret@db:5432/postgis", "postgres://user:xxxxx@db:5432/postgis"},
+		{"postgresql scheme", "postgresql://user:secret@db/postgis?sslmode=require", "postgresql://user:xxxxx@db/postgis?sslmode=require"},
+		{"url without password", "postgres://user@db/postgis", "postgres://user@db/postgis"},
+		{"url without user", "postgres://db/postgis", "postgres://db/postgis"},
+		{"password query parameter", "postgres://user@db/postgis?password=secret", "postgres://user@db/postgis?password=xxxxx"},
+		{"unparseable url", "postgres://user:se%zzcret@db/postgis", "postgres://user:xxxxx@db/postgis"},
+		{"keyword/value", "host=db user=user password=secret dbname=postgis", "host=db user=user password=xxxxx dbname=postgis"},
+		{"quoted keyword/value", "host=db password='sec ret' dbname=postgis", "host=db password=xxxxx dbname=postgis"},
+		{"spaces around equals", "host=db password = secret", "host=db password = xxxxx"},
+		{"keyword/value without password", "host=db user=user", "host=db user=user"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		got := RedactDSN(tt.dsn)
+		if got != tt.want {
+			t.Errorf("%s: RedactDSN(%q) = %q, want %q", tt.name, tt.dsn, got, tt.want)
+		}
+		if strings.Contains(got, "secret") || strings.Contains(got, "sec ret") {
+			t.Errorf("%s: RedactDSN(%q) = %q still contains the password", tt.name, tt.dsn, got)
+		}
+	}
+}
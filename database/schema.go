@@ -0,0 +1,112 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"sort"
+
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed schema/*.sql
+var schemaFiles embed.FS
+
+// migrationsTable records every schema file applied by go-stac-server
+var migrationsTable = `
+CREATE SCHEMA IF NOT EXISTS stac_server;
+CREATE TABLE IF NOT EXISTS stac_server.migrations (
+    name text PRIMARY KEY,
+    version text,
+    applied_at timestamptz NOT NULL DEFAULT now()
+);`
+
+// EnsureSchema creates the tables owned by go-stac-server (jobs, etc.) in the
+// stac_server schema. Files that have already been applied are recorded in
+// stac_server.migrations and skipped so this is safe to call on every startup.
+func EnsureSchema(ctx context.Context) error {
+	names, err := fs.Glob(schemaFiles, "schema/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	pool := GetInstance(ctx)
+	if _, err := pool.Exec(ctx, migrationsTable); err != nil {
+		log.Error().Err(err).Msg("failed to create migrations table")
+		return err
+	}
+
+	applied, err := appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if applied[name] {
+			continue
+		}
+
+		sql, err := schemaFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		log.Info().Str("file", name).Msg("applying server schema")
+		tx, err := pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, string(sql)); err != nil {
+			_ = tx.Rollback(ctx)
+			log.Error().Err(err).Str("file", name).Msg("failed to apply server schema")
+			return err
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO stac_server.migrations (name) VALUES ($1)", name); err != nil {
+			_ = tx.Rollback(ctx)
+			log.Error().Err(err).Str("file", name).Msg("failed to record server schema")
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func appliedMigrations(ctx context.Context) (map[string]bool, error) {
+	pool := GetInstance(ctx)
+	rows, err := pool.Query(ctx, "SELECT name FROM stac_server.migrations")
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query applied migrations")
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		applied[name] = true
+	}
+
+	return applied, rows.Err()
+}
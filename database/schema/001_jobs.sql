@@ -0,0 +1,27 @@
+-- tables owned by go-stac-server; pgstac tables are managed by pgstac itself
+CREATE SCHEMA IF NOT EXISTS stac_server;
+
+CREATE TABLE IF NOT EXISTS stac_server.jobs (
+    id text PRIMARY KEY,
+    collection_id text NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    total integer NOT NULL DEFAULT 0,
+    processed integer NOT NULL DEFAULT 0,
+    succeeded integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    failures jsonb NOT NULL DEFAULT '[]'::jsonb,
+    message text,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    started_at timestamptz,
+    finished_at timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS jobs_status_idx ON stac_server.jobs (status, created_at);
+
+CREATE TABLE IF NOT EXISTS stac_server.job_items (
+    job_id text NOT NULL REFERENCES stac_server.jobs (id) ON DELETE CASCADE,
+    idx integer NOT NULL,
+    content jsonb NOT NULL,
+    PRIMARY KEY (job_id, idx)
+);
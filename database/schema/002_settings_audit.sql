@@ -0,0 +1,11 @@
+-- history of changes made to pgstac settings through go-stac-server
+CREATE TABLE IF NOT EXISTS stac_server.settings_audit (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    old_value text,
+    new_value text,
+    changed_by text,
+    changed_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS settings_audit_name_idx ON stac_server.settings_audit (name, changed_at);
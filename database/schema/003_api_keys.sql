@@ -0,0 +1,10 @@
+-- API keys; only a SHA-256 hash of each key is stored
+CREATE TABLE IF NOT EXISTS stac_server.api_keys (
+    id text PRIMARY KEY,
+    name text NOT NULL,
+    key_hash text NOT NULL UNIQUE,
+    roles text[] NOT NULL DEFAULT '{}',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz,
+    last_used_at timestamptz
+);
@@ -0,0 +1,7 @@
+-- token buckets shared by every replica when rate limit state is kept in Postgres
+CREATE UNLOGGED TABLE IF NOT EXISTS stac_server.rate_limits (
+    key text PRIMARY KEY,
+    tokens double precision NOT NULL,
+    allowed boolean NOT NULL DEFAULT true,
+    updated_at timestamptz NOT NULL DEFAULT clock_timestamp()
+);
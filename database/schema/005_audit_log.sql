@@ -0,0 +1,21 @@
+-- every write made through the transaction routes
+CREATE TABLE IF NOT EXISTS stac_server.audit_log (
+    id bigserial PRIMARY KEY,
+    occurred_at timestamptz NOT NULL DEFAULT now(),
+    principal text NOT NULL,
+    auth_method text,
+    ip text,
+    method text NOT NULL,
+    route text NOT NULL,
+    path text NOT NULL,
+    collection_id text,
+    item_ids text[],
+    before_hash text,
+    after_hash text,
+    diff jsonb,
+    status integer NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_collection_idx ON stac_server.audit_log (collection_id, occurred_at);
+CREATE INDEX IF NOT EXISTS audit_log_principal_idx ON stac_server.audit_log (principal, occurred_at);
+CREATE INDEX IF NOT EXISTS audit_log_occurred_at_idx ON stac_server.audit_log (occurred_at);
@@ -0,0 +1,7 @@
+-- the worker holding a running job; progress is only recorded by the holder
+-- of the claim so a job taken over after its lease expired isn't counted twice
+ALTER TABLE stac_server.jobs ADD COLUMN IF NOT EXISTS claim text;
+
+-- index of the last job item processed; a job taken over resumes after it
+ALTER TABLE stac_server.jobs ADD COLUMN IF NOT EXISTS last_idx integer NOT NULL DEFAULT 0;
+UPDATE stac_server.jobs SET last_idx = processed;
@@ -0,0 +1,258 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrUnknownSetting = errors.New("unknown pgstac setting")
+var ErrInvalidSetting = errors.New("invalid setting value")
+
+// Setting is a pgstac_settings row
+type Setting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SettingChange is an audit record of a setting being changed
+type SettingChange struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	OldValue  *string   `json:"old_value"`
+	NewValue  *string   `json:"new_value"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// PartitionTruncations are the allowed values of a collection's partition_trunc
+var PartitionTruncations = []string{"year", "month"}
+
+// settingValidators check the values allowed for each pgstac setting
+var settingValidators = map[string]func(string) error{
+	"context":                  oneOf("on", "off", "auto"),
+	"context_estimated_count":  nonNegativeInt,
+	"context_estimated_cost":   nonNegativeInt,
+	"context_stats_ttl":        interval,
+	"default_filter_lang":      oneOf("cql2-json", "cql-json"),
+	"additional_properties":    oneOf("true", "false"),
+	"use_queue":                oneOf("true", "false"),
+	"update_collection_extent": oneOf("true", "false"),
+	"format_cache":             oneOf("true", "false"),
+}
+
+func oneOf(allowed ...string) func(string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("value must be one of: %s", strings.Join(allowed, ", "))
+	}
+}
+
+func nonNegativeInt(value string) error {
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return errors.New("value must be a non-negative integer")
+	}
+	return nil
+}
+
+func interval(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return errors.New("value must be an interval such as '1 day'")
+	}
+	if err := nonNegativeInt(fields[0]); err != nil {
+		return errors.New("value must be an interval such as '1 day'")
+	}
+	return oneOf("second", "seconds", "minute", "minutes", "hour", "hours", "day", "days", "week", "weeks")(fields[1])
+}
+
+// SettingNames returns the names of the settings that can be changed
+func SettingNames() []string {
+	names := make([]string, 0, len(settingValidators))
+	for name := range settingValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateSetting checks a setting name and value are supported
+func ValidateSetting(name string, value string) error {
+	validate, ok := settingValidators[name]
+	if !ok {
+		return fmt.Errorf("%w '%s'; settings are: %s", ErrUnknownSetting, name, strings.Join(SettingNames(), ", "))
+	}
+	if err := validate(value); err != nil {
+		return fmt.Errorf("%w for %s: %s", ErrInvalidSetting, name, err)
+	}
+	return nil
+}
+
+// GetSettings returns the pgstac_settings rows that can be managed
+func GetSettings(ctx context.Context) ([]Setting, error) {
+	pool := GetInstance(ctx)
+	rows, err := pool.Query(ctx, "SELECT name, value FROM pgstac.pgstac_settings WHERE name = ANY($1) ORDER BY name", SettingNames())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query pgstac settings")
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make([]Setting, 0, len(settingValidators))
+	for rows.Next() {
+		var setting Setting
+		if err := rows.Scan(&setting.Name, &setting.Value); err != nil {
+			return nil, err
+		}
+		settings = append(settings, setting)
+	}
+	return settings, rows.Err()
+}
+
+// GetSetting returns a single pgstac setting
+func GetSetting(ctx context.Context, name string) (*Setting, error) {
+	if _, ok := settingValidators[name]; !ok {
+		return nil, ErrUnknownSetting
+	}
+
+	setting := Setting{Name: name}
+	pool := GetInstance(ctx)
+	err := pool.QueryRow(ctx, "SELECT value FROM pgstac.pgstac_settings WHERE name = $1", name).Scan(&setting.Value)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &setting, nil
+	}
+	return &setting, err
+}
+
+// SetSetting validates and stores a pgstac setting, recording the change
+// and who made it in stac_server.settings_audit
+func SetSetting(ctx context.Context, name string, value string, changedBy string) (*SettingChange, error) {
+	if err := ValidateSetting(name, value); err != nil {
+		return nil, err
+	}
+
+	pool := GetInstance(ctx)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var old *string
+	if err := tx.QueryRow(ctx, "SELECT value FROM pgstac.pgstac_settings WHERE name = $1 FOR UPDATE", name).Scan(&old); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `INSERT INTO pgstac.pgstac_settings (name, value) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value`, name, value); err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed to update pgstac setting")
+		return nil, err
+	}
+
+	change, err := recordSettingChange(ctx, tx, name, old, &value, changedBy)
+	if err != nil {
+		return nil, err
+	}
+	return change, tx.Commit(ctx)
+}
+
+// SetPartitionTrunc changes how a collection's items are partitioned; pgstac
+// repartitions the existing items
+func SetPartitionTrunc(ctx context.Context, collectionID string, value string, changedBy string) (*SettingChange, error) {
+	var trunc *string
+	if value != "" {
+		if err := oneOf(PartitionTruncations...)(value); err != nil {
+			return nil, fmt.Errorf("%w for partition_trunc: %s", ErrInvalidSetting, err)
+		}
+		trunc = &value
+	}
+
+	pool := GetInstance(ctx)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var old *string
+	if err := tx.QueryRow(ctx, "SELECT partition_trunc FROM pgstac.collections WHERE id = $1 FOR UPDATE", collectionID).Scan(&old); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE pgstac.collections SET partition_trunc = $2 WHERE id = $1", collectionID, trunc); err != nil {
+		log.Error().Err(err).Str("collection", collectionID).Msg("failed to update partition_trunc")
+		return nil, err
+	}
+
+	change, err := recordSettingChange(ctx, tx, fmt.Sprintf("partition_trunc:%s", collectionID), old, trunc, changedBy)
+	if err != nil {
+		return nil, err
+	}
+	return change, tx.Commit(ctx)
+}
+
+func recordSettingChange(ctx context.Context, tx pgx.Tx, name string, old *string, value *string, changedBy string) (*SettingChange, error) {
+	change := SettingChange{
+		Name:      name,
+		OldValue:  old,
+		NewValue:  value,
+		ChangedBy: changedBy,
+	}
+	if err := tx.QueryRow(ctx, `INSERT INTO stac_server.settings_audit (name, old_value, new_value, changed_by)
+		VALUES ($1, $2, $3, $4) RETURNING id, changed_at`, name, old, value, changedBy).Scan(&change.ID, &change.ChangedAt); err != nil {
+		log.Error().Err(err).Str("name", name).Msg("failed to record setting change")
+		return nil, err
+	}
+	return &change, nil
+}
+
+// SettingHistory returns the most recent setting changes, optionally for a
+// single setting
+func SettingHistory(ctx context.Context, name string, limit int) ([]SettingChange, error) {
+	pool := GetInstance(ctx)
+	rows, err := pool.Query(ctx, `SELECT id, name, old_value, new_value, coalesce(changed_by, ''), changed_at
+		FROM stac_server.settings_audit
+		WHERE $1 = '' OR name = $1
+		ORDER BY id DESC LIMIT $2`, name, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to query setting history")
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]SettingChange, 0)
+	for rows.Next() {
+		var change SettingChange
+		if err := rows.Scan(&change.ID, &change.Name, &change.OldValue, &change.NewValue, &change.ChangedBy, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
@@ -0,0 +1,418 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export writes the results of a STAC search to a self-contained
+// static catalog, a gzipped tar archive of one or newline delimited JSON.
+package export
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// Format is the layout an export is written in
+type Format string
+
+const (
+	FormatCatalog Format = "catalog"
+	FormatTarGz   Format = "tar.gz"
+	FormatNDJSON  Format = "ndjson"
+)
+
+// hierarchyRels are replaced by relative links when writing a static catalog
+var hierarchyRels = []string{"self", "root", "parent", "child", "item", "collection"}
+
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9\-_\.]`)
+
+// leadingDots keeps ids like "." and ".." from naming a relative directory
+var leadingDots = regexp.MustCompile(`^\.+`)
+
+// Exporter pages through a search and writes every matching item along with
+// the collections they belong to
+type Exporter struct {
+	Output             string
+	Format             Format
+	PageSize           int
+	MaxItems           int
+	CatalogID          string
+	CatalogTitle       string
+	CatalogDescription string
+}
+
+// Report summarizes what was exported
+type Report struct {
+	Collections int
+	Items       int
+	Path        string
+}
+
+// sink receives the files of a static catalog
+type sink interface {
+	write(name string, data []byte) error
+	close() error
+}
+
+// Export runs the search described by params and writes the results
+func (e *Exporter) Export(ctx context.Context, params stac.CQL) (*Report, error) {
+	if e.PageSize <= 0 {
+		e.PageSize = 1000
+	}
+
+	if err := os.MkdirAll(e.Output, 0o755); err != nil {
+		return nil, err
+	}
+
+	switch e.Format {
+	case FormatNDJSON:
+		return e.exportNDJSON(ctx, params)
+	case FormatTarGz:
+		name := filepath.Join(e.Output, fmt.Sprintf("%s.tar.gz", safeName(e.CatalogID)))
+		out, err := newTarSink(name)
+		if err != nil {
+			return nil, err
+		}
+		report, err := e.exportCatalog(ctx, params, out)
+		if closeErr := out.close(); err == nil {
+			err = closeErr
+		}
+		if report != nil {
+			report.Path = name
+		}
+		return report, err
+	case FormatCatalog, "":
+		report, err := e.exportCatalog(ctx, params, &dirSink{root: e.Output})
+		if report != nil {
+			report.Path = filepath.Join(e.Output, "catalog.json")
+		}
+		return report, err
+	default:
+		return nil, fmt.Errorf("unsupported export format '%s'", e.Format)
+	}
+}
+
+// eachItem calls fn for every item matched by params, following next tokens
+// until the search is exhausted or MaxItems is reached
+func (e *Exporter) eachItem(ctx context.Context, params stac.CQL, fn func(collectionID string, itemID string, item map[string]*json.RawMessage) error) (int, error) {
+	if params.Conf == nil {
+		conf := json.RawMessage(`{"nohydrate": false}`)
+		params.Conf = &conf
+	}
+	params.Limit = e.PageSize
+
+	count := 0
+	for {
+		featureCollection, err := stac.Search(ctx, params)
+		if err != nil {
+			return count, err
+		}
+
+		for _, item := range featureCollection.Features {
+			var itemID, collectionID string
+			if raw, ok := item["id"]; !ok || json.Unmarshal(*raw, &itemID) != nil {
+				return count, errors.New("search returned an item without an id")
+			}
+			if raw, ok := item["collection"]; !ok || json.Unmarshal(*raw, &collectionID) != nil {
+				return count, fmt.Errorf("item '%s' has no collection", itemID)
+			}
+
+			if err := fn(collectionID, itemID, item); err != nil {
+				return count, err
+			}
+
+			count++
+			if e.MaxItems > 0 && count >= e.MaxItems {
+				return count, nil
+			}
+		}
+
+		if featureCollection.Next == "" || len(featureCollection.Features) == 0 {
+			return count, nil
+		}
+		params.Token = featureCollection.Next
+		log.Info().Int("items", count).Msg("export progress")
+	}
+}
+
+func (e *Exporter) exportCatalog(ctx context.Context, params stac.CQL, out sink) (*Report, error) {
+	itemIDs := make(map[string][]string)
+	collectionNames := make(fileNames)
+	itemNames := make(map[string]fileNames)
+
+	count, err := e.eachItem(ctx, params, func(collectionID string, itemID string, item map[string]*json.RawMessage) error {
+		if err := stac.StripLinks(item, hierarchyRels...); err != nil {
+			return err
+		}
+
+		links := []stac.Link{
+			{Rel: "root", Type: "application/json", Href: "../../catalog.json"},
+			{Rel: "parent", Type: "application/json", Href: "../collection.json"},
+			{Rel: stac.CollectionKey, Type: "application/json", Href: "../collection.json"},
+		}
+		if err := setLinks(item, links); err != nil {
+			return err
+		}
+
+		data, err := json.MarshalIndent(item, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		collectionName, err := collectionNames.claim("collection", collectionID)
+		if err != nil {
+			return err
+		}
+		if itemNames[collectionID] == nil {
+			itemNames[collectionID] = make(fileNames)
+		}
+		itemName, err := itemNames[collectionID].claim("item", itemID)
+		if err != nil {
+			return err
+		}
+
+		itemIDs[collectionID] = append(itemIDs[collectionID], itemID)
+		return out.write(archivePath(collectionName, itemName, itemName+".json"), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	collectionIDs := make([]string, 0, len(itemIDs))
+	for collectionID := range itemIDs {
+		collectionIDs = append(collectionIDs, collectionID)
+	}
+	sort.Strings(collectionIDs)
+
+	catalogLinks := []stac.Link{
+		{Rel: "root", Type: "application/json", Href: "./catalog.json"},
+	}
+	for _, collectionID := range collectionIDs {
+		collection, err := loadCollection(ctx, collectionID)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := stac.StripLinks(collection, hierarchyRels...); err != nil {
+			return nil, err
+		}
+
+		links := []stac.Link{
+			{Rel: "root", Type: "application/json", Href: "../catalog.json"},
+			{Rel: "parent", Type: "application/json", Href: "../catalog.json"},
+		}
+		for _, itemID := range itemIDs[collectionID] {
+			links = append(links, stac.Link{
+				Rel:  "item",
+				Type: "application/geo+json",
+				Href: fmt.Sprintf("./%s/%s.json", safeName(itemID), safeName(itemID)),
+			})
+		}
+		if err := setLinks(collection, links); err != nil {
+			return nil, err
+		}
+
+		data, err := json.MarshalIndent(collection, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := out.write(archivePath(safeName(collectionID), "collection.json"), data); err != nil {
+			return nil, err
+		}
+
+		var title string
+		if raw, ok := collection["title"]; ok {
+			_ = json.Unmarshal(*raw, &title)
+		}
+		catalogLinks = append(catalogLinks, stac.Link{
+			Rel:   "child",
+			Type:  "application/json",
+			Title: title,
+			Href:  fmt.Sprintf("./%s/collection.json", safeName(collectionID)),
+		})
+	}
+
+	catalog := stac.Catalog{
+		Type:        "Catalog",
+		ID:          e.CatalogID,
+		Title:       e.CatalogTitle,
+		Description: e.CatalogDescription,
+		StacVersion: "1.0.0",
+		Links:       catalogLinks,
+	}
+	data, err := json.MarshalIndent(catalog, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := out.write("catalog.json", data); err != nil {
+		return nil, err
+	}
+
+	return &Report{Collections: len(collectionIDs), Items: count}, nil
+}
+
+func (e *Exporter) exportNDJSON(ctx context.Context, params stac.CQL) (*Report, error) {
+	itemsPath := filepath.Join(e.Output, "items.ndjson")
+	fh, err := os.Create(itemsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	writer := bufio.NewWriter(fh)
+
+	seen := make(map[string]bool)
+	collectionIDs := make([]string, 0)
+	count, err := e.eachItem(ctx, params, func(collectionID string, itemID string, item map[string]*json.RawMessage) error {
+		if !seen[collectionID] {
+			seen[collectionID] = true
+			collectionIDs = append(collectionIDs, collectionID)
+		}
+
+		if err := stac.StripLinks(item, hierarchyRels...); err != nil {
+			return err
+		}
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+		return writer.WriteByte('\n')
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	collectionsFh, err := os.Create(filepath.Join(e.Output, "collections.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+	defer collectionsFh.Close()
+
+	for _, collectionID := range collectionIDs {
+		collection, err := loadCollection(ctx, collectionID)
+		if err != nil {
+			return nil, err
+		}
+		if err := stac.StripLinks(collection, hierarchyRels...); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(collection)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := collectionsFh.Write(append(data, '\n')); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Report{Collections: len(collectionIDs), Items: count, Path: itemsPath}, nil
+}
+
+func loadCollection(ctx context.Context, collectionID string) (map[string]*json.RawMessage, error) {
+	var raw string
+	pool := database.GetInstance(ctx)
+	if err := pool.QueryRow(ctx, "SELECT get_collection FROM pgstac.get_collection($1)", collectionID).Scan(&raw); err != nil {
+		log.Error().Err(err).Str("collection", collectionID).Msg("failed to load collection")
+		return nil, err
+	}
+
+	collection := make(map[string]*json.RawMessage)
+	if err := json.Unmarshal([]byte(raw), &collection); err != nil {
+		return nil, err
+	}
+
+	collectionType := json.RawMessage(`"Collection"`)
+	collection["type"] = &collectionType
+	return collection, nil
+}
+
+// setLinks appends links to the links array of obj
+func setLinks(obj map[string]*json.RawMessage, links []stac.Link) error {
+	existing := make([]*json.RawMessage, 0, len(links))
+	if raw, ok := obj["links"]; ok {
+		if err := json.Unmarshal(*raw, &existing); err != nil {
+			return err
+		}
+	}
+
+	for _, link := range links {
+		data, err := json.Marshal(link)
+		if err != nil {
+			return err
+		}
+		raw := json.RawMessage(data)
+		existing = append(existing, &raw)
+	}
+
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(data)
+	obj["links"] = &raw
+	return nil
+}
+
+// safeName makes an id usable as a file name. Ids that are already safe are
+// used as they are; others, including ids starting with a dot such as "..",
+// have their unsafe characters replaced and a hash of the id appended so that
+// ids like "a/b" and "a_b" don't end up in the same file.
+func safeName(id string) string {
+	if id != "" && !strings.HasPrefix(id, ".") && !unsafeChars.MatchString(id) {
+		return id
+	}
+	name := unsafeChars.ReplaceAllString(id, "_")
+	name = leadingDots.ReplaceAllStringFunc(name, func(dots string) string {
+		return strings.Repeat("_", len(dots))
+	})
+	sum := sha256.Sum256([]byte(id))
+	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(sum[:4]))
+}
+
+// fileNames tracks the file names given to ids so that two ids never share a
+// file
+type fileNames map[string]string
+
+// claim returns the file name for id, failing if another id already has it
+func (f fileNames) claim(kind string, id string) (string, error) {
+	name := safeName(id)
+	if other, ok := f[name]; ok && other != id {
+		return "", fmt.Errorf("%s ids '%s' and '%s' would both be written as '%s'", kind, other, id, name)
+	}
+	f[name] = id
+	return name, nil
+}
+
+// archivePath joins archive path elements with forward slashes
+func archivePath(elem ...string) string {
+	return filepath.ToSlash(filepath.Join(elem...))
+}
@@ -0,0 +1,90 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		id     string
+		prefix string
+		hashed bool
+	}{
+		{"S2A_MSIL2A_20230101", "S2A_MSIL2A_20230101", false},
+		{"item-1.v2", "item-1.v2", false},
+		{"a/b", "a_b-", true},
+		{"..", "__-", true},
+		{".", "_-", true},
+		{".hidden", "_hidden-", true},
+		{"../../etc/passwd", "___.._etc_passwd-", true},
+		{"", "-", true},
+	}
+	for _, tt := range tests {
+		got := safeName(tt.id)
+		if !tt.hashed && got != tt.prefix {
+			t.Errorf("safeName(%q) = %q, want %q", tt.id, got, tt.prefix)
+		}
+		if tt.hashed && (!strings.HasPrefix(got, tt.prefix) || len(got) != len(tt.prefix)+8) {
+			t.Errorf("safeName(%q) = %q, want %q followed by a hash", tt.id, got, tt.prefix)
+		}
+		if !filepath.IsLocal(got) || strings.ContainsAny(got, `/\`) {
+			t.Errorf("safeName(%q) = %q is not a plain file name", tt.id, got)
+		}
+	}
+
+	if safeName("a/b") == safeName("a_b") || safeName("a/b") == safeName("a:b") {
+		t.Errorf("ids differing only in unsafe characters share a name")
+	}
+}
+
+func TestFileNamesClaim(t *testing.T) {
+	names := make(fileNames)
+	if _, err := names.claim("item", "a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := names.claim("item", "a/b"); err != nil {
+		t.Errorf("claim() of the same id twice = %v, want no error", err)
+	}
+
+	// an id that happens to equal the escaped form of another
+	escaped := safeName("a/b")
+	if _, err := names.claim("item", escaped); err == nil {
+		t.Errorf("claim(%q) after claiming %q succeeded, want a collision", escaped, "a/b")
+	}
+}
+
+func TestDirSinkStaysInRoot(t *testing.T) {
+	root := t.TempDir()
+	out := &dirSink{root: root}
+
+	for _, name := range []string{"../escaped.json", "a/../../escaped.json", "/etc/escaped.json", ""} {
+		if err := out.write(name, []byte("{}")); err == nil {
+			t.Errorf("write(%q) succeeded, want an error", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escaped.json")); err == nil {
+		t.Errorf("a file was written outside of the export")
+	}
+
+	if err := out.write(archivePath(safeName(".."), safeName(".."), safeName("..")+".json"), []byte("{}")); err != nil {
+		t.Errorf("write() of an escaped id = %v, want no error", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkName rejects file names that would be written outside of the export,
+// such as absolute names or names with ".." elements
+func checkName(name string) error {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("invalid export file name '%s'", name)
+	}
+	return nil
+}
+
+// dirSink writes files below a directory on disk
+type dirSink struct {
+	root string
+}
+
+func (d *dirSink) write(name string, data []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	target := filepath.Join(d.root, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(d.root, target); err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("export file '%s' is outside of %s", name, d.root)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+func (d *dirSink) close() error {
+	return nil
+}
+
+// tarSink writes files into a gzipped tar archive
+type tarSink struct {
+	fh  *os.File
+	gz  *gzip.Writer
+	tar *tar.Writer
+}
+
+func newTarSink(name string) (*tarSink, error) {
+	fh, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(fh)
+	return &tarSink{
+		fh:  fh,
+		gz:  gz,
+		tar: tar.NewWriter(gz),
+	}, nil
+}
+
+func (t *tarSink) write(name string, data []byte) error {
+	if err := checkName(name); err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := t.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := t.tar.Write(data)
+	return err
+}
+
+func (t *tarSink) close() error {
+	if err := t.tar.Close(); err != nil {
+		return err
+	}
+	if err := t.gz.Close(); err != nil {
+		return err
+	}
+	return t.fh.Close()
+}
@@ -0,0 +1,74 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/audit"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuditLog returns the newest audited writes, optionally filtered by
+// collection, item, principal and a start/end time range
+// GET /audit
+func AuditLog(c *fiber.Ctx) error {
+	filter := audit.Filter{
+		CollectionID: c.Query("collection"),
+		ItemID:       c.Query("item"),
+		Principal:    c.Query("principal"),
+		Limit:        c.QueryInt("limit", 100),
+	}
+	if filter.Limit < 1 || filter.Limit > 1000 {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: "limit must be between 1 and 1000",
+		})
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"start", &filter.Start}, {"end", &filter.End}} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return c.JSON(stac.Message{
+				Code:        stac.ParameterError,
+				Description: fmt.Sprintf("%s '%s' must be an RFC 3339 timestamp", bound.name, raw),
+			})
+		}
+		*bound.value = parsed
+	}
+
+	entries, err := audit.Query(c.UserContext(), filter)
+	if err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        database.QueryErrorCode,
+			Description: "failed to query audit log",
+		})
+	}
+	return c.JSON(fiber.Map{"entries": entries})
+}
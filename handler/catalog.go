@@ -16,9 +16,10 @@
 package handler
 
 import (
-	"context"
 	"fmt"
 
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/common"
 	"github.com/go-geospatial/go-stac-server/database"
 	"github.com/go-geospatial/go-stac-server/stac"
 	"github.com/gofiber/fiber/v2"
@@ -27,7 +28,7 @@ import (
 )
 
 func Catalog(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 
 	baseURL := getBaseURL(c)
 	self := fmt.Sprintf("%s/api/stac/v1", baseURL)
@@ -80,11 +81,12 @@ func Catalog(c *fiber.Ctx) error {
 		Href:  fmt.Sprintf("%s/doc/", baseURL),
 	})
 
-	// get a list of all collections
+	// get a list of all collections the caller may see
+	principal := auth.FromContext(c)
 	pool := database.GetInstance(ctx)
 	rows, err := pool.Query(ctx, "SELECT id, content->>'title'::text as title FROM pgstac.collections ORDER BY id")
 	if err != nil {
-		log.Error().Err(err).Msg("error querying collections for catalog response")
+		log.Ctx(ctx).Error().Err(err).Msg("error querying collections for catalog response")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        database.QueryErrorCode,
@@ -100,13 +102,16 @@ func Catalog(c *fiber.Ctx) error {
 		var collectionID string
 		err := rows.Scan(&collectionID, &child.Title)
 		if err != nil {
-			log.Error().Err(err).Msg("could not scan collection id and title")
+			log.Ctx(ctx).Error().Err(err).Msg("could not scan collection id and title")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        database.QueryErrorCode,
 				Description: "could not serialize data from collections table",
 			})
 		}
+		if !auth.CanAccessCollection(principal, collectionID) {
+			continue
+		}
 		child.Href = fmt.Sprintf("%s/collections/%s", self, collectionID)
 		links = append(links, child)
 	}
@@ -117,7 +122,7 @@ func Catalog(c *fiber.Ctx) error {
 		Title:       viper.GetString("stac.catalog.title"),
 		Description: viper.GetString("stac.catalog.description"),
 		StacVersion: "1.0.0",
-		ConformsTo:  stac.Conformance,
+		ConformsTo:  stac.ConformsTo(common.TransactionsEnabled()),
 		Links:       links,
 	}
 
@@ -20,26 +20,28 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/go-geospatial/go-stac-server/auth"
 	"github.com/go-geospatial/go-stac-server/database"
 	"github.com/go-geospatial/go-stac-server/stac"
 	json "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v5"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 // ModifyCollection creates a new collection in the database
 // POST /collections
 // PUT /collections
 func ModifyCollection(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 
 	// validate passed JSON
 	collectionRaw := c.Body()
 	collection := make(map[string]*json.RawMessage)
 
 	if err := json.Unmarshal(collectionRaw, &collection); err != nil {
-		log.Error().Err(err).Str("RequestBody", string(collectionRaw)).Msg("cannot unmarshal provided JSON in CreateCollection")
+		log.Ctx(ctx).Error().Err(err).Str("RequestBody", string(collectionRaw)).Msg("cannot unmarshal provided JSON in CreateCollection")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -53,9 +55,18 @@ func ModifyCollection(c *fiber.Ctx) error {
 		return err
 	}
 
+	// collections hidden from the caller can't be overwritten either
+	if !auth.CanAccessCollection(auth.FromContext(c), id) {
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: "collection not found",
+		})
+	}
+
 	collectionJSON, err := json.Marshal(collection)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal collection to JSON")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to marshal collection to JSON")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -65,13 +76,13 @@ func ModifyCollection(c *fiber.Ctx) error {
 
 	query := "SELECT create_collection($1::text::jsonb)"
 	if c.Method() == "PUT" {
-		log.Info().Msg("updating collection")
+		log.Ctx(ctx).Info().Msg("updating collection")
 		query = "SELECT update_collection($1::text::jsonb)"
 	}
 
 	pool := database.GetInstance(ctx)
 	if _, err := pool.Exec(ctx, query, collectionJSON); err != nil {
-		log.Error().Err(err).Str("id", id).Str("raw", string(collectionRaw)).Msg("failed to create collection")
+		log.Ctx(ctx).Error().Err(err).Str("id", id).Str("raw", string(collectionRaw)).Msg("failed to create collection")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        "CreateCollectionFailed",
@@ -79,24 +90,36 @@ func ModifyCollection(c *fiber.Ctx) error {
 		})
 	}
 
+	stac.InvalidateCollectionIDs()
+	registerSummaryQueryables(ctx, id, collection)
+
 	return collectionFromID(c, id)
 }
 
+// registerSummaryQueryables registers queryables described by summaries and
+// item_assets when queryables.fromSummaries is set
+func registerSummaryQueryables(ctx context.Context, id string, collection map[string]*json.RawMessage) {
+	if viper.GetBool("queryables.fromSummaries") {
+		stac.RegisterSummaryQueryables(ctx, id, collection)
+	}
+}
+
 // DeleteCollection creates a new collection in the database
 // DELETE /collections
 func DeleteCollection(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 
 	pool := database.GetInstance(ctx)
 	if _, err := pool.Exec(ctx, "SELECT delete_collection($1::text)", collectionID); err != nil {
-		log.Error().Err(err).Str("id", collectionID).Msg("collection not found")
+		log.Ctx(ctx).Error().Err(err).Str("id", collectionID).Msg("collection not found")
 		c.Status(fiber.ErrNotFound.Code)
 		return c.JSON(stac.Message{
 			Code:        stac.NotFoundError,
 			Description: "collection not found",
 		})
 	}
+	stac.InvalidateCollectionIDs()
 
 	// NOTE: we use the error struct here for convenience because it has a suitable structure for the response
 	return c.JSON(stac.Message{
@@ -113,7 +136,7 @@ func Collection(c *fiber.Ctx) error {
 }
 
 func collectionFromID(c *fiber.Ctx, collectionID string) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	baseURL := getBaseURL(c)
 
 	// get a list of all collections
@@ -125,7 +148,7 @@ func collectionFromID(c *fiber.Ctx, collectionID string) error {
 	err := row.Scan(&rawCollection)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			log.Error().Err(err).Str("collectionId", collectionID).Msg("collection not found")
+			log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection not found")
 			c.Status(fiber.ErrNotFound.Code)
 			return c.JSON(stac.Message{
 				Code:        "404",
@@ -134,7 +157,7 @@ func collectionFromID(c *fiber.Ctx, collectionID string) error {
 		}
 
 		// pgstac returns a row even if the collection doesn't exist.
-		log.Error().Str("collection", collectionID).Msg("collection not found")
+		log.Ctx(ctx).Error().Str("collection", collectionID).Msg("collection not found")
 		c.Status(fiber.StatusNotFound)
 		return c.JSON(stac.Message{
 			Code:        stac.NotFoundError,
@@ -144,7 +167,7 @@ func collectionFromID(c *fiber.Ctx, collectionID string) error {
 
 	// un-marshal to map
 	if err := json.Unmarshal([]byte(rawCollection), &collection); err != nil {
-		log.Error().Err(err).Msg("collection JSON unmarshal failed")
+		log.Ctx(ctx).Error().Err(err).Msg("collection JSON unmarshal failed")
 		c.Status(fiber.StatusInternalServerError)
 		_ = c.JSON(stac.Message{
 			Code:        stac.JSONParsingError,
@@ -157,7 +180,7 @@ func collectionFromID(c *fiber.Ctx, collectionID string) error {
 	links := make([]stac.Link, 0, 5)
 	if rawLinks, ok := collection["links"]; ok {
 		if err := json.Unmarshal(*rawLinks, &links); err != nil {
-			log.Error().Err(err).Msg("collection JSON unmarshal failed")
+			log.Ctx(ctx).Error().Err(err).Msg("collection JSON unmarshal failed")
 			c.Status(fiber.StatusInternalServerError)
 			_ = c.JSON(stac.Message{
 				Code:        stac.JSONParsingError,
@@ -177,7 +200,7 @@ func collectionFromID(c *fiber.Ctx, collectionID string) error {
 	var serializedLinks json.RawMessage
 	serializedLinks, err = json.Marshal(links)
 	if err != nil {
-		log.Error().Err(err).Msg("collection links JSON marshal failed")
+		log.Ctx(ctx).Error().Err(err).Msg("collection links JSON marshal failed")
 		c.Status(fiber.StatusInternalServerError)
 		_ = c.JSON(stac.Message{
 			Code:        stac.JSONParsingError,
@@ -196,16 +219,34 @@ func collectionFromID(c *fiber.Ctx, collectionID string) error {
 // Collections returns a list of collections managed by this STAC server
 // GET /collections/
 func Collections(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	baseURL := getBaseURL(c)
 
 	collections := make([]*json.RawMessage, 0, 10)
 
-	// get a list of all collections
+	// ?stats=true adds item counts to each collection
+	var counts map[string]stac.ItemCounts
+	if c.QueryBool("stats") {
+		refresh, err := statsRefresh(c)
+		if err != nil {
+			// http response and logging handled by statsRefresh
+			return nil
+		}
+		if counts, err = stac.GetItemCounts(ctx, viper.GetDuration("stats.cacheTtl"), refresh); err != nil {
+			c.Status(fiber.StatusInternalServerError)
+			return c.JSON(stac.Message{
+				Code:        database.QueryErrorCode,
+				Description: "could not count collection items",
+			})
+		}
+	}
+
+	// get a list of all collections the caller may see
+	principal := auth.FromContext(c)
 	pool := database.GetInstance(ctx)
 	rows, err := pool.Query(ctx, "SELECT id, content FROM pgstac.collections ORDER BY id")
 	if err != nil {
-		log.Error().Err(err).Msg("error querying collections for list collections response")
+		log.Ctx(ctx).Error().Err(err).Msg("error querying collections for list collections response")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        database.QueryErrorCode,
@@ -219,7 +260,7 @@ func Collections(c *fiber.Ctx) error {
 		var collectionID string
 		err := rows.Scan(&collectionID, &rawCollection)
 		if err != nil {
-			log.Error().Err(err).Msg("could not scan collection id and title")
+			log.Ctx(ctx).Error().Err(err).Msg("could not scan collection id and title")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        database.QueryErrorCode,
@@ -227,9 +268,13 @@ func Collections(c *fiber.Ctx) error {
 			})
 		}
 
+		if !auth.CanAccessCollection(principal, collectionID) {
+			continue
+		}
+
 		// un-marshal to map
 		if err := json.Unmarshal([]byte(rawCollection), &collection); err != nil {
-			log.Error().Err(err).Msg("collection JSON unmarshal failed")
+			log.Ctx(ctx).Error().Err(err).Msg("collection JSON unmarshal failed")
 			c.Status(fiber.StatusInternalServerError)
 			_ = c.JSON(stac.Message{
 				Code:        stac.JSONParsingError,
@@ -242,7 +287,7 @@ func Collections(c *fiber.Ctx) error {
 		links := make([]stac.Link, 0, 5)
 		if rawLinks, ok := collection["links"]; ok {
 			if err := json.Unmarshal(*rawLinks, &links); err != nil {
-				log.Error().Err(err).Msg("collection JSON unmarshal failed")
+				log.Ctx(ctx).Error().Err(err).Msg("collection JSON unmarshal failed")
 				c.Status(fiber.StatusInternalServerError)
 				_ = c.JSON(stac.Message{
 					Code:        stac.JSONParsingError,
@@ -262,7 +307,7 @@ func Collections(c *fiber.Ctx) error {
 		var serializedLinks json.RawMessage
 		serializedLinks, err = json.Marshal(links)
 		if err != nil {
-			log.Error().Err(err).Msg("collection links JSON marshal failed")
+			log.Ctx(ctx).Error().Err(err).Msg("collection links JSON marshal failed")
 			c.Status(fiber.StatusInternalServerError)
 			_ = c.JSON(stac.Message{
 				Code:        stac.JSONParsingError,
@@ -275,10 +320,24 @@ func Collections(c *fiber.Ctx) error {
 		collectionType := json.RawMessage(`"Collection"`)
 		collection["type"] = &collectionType
 
+		if counts != nil {
+			var serializedStats json.RawMessage
+			if serializedStats, err = json.Marshal(counts[collectionID]); err != nil {
+				log.Ctx(ctx).Error().Err(err).Msg("collection stats JSON marshal failed")
+				c.Status(fiber.StatusInternalServerError)
+				_ = c.JSON(stac.Message{
+					Code:        stac.JSONParsingError,
+					Description: "unable to marshal collection stats to JSON",
+				})
+				return err
+			}
+			collection["stats"] = &serializedStats
+		}
+
 		var serializedCollection json.RawMessage
 		serializedCollection, err = json.Marshal(collection)
 		if err != nil {
-			log.Error().Err(err).Msg("collection JSON marshal failed")
+			log.Ctx(ctx).Error().Err(err).Msg("collection JSON marshal failed")
 			c.Status(fiber.StatusInternalServerError)
 			_ = c.JSON(stac.Message{
 				Code:        stac.JSONParsingError,
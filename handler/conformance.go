@@ -16,6 +16,7 @@
 package handler
 
 import (
+	"github.com/go-geospatial/go-stac-server/common"
 	"github.com/go-geospatial/go-stac-server/stac"
 	"github.com/gofiber/fiber/v2"
 )
@@ -24,6 +25,6 @@ func Conformance(c *fiber.Ctx) error {
 	return c.JSON(struct {
 		ConformsTo []string `json:"conformsTo"`
 	}{
-		ConformsTo: stac.Conformance,
+		ConformsTo: stac.ConformsTo(common.TransactionsEnabled()),
 	})
 }
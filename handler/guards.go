@@ -0,0 +1,166 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/go-geospatial/go-stac-server/metrics"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// collectionLimit overrides the default and maximum page size of a collection
+type collectionLimit struct {
+	ID           string `mapstructure:"id"`
+	DefaultLimit int    `mapstructure:"defaultLimit"`
+	MaxLimit     int    `mapstructure:"maxLimit"`
+}
+
+// collectionLimits holds limits.collections keyed by collection id
+var collectionLimits map[string]collectionLimit
+
+// LoadSearchLimits parses the per-collection page sizes in limits.collections.
+// It is called once at startup rather than on every search.
+func LoadSearchLimits() error {
+	var overrides []collectionLimit
+	if err := viper.UnmarshalKey("limits.collections", &overrides); err != nil {
+		return err
+	}
+
+	limits := make(map[string]collectionLimit, len(overrides))
+	for _, override := range overrides {
+		limits[override.ID] = override
+	}
+	collectionLimits = limits
+	return nil
+}
+
+// searchLimits returns the default and maximum page size for a search of
+// the collections. When several collections are searched the most
+// restrictive configuration applies.
+func searchLimits(collections []string) (defaultLimit int, maxLimit int) {
+	defaultLimit = viper.GetInt("limits.defaultLimit")
+	maxLimit = viper.GetInt("limits.maxLimit")
+
+	collectionDefault := 0
+	for _, id := range collections {
+		override, ok := collectionLimits[id]
+		if !ok {
+			continue
+		}
+		if override.MaxLimit > 0 && (maxLimit <= 0 || override.MaxLimit < maxLimit) {
+			maxLimit = override.MaxLimit
+		}
+		if override.DefaultLimit > 0 && (collectionDefault == 0 || override.DefaultLimit < collectionDefault) {
+			collectionDefault = override.DefaultLimit
+		}
+	}
+	if collectionDefault > 0 {
+		defaultLimit = collectionDefault
+	}
+
+	if defaultLimit <= 0 {
+		defaultLimit = 10
+	}
+	if maxLimit > 0 && defaultLimit > maxLimit {
+		defaultLimit = maxLimit
+	}
+	return defaultLimit, maxLimit
+}
+
+// validateComplexity rejects searches that would be too expensive to run:
+// too many ids or collections, intersects geometries with too many
+// vertices, deeply nested or very large filters and, for searches not
+// restricted to collections or ids, bboxes covering too large an area
+func validateComplexity(c *fiber.Ctx, cql stac.CQL) error {
+	reject := func(reason string, description string) error {
+		metrics.ValidationFailed(reason)
+		log.Ctx(c.UserContext()).Warn().Str("reason", description).Msg("search rejected by complexity limits")
+		c.Status(fiber.StatusBadRequest)
+		_ = c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: description,
+		})
+		return errors.New(description)
+	}
+
+	if maxIds := viper.GetInt("limits.maxIds"); maxIds > 0 && len(cql.Ids) > maxIds {
+		return reject("max_ids", fmt.Sprintf("%d ids requested; at most %d are allowed", len(cql.Ids), maxIds))
+	}
+
+	if maxCollections := viper.GetInt("limits.maxCollections"); maxCollections > 0 && len(cql.Collections) > maxCollections {
+		return reject("max_collections", fmt.Sprintf("%d collections requested; at most %d are allowed", len(cql.Collections), maxCollections))
+	}
+
+	if maxVertices := viper.GetInt("limits.maxIntersectsVertices"); maxVertices > 0 && cql.Intersects != nil {
+		vertices, err := cql.Intersects.Vertices()
+		if err != nil {
+			return reject("intersects", "could not parse intersects coordinates")
+		}
+		if vertices > maxVertices {
+			return reject("max_intersects_vertices", fmt.Sprintf("intersects geometry has %d vertices; at most %d are allowed, simplify the geometry", vertices, maxVertices))
+		}
+	}
+
+	if cql.Filter != nil {
+		var depth, nodes int
+		var filterText string
+		if err := json.Unmarshal(*cql.Filter, &filterText); err == nil {
+			depth, nodes = stac.TextFilterComplexity(filterText)
+		} else if depth, nodes, err = stac.FilterComplexity(*cql.Filter); err != nil {
+			// CQL2 text arrives unquoted from query parameters
+			depth, nodes = stac.TextFilterComplexity(string(*cql.Filter))
+		}
+
+		if maxDepth := viper.GetInt("limits.maxFilterDepth"); maxDepth > 0 && depth > maxDepth {
+			return reject("max_filter_depth", fmt.Sprintf("filter is nested %d levels deep; at most %d are allowed", depth, maxDepth))
+		}
+		if maxNodes := viper.GetInt("limits.maxFilterNodes"); maxNodes > 0 && nodes > maxNodes {
+			return reject("max_filter_nodes", fmt.Sprintf("filter has %d terms; at most %d are allowed", nodes, maxNodes))
+		}
+	}
+
+	maxArea := viper.GetFloat64("limits.maxBboxArea")
+	unrestricted := len(cql.Collections) == 0 && len(cql.Ids) == 0 && c.Params("collectionId") == ""
+	if maxArea > 0 && unrestricted && len(cql.Bbox) != 0 {
+		if area := bboxArea(cql.Bbox); area > maxArea {
+			return reject("max_bbox_area", fmt.Sprintf("bbox covers %.1f square degrees; searches across all collections may cover at most %.1f, restrict the search to collections or a smaller bbox", area, maxArea))
+		}
+	}
+
+	return nil
+}
+
+// bboxArea returns the area of a 2D or 3D bbox in square degrees; bboxes
+// crossing the antimeridian have minx > maxx
+func bboxArea(bbox []float64) float64 {
+	minX, minY, maxX, maxY := bbox[0], bbox[1], bbox[2], bbox[3]
+	if len(bbox) == 6 {
+		minX, minY, maxX, maxY = bbox[0], bbox[1], bbox[3], bbox[4]
+	}
+
+	width := maxX - minX
+	if width < 0 {
+		width += 360
+	}
+	return math.Abs(width * (maxY - minY))
+}
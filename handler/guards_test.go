@@ -0,0 +1,121 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+func TestBboxArea(t *testing.T) {
+	tests := []struct {
+		name string
+		bbox []float64
+		want float64
+	}{
+		{"2D", []float64{-10, -5, 10, 5}, 200},
+		{"3D", []float64{-10, -5, 0, 10, 5, 100}, 200},
+		{"whole world", []float64{-180, -90, 180, 90}, 64800},
+		{"crossing the antimeridian", []float64{170, 0, -170, 10}, 200},
+		{"flipped latitudes", []float64{0, 10, 10, 0}, 100},
+		{"empty", []float64{5, 5, 5, 5}, 0},
+	}
+	for _, tt := range tests {
+		if got := bboxArea(tt.bbox); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: bboxArea(%v) = %v, want %v", tt.name, tt.bbox, got, tt.want)
+		}
+	}
+}
+
+func TestValidateComplexity(t *testing.T) {
+	jsonFilter := `{"op":"and","args":[{"op":"=","args":[{"property":"a"},1]},{"op":"=","args":[{"property":"b"},2]}]}`
+	textFilter := `a = 1 AND (b = 2 OR c = 3)`
+
+	tests := []struct {
+		name   string
+		limits map[string]any
+		path   string
+		body   string
+		status int
+	}{
+		{"no limits", nil, "/search", `{"ids":["a","b","c"],"bbox":[-180,-90,180,90]}`, fiber.StatusOK},
+		{"ids within limit", map[string]any{"limits.maxIds": 3}, "/search", `{"ids":["a","b","c"]}`, fiber.StatusOK},
+		{"too many ids", map[string]any{"limits.maxIds": 2}, "/search", `{"ids":["a","b","c"]}`, fiber.StatusBadRequest},
+		{"too many collections", map[string]any{"limits.maxCollections": 1}, "/search", `{"collections":["a","b"]}`, fiber.StatusBadRequest},
+		{
+			"intersects within limit", map[string]any{"limits.maxIntersectsVertices": 5}, "/search",
+			`{"intersects":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`, fiber.StatusOK,
+		},
+		{
+			"intersects with too many vertices", map[string]any{"limits.maxIntersectsVertices": 4}, "/search",
+			`{"intersects":{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]}}`, fiber.StatusBadRequest,
+		},
+		{"json filter within depth", map[string]any{"limits.maxFilterDepth": 2}, "/search", `{"filter":` + jsonFilter + `}`, fiber.StatusOK},
+		{"json filter too deep", map[string]any{"limits.maxFilterDepth": 1}, "/search", `{"filter":` + jsonFilter + `}`, fiber.StatusBadRequest},
+		{"json filter with too many nodes", map[string]any{"limits.maxFilterNodes": 6}, "/search", `{"filter":` + jsonFilter + `}`, fiber.StatusBadRequest},
+		{"text filter within limits", map[string]any{"limits.maxFilterDepth": 1, "limits.maxFilterNodes": 11}, "/search", `{"filter":"` + textFilter + `"}`, fiber.StatusOK},
+		{"text filter too deep", map[string]any{"limits.maxFilterDepth": 1}, "/search", `{"filter":"a = 1 AND (b = 2 OR (c = 3))"}`, fiber.StatusBadRequest},
+		{"text filter with too many nodes", map[string]any{"limits.maxFilterNodes": 10}, "/search", `{"filter":"` + textFilter + `"}`, fiber.StatusBadRequest},
+		{"bbox within area", map[string]any{"limits.maxBboxArea": 100}, "/search", `{"bbox":[0,0,10,10]}`, fiber.StatusOK},
+		{"bbox too large", map[string]any{"limits.maxBboxArea": 100}, "/search", `{"bbox":[0,0,10,11]}`, fiber.StatusBadRequest},
+		{"large bbox in collections", map[string]any{"limits.maxBboxArea": 100}, "/search", `{"collections":["a"],"bbox":[-180,-90,180,90]}`, fiber.StatusOK},
+		{"large bbox with ids", map[string]any{"limits.maxBboxArea": 100}, "/search", `{"ids":["a"],"bbox":[-180,-90,180,90]}`, fiber.StatusOK},
+		{"large bbox in collection items", map[string]any{"limits.maxBboxArea": 100}, "/collections/a/items", `{"bbox":[-180,-90,180,90]}`, fiber.StatusOK},
+	}
+
+	app := fiber.New()
+	validate := func(c *fiber.Ctx) error {
+		var cql stac.CQL
+		if err := json.Unmarshal(c.Body(), &cql); err != nil {
+			return err
+		}
+		if err := validateComplexity(c, cql); err != nil {
+			return nil
+		}
+		return c.SendStatus(fiber.StatusOK)
+	}
+	app.Post("/search", validate)
+	app.Post("/collections/:collectionId/items", validate)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.limits {
+				viper.Set(key, value)
+			}
+			defer func() {
+				for key := range tt.limits {
+					viper.Set(key, nil)
+				}
+			}()
+
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
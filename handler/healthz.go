@@ -16,29 +16,51 @@
 package handler
 
 import (
-	"context"
+	"errors"
 
 	"github.com/go-geospatial/go-stac-server/database"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 )
 
-// Collection returns details of a specific collection
+// Healthz reports the health of the database and pgstac, answering 503
+// like /readyz when the database can't be reached or pgstac is missing, of an
+// unsupported version or can't be queried
 func Healthz(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 
 	overallHealth := "OK"
 	dbHealth := "OK"
 
 	pool := database.GetInstance(ctx)
 	if err := pool.Ping(ctx); err != nil {
-		log.Error().Err(err).Msg("database ping failed")
+		log.Ctx(ctx).Error().Err(err).Msg("database ping failed")
 		dbHealth = "FAILED"
 		overallHealth = "FAILED"
 	}
 
+	pgstacHealth := "OK"
+	pgstacVersion, err := database.PgstacVersion(ctx)
+	switch {
+	case errors.Is(err, database.ErrPgstacMissing):
+		pgstacHealth = "MISSING"
+	case err != nil:
+		pgstacHealth = "FAILED"
+	case !database.IsSupportedPgstac(pgstacVersion):
+		pgstacHealth = "UNSUPPORTED"
+	}
+	if pgstacHealth != "OK" {
+		log.Ctx(ctx).Error().Err(err).Str("pgstac", pgstacHealth).Str("version", pgstacVersion).Msg("pgstac health check failed")
+		overallHealth = "FAILED"
+	}
+
+	if overallHealth != "OK" {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
 	return c.JSON(map[string]string{
-		"status":   overallHealth,
-		"database": dbHealth,
+		"status":        overallHealth,
+		"database":      dbHealth,
+		"pgstac":        pgstacHealth,
+		"pgstacVersion": pgstacVersion,
 	})
 }
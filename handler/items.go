@@ -16,30 +16,35 @@
 package handler
 
 import (
-	"context"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/go-geospatial/go-stac-server/common"
 	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/ingest"
 	"github.com/go-geospatial/go-stac-server/jsonutil"
+	"github.com/go-geospatial/go-stac-server/metrics"
 	"github.com/go-geospatial/go-stac-server/stac"
 	json "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 // DeleteItem deletes an item from the database
 // DELETE /collections/:collectionId/items/:itemId
 func DeleteItem(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 	itemID := c.Params("itemId")
 
 	pool := database.GetInstance(ctx)
 	if _, err := pool.Exec(ctx, "SELECT delete_item($1::text, $2::text);", itemID, collectionID); err != nil {
-		log.Error().Err(err).Msg("received error while trying to delete item")
+		log.Ctx(ctx).Error().Err(err).Msg("received error while trying to delete item")
 		c.Status(fiber.ErrNotFound.Code)
 		return c.JSON(stac.Message{
 			Code:        "DeleteItemFailed",
@@ -47,6 +52,7 @@ func DeleteItem(c *fiber.Ctx) error {
 		})
 	}
 
+	metrics.ItemsWritten(collectionID, metrics.OperationDeleted, 1)
 	return c.JSON(stac.Message{
 		Code:        "ItemDeleted",
 		Description: "the item has been deleted",
@@ -56,14 +62,14 @@ func DeleteItem(c *fiber.Ctx) error {
 // UpdateItem updates an existing item with the provided JSON
 // PUT /collections/:collectionId/items/:itemId
 func UpdateItem(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 	itemID := c.Params("itemId")
 
 	// set collectionId and and itemId from URL
 	item := make(map[string]*json.RawMessage)
 	if err := json.Unmarshal(c.Body(), &item); err != nil {
-		log.Error().Err(err).Msg("failed to un-marshal body")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to un-marshal body")
 		c.Status(fiber.StatusUnprocessableEntity)
 		return c.JSON(stac.Message{
 			Code:        "PutItemFailed",
@@ -82,7 +88,7 @@ func UpdateItem(c *fiber.Ctx) error {
 
 	putItem, err := json.Marshal(item)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to serialize item")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to serialize item")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        "ItemSerializeFailed",
@@ -92,13 +98,14 @@ func UpdateItem(c *fiber.Ctx) error {
 
 	pool := database.GetInstance(ctx)
 	if _, err := pool.Exec(ctx, "SELECT update_item($1::text::jsonb);", putItem); err != nil {
-		log.Error().Err(err).Msg("received error while trying to update item")
+		log.Ctx(ctx).Error().Err(err).Msg("received error while trying to update item")
 		c.Status(fiber.StatusNotFound)
 		return c.JSON(stac.Message{
 			Code:        "PutItemFailed",
 			Description: fmt.Sprintf("collection %s does not contain an item with id %s", collectionID, itemID),
 		})
 	}
+	metrics.ItemsWritten(collectionID, metrics.OperationUpdated, 1)
 
 	return itemFromID(c, collectionID, itemID)
 }
@@ -106,14 +113,14 @@ func UpdateItem(c *fiber.Ctx) error {
 // PatchItem updates an item with only the specific fields provided by request body
 // PATCH /collections/:collectionId/items/:itemId
 func PatchItem(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 	itemID := c.Params("itemId")
 
 	// set collectionId and and itemId from URL
 	item := make(map[string]*json.RawMessage)
 	if err := json.Unmarshal(c.Body(), &item); err != nil {
-		log.Error().Err(err).Msg("failed to un-marshal body")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to un-marshal body")
 		c.Status(fiber.StatusUnprocessableEntity)
 		return c.JSON(stac.Message{
 			Code:        "PatchItemFailed",
@@ -131,7 +138,7 @@ func PatchItem(c *fiber.Ctx) error {
 	pool := database.GetInstance(ctx)
 	var dbItemRaw string
 	if err := pool.QueryRow(ctx, "SELECT get_item FROM get_item($1::text, $2::text);", itemID, collectionID).Scan(&dbItemRaw); err != nil {
-		log.Error().Err(err).Msg("failed load item from database")
+		log.Ctx(ctx).Error().Err(err).Msg("failed load item from database")
 		c.Status(fiber.StatusNotFound)
 		return c.JSON(stac.Message{
 			Code:        "ItemNotFound",
@@ -141,7 +148,7 @@ func PatchItem(c *fiber.Ctx) error {
 
 	patchItem, err := json.Marshal(item)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to serialize item")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to serialize item")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        "ItemSerializeFailed",
@@ -152,7 +159,7 @@ func PatchItem(c *fiber.Ctx) error {
 	// merge items
 	mergedItem, err := jsonutil.Merge(patchItem, []byte(dbItemRaw))
 	if err != nil {
-		log.Error().Err(err).Msg("failed to merge items")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to merge items")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        "MergeItem",
@@ -162,26 +169,42 @@ func PatchItem(c *fiber.Ctx) error {
 
 	// upate database
 	if _, err := pool.Exec(ctx, "SELECT update_item($1::text::jsonb);", mergedItem); err != nil {
-		log.Error().Err(err).Msg("received error while trying to update item")
+		log.Ctx(ctx).Error().Err(err).Msg("received error while trying to update item")
 		c.Status(fiber.StatusNotFound)
 		return c.JSON(stac.Message{
 			Code:        "PutItemFailed",
 			Description: fmt.Sprintf("collection %s does not contain an item with id %s", collectionID, itemID),
 		})
 	}
+	metrics.ItemsWritten(collectionID, metrics.OperationUpdated, 1)
 
 	return itemFromID(c, collectionID, itemID)
 }
 
-// CreateItems creates a new collection in the database
+// CreateItems creates new items in the database. When the async query parameter
+// is true the items are processed in the background by an ingest job.
 // POST /collections/:collectionId/items
 func CreateItems(c *fiber.Ctx) error {
+	async, err := asyncRequested(c)
+	if err != nil {
+		// http response and logging handled by asyncRequested
+		return nil
+	}
+	if async {
+		return createItemsAsync(c)
+	}
+
+	// newline delimited items are decoded while the body is streamed in
+	if ingest.IsStreamMediaType(c.Get(fiber.HeaderContentType)) {
+		return createItemsStream(c)
+	}
+
 	// validate passed JSON
 	itemsRaw := c.Body()
 	items := make(map[string]*json.RawMessage)
 
 	if err := json.Unmarshal(itemsRaw, &items); err != nil {
-		log.Error().Err(err).Str("RequestBody", string(itemsRaw)).Msg("cannot unmarshal body to items")
+		log.Ctx(c.UserContext()).Error().Err(err).Str("RequestBody", string(itemsRaw)).Msg("cannot unmarshal body to items")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -193,7 +216,7 @@ func CreateItems(c *fiber.Ctx) error {
 	var geojsonType *json.RawMessage
 	var ok bool
 	if geojsonType, ok = items["type"]; !ok {
-		log.Error().Str("RequestBody", string(itemsRaw)).Msg("items missing type field - must be a valid geojson object")
+		log.Ctx(c.UserContext()).Error().Str("RequestBody", string(itemsRaw)).Msg("items missing type field - must be a valid geojson object")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -203,7 +226,7 @@ func CreateItems(c *fiber.Ctx) error {
 
 	var geojsonTypeStr string
 	if err := json.Unmarshal(*geojsonType, &geojsonTypeStr); err != nil {
-		log.Error().Str("RequestBody", string(itemsRaw)).Msg("cannot unmarshal geojson type")
+		log.Ctx(c.UserContext()).Error().Str("RequestBody", string(itemsRaw)).Msg("cannot unmarshal geojson type")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -217,7 +240,7 @@ func CreateItems(c *fiber.Ctx) error {
 	case "FeatureCollection":
 		return createFeatureCollection(c, items, itemsRaw)
 	default:
-		log.Error().Str("type", geojsonTypeStr).Msg("invalid geojson type")
+		log.Ctx(c.UserContext()).Error().Str("type", geojsonTypeStr).Msg("invalid geojson type")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -227,7 +250,7 @@ func CreateItems(c *fiber.Ctx) error {
 }
 
 func createFeature(c *fiber.Ctx, items map[string]*json.RawMessage, itemsRaw []byte) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 	var err error
 
@@ -244,7 +267,7 @@ func createFeature(c *fiber.Ctx, items map[string]*json.RawMessage, itemsRaw []b
 
 	itemsJSON, err := json.Marshal(items)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal items to JSON")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to marshal items to JSON")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -254,26 +277,27 @@ func createFeature(c *fiber.Ctx, items map[string]*json.RawMessage, itemsRaw []b
 
 	pool := database.GetInstance(ctx)
 	if _, err := pool.Exec(ctx, "SELECT create_item($1::text::jsonb)", itemsJSON); err != nil {
-		log.Error().Err(err).Str("id", itemID).Str("raw", string(itemsRaw)).Msg("failed to create item")
+		log.Ctx(ctx).Error().Err(err).Str("id", itemID).Str("raw", string(itemsRaw)).Msg("failed to create item")
 		c.Status(fiber.ErrConflict.Code)
 		return c.JSON(stac.Message{
 			Code:        "CreateItemFailed",
 			Description: "failed to create item",
 		})
 	}
+	metrics.ItemsWritten(collectionID, metrics.OperationIngested, 1)
 
 	return itemFromID(c, collectionID, itemID)
 }
 
 func createFeatureCollection(c *fiber.Ctx, items map[string]*json.RawMessage, itemsRaw []byte) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 
 	// for each feature validate collection matches the expected collection
 	var featuresRaw *json.RawMessage
 	var ok bool
 	if featuresRaw, ok = items["features"]; !ok {
-		log.Error().Str("raw", string(itemsRaw)).Msg("failed to get features - object invalid")
+		log.Ctx(ctx).Error().Str("raw", string(itemsRaw)).Msg("failed to get features - object invalid")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -283,7 +307,7 @@ func createFeatureCollection(c *fiber.Ctx, items map[string]*json.RawMessage, it
 
 	var features []map[string]*json.RawMessage
 	if err := json.Unmarshal(*featuresRaw, &features); err != nil {
-		log.Error().Err(err).Str("raw", string(itemsRaw)).Msg("unmarshal geojson features failed")
+		log.Ctx(ctx).Error().Err(err).Str("raw", string(itemsRaw)).Msg("unmarshal geojson features failed")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -294,12 +318,12 @@ func createFeatureCollection(c *fiber.Ctx, items map[string]*json.RawMessage, it
 	itemIds := make([]string, len(features))
 	for idx, feature := range features {
 		if err := stac.ValidateCollectionIDsMatch(c, feature, collectionID); err != nil {
-			log.Error().Int("FeatureIndex", idx).Msg("failed collection ID match validation")
+			log.Ctx(ctx).Error().Int("FeatureIndex", idx).Msg("failed collection ID match validation")
 			return nil
 		}
 		itemID, err := stac.ValidateID(c, feature)
 		if err != nil {
-			log.Error().Int("FeatureIndex", idx).Msg("failed ID validation")
+			log.Ctx(ctx).Error().Int("FeatureIndex", idx).Msg("failed ID validation")
 			return nil
 		}
 		itemIds[idx] = itemID
@@ -308,7 +332,7 @@ func createFeatureCollection(c *fiber.Ctx, items map[string]*json.RawMessage, it
 	// validation has passed, create items
 	itemsJSON, err := json.Marshal(items)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal items to JSON")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to marshal items to JSON")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -318,17 +342,105 @@ func createFeatureCollection(c *fiber.Ctx, items map[string]*json.RawMessage, it
 
 	pool := database.GetInstance(ctx)
 	if _, err := pool.Exec(ctx, "SELECT create_items($1::text::jsonb)", itemsJSON); err != nil {
-		log.Error().Err(err).Strs("id", itemIds).Str("raw", string(itemsRaw)).Msg("failed to create item")
+		log.Ctx(ctx).Error().Err(err).Strs("id", itemIds).Str("raw", string(itemsRaw)).Msg("failed to create item")
 		c.Status(fiber.ErrConflict.Code)
 		return c.JSON(stac.Message{
 			Code:        "CreateItemFailed",
 			Description: "failed to create item",
 		})
 	}
+	metrics.ItemsWritten(collectionID, metrics.OperationIngested, len(itemIds))
 
 	return itemFromIDs(c, itemIds)
 }
 
+// uploadBody returns the body of an item upload, which is exempt from the
+// server's body limit and read up to maxBytes instead; reading past maxBytes
+// fails with ingest.ErrStreamTooLarge. Large bodies are streamed rather than
+// buffered since StreamRequestBody is enabled.
+func uploadBody(c *fiber.Ctx, maxBytes int64) (io.Reader, error) {
+	if length := c.Request().Header.ContentLength(); maxBytes > 0 && int64(length) > maxBytes {
+		_ = uploadTooLarge(c, fmt.Sprintf("request body of %d bytes exceeds the maximum of %d bytes", length, maxBytes))
+		return nil, ingest.ErrStreamTooLarge
+	}
+
+	var body io.Reader = c.Context().RequestBodyStream()
+	if !c.Request().IsBodyStream() {
+		body = bytes.NewReader(c.Body())
+	}
+	return ingest.LimitReader(body, maxBytes), nil
+}
+
+// uploadTooLarge answers 413 to an item upload over its limit
+func uploadTooLarge(c *fiber.Ctx, description string) error {
+	log.Ctx(c.UserContext()).Warn().Str("path", c.Path()).Msg(description)
+	// the rest of the body is left unread
+	c.Context().SetConnectionClose()
+	c.Status(fiber.StatusRequestEntityTooLarge)
+	return c.JSON(stac.Message{
+		Code:        stac.PayloadTooLargeError,
+		Description: description,
+	})
+}
+
+// streamFailure answers a streaming upload that could not be read to the end
+type streamFailure struct {
+	stac.Message
+	*ingest.Summary
+}
+
+// createItemsStream creates items from a newline delimited JSON or GeoJSON
+// text sequence body and reports which lines were inserted and rejected
+func createItemsStream(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	collectionID := c.Params("collectionId")
+
+	// make sure the requested collection exists
+	pool := database.GetInstance(ctx)
+	var dbResult string
+	if err := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID).Scan(&dbResult); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: fmt.Sprintf("collection '%s' not found", collectionID),
+		})
+	}
+
+	maxBytes := viper.GetInt64("ingest.maxBodyBytes")
+	body, err := uploadBody(c, maxBytes)
+	if err != nil {
+		// http response and logging handled by uploadBody
+		return nil
+	}
+
+	summary, err := ingest.LoadNDJSON(ctx, body, collectionID, viper.GetInt("ingest.batchSize"), ingest.ModeCreate)
+	if err != nil {
+		// the lines read before the error have been written; the summary
+		// reports them so the client knows where to resume
+		message := stac.Message{
+			Code:        stac.ParameterError,
+			Description: "failed to read request body",
+		}
+		c.Status(fiber.StatusBadRequest)
+		if errors.Is(err, ingest.ErrStreamTooLarge) {
+			// the rest of the body is left unread
+			c.Context().SetConnectionClose()
+			message.Code = stac.PayloadTooLargeError
+			message.Description = fmt.Sprintf("request body exceeds the maximum of %d bytes; only the lines listed as inserted were created", maxBytes)
+			c.Status(fiber.StatusRequestEntityTooLarge)
+		}
+		return c.JSON(streamFailure{Message: message, Summary: summary})
+	}
+
+	if len(summary.Inserted) == 0 && len(summary.Rejected) > 0 {
+		c.Status(fiber.StatusBadRequest)
+	} else {
+		c.Status(fiber.StatusCreated)
+	}
+	return c.JSON(summary)
+}
+
 // Item returns details of a specific item
 // GET /collections/:collectionId/items/:itemId
 func Item(c *fiber.Ctx) error {
@@ -339,7 +451,7 @@ func Item(c *fiber.Ctx) error {
 }
 
 func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	baseURL := getBaseURL(c)
 
 	pool := database.GetInstance(ctx)
@@ -348,7 +460,7 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 	row := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID)
 	var dbResult string
 	if err := row.Scan(&dbResult); err != nil {
-		log.Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
 		c.Status(fiber.ErrNotFound.Code)
 		return c.JSON(stac.Message{
 			Code:        stac.NotFoundError,
@@ -366,9 +478,9 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 	}
 
 	// do the search
-	featureCollection, err := stac.Search(cql)
+	featureCollection, err := stac.Search(ctx, cql)
 	if err != nil {
-		log.Error().Err(err).Msg("stac search returned an error")
+		log.Ctx(ctx).Error().Err(err).Msg("stac search returned an error")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        stac.ServerError,
@@ -378,7 +490,7 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 
 	if len(featureCollection.Features) == 0 {
 		// item not found
-		log.Error().Str("collection", collectionID).Str("item", itemID).Msg("item not found")
+		log.Ctx(ctx).Error().Str("collection", collectionID).Str("item", itemID).Msg("item not found")
 		c.Status(fiber.StatusNotFound)
 		return c.JSON(stac.Message{
 			Code:        stac.NotFoundError,
@@ -394,7 +506,7 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 		var links []stac.Link
 
 		if err := json.Unmarshal(*item["id"], &itemID); err != nil {
-			log.Error().Err(err).Msg("error de-serializing id")
+			log.Ctx(ctx).Error().Err(err).Msg("error de-serializing id")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -403,7 +515,7 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 		}
 
 		if err := json.Unmarshal(*item["links"], &links); err != nil {
-			log.Error().Err(err).Msg("error de-serializing link")
+			log.Ctx(ctx).Error().Err(err).Msg("error de-serializing link")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -423,7 +535,7 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 
 		myLinksJSON, err = json.Marshal(links)
 		if err != nil {
-			log.Error().Err(err).Msg("error serializing links")
+			log.Ctx(ctx).Error().Err(err).Msg("error serializing links")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -441,7 +553,7 @@ func itemFromID(c *fiber.Ctx, collectionID string, itemID string) error {
 // Items returns a list of items in a collection
 // GET /collections/:collectionId/items
 func Items(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	baseURL := getBaseURL(c)
 	collectionID := c.Params("collectionId")
 
@@ -451,7 +563,7 @@ func Items(c *fiber.Ctx) error {
 	row := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID)
 	var dbResult string
 	if err := row.Scan(&dbResult); err != nil {
-		log.Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
 		c.Status(fiber.ErrNotFound.Code)
 		return c.JSON(stac.Message{
 			Code:        stac.NotFoundError,
@@ -466,15 +578,19 @@ func Items(c *fiber.Ctx) error {
 		return nil
 	}
 	cql.Collections = []string{collectionID}
-	featureCollection, err := stac.Search(cql)
+	started := time.Now()
+	featureCollection, err := stac.Search(ctx, cql)
 	if err != nil {
-		log.Error().Err(err).Msg("stac search returned an error")
+		log.Ctx(ctx).Error().Err(err).Msg("stac search returned an error")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        stac.ServerError,
 			Description: "stac search returned an error",
 		})
 	}
+	// the collection was found above and the route checks the caller may see it
+	metrics.ObserveSearch(collectionID, cql.FilterLang, cql.Filter != nil, time.Since(started), len(featureCollection.Features))
+	metrics.ObservePage(cql.Token, featureCollection.Next, featureCollection.Prev)
 
 	// enrich links
 	for _, item := range featureCollection.Features {
@@ -483,7 +599,7 @@ func Items(c *fiber.Ctx) error {
 		var links []stac.Link
 
 		if err := json.Unmarshal(*item["id"], &itemID); err != nil {
-			log.Error().Err(err).Msg("error de-serializing id")
+			log.Ctx(ctx).Error().Err(err).Msg("error de-serializing id")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -492,7 +608,7 @@ func Items(c *fiber.Ctx) error {
 		}
 
 		if err := json.Unmarshal(*item["links"], &links); err != nil {
-			log.Error().Err(err).Msg("error de-serializing link")
+			log.Ctx(ctx).Error().Err(err).Msg("error de-serializing link")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -512,7 +628,7 @@ func Items(c *fiber.Ctx) error {
 
 		myLinksJSON, err = json.Marshal(links)
 		if err != nil {
-			log.Error().Err(err).Msg("error serializing links")
+			log.Ctx(ctx).Error().Err(err).Msg("error serializing links")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -570,7 +686,7 @@ func Items(c *fiber.Ctx) error {
 }
 
 func itemFromIDs(c *fiber.Ctx, ids []string) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	baseURL := getBaseURL(c)
 	collectionID := c.Params("collectionId")
 
@@ -580,7 +696,7 @@ func itemFromIDs(c *fiber.Ctx, ids []string) error {
 	row := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID)
 	var dbResult string
 	if err := row.Scan(&dbResult); err != nil {
-		log.Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
 		c.Status(fiber.ErrNotFound.Code)
 		return c.JSON(stac.Message{
 			Code:        stac.NotFoundError,
@@ -597,9 +713,9 @@ func itemFromIDs(c *fiber.Ctx, ids []string) error {
 		Conf:        &conf,
 	}
 
-	featureCollection, err := stac.Search(cql)
+	featureCollection, err := stac.Search(ctx, cql)
 	if err != nil {
-		log.Error().Err(err).Msg("stac search returned an error")
+		log.Ctx(ctx).Error().Err(err).Msg("stac search returned an error")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        stac.ServerError,
@@ -614,7 +730,7 @@ func itemFromIDs(c *fiber.Ctx, ids []string) error {
 		var links []stac.Link
 
 		if err := json.Unmarshal(*item["id"], &itemID); err != nil {
-			log.Error().Err(err).Msg("error de-serializing id")
+			log.Ctx(ctx).Error().Err(err).Msg("error de-serializing id")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -623,7 +739,7 @@ func itemFromIDs(c *fiber.Ctx, ids []string) error {
 		}
 
 		if err := json.Unmarshal(*item["links"], &links); err != nil {
-			log.Error().Err(err).Msg("error de-serializing link")
+			log.Ctx(ctx).Error().Err(err).Msg("error de-serializing link")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -643,7 +759,7 @@ func itemFromIDs(c *fiber.Ctx, ids []string) error {
 
 		myLinksJSON, err = json.Marshal(links)
 		if err != nil {
-			log.Error().Err(err).Msg("error serializing links")
+			log.Ctx(ctx).Error().Err(err).Msg("error serializing links")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -697,7 +813,7 @@ func checkBodyIDAgainstURL(c *fiber.Ctx, collectionID string, itemID string, ite
 		var itemIDSerialized json.RawMessage
 		itemIDSerialized, err := json.Marshal(itemID)
 		if err != nil {
-			log.Error().Msg("body does not include item id")
+			log.Ctx(c.UserContext()).Error().Msg("body does not include item id")
 			c.Status(fiber.StatusBadRequest)
 			_ = c.JSON(stac.Message{
 				Code:        "ModifyItemFailed",
@@ -707,7 +823,7 @@ func checkBodyIDAgainstURL(c *fiber.Ctx, collectionID string, itemID string, ite
 		}
 		item["id"] = &itemIDSerialized
 	} else if string(*bodyItemID) != itemID {
-		log.Error().Str("BodyItemId", string(*bodyItemID)).Str("URLItemId", itemID).Msg("PUT body item id does not match URL item id")
+		log.Ctx(c.UserContext()).Error().Str("BodyItemId", string(*bodyItemID)).Str("URLItemId", itemID).Msg("PUT body item id does not match URL item id")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        "ModifyItemFailed",
@@ -720,7 +836,7 @@ func checkBodyIDAgainstURL(c *fiber.Ctx, collectionID string, itemID string, ite
 		var collectionSerialized json.RawMessage
 		collectionSerialized, err := json.Marshal(collectionID)
 		if err != nil {
-			log.Error().Err(err).Msg("could not serialize collection id")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not serialize collection id")
 			c.Status(fiber.StatusInternalServerError)
 			_ = c.JSON(stac.Message{
 				Code:        "ModifyItemFailed",
@@ -730,7 +846,7 @@ func checkBodyIDAgainstURL(c *fiber.Ctx, collectionID string, itemID string, ite
 		}
 		item[stac.CollectionKey] = &collectionSerialized
 	} else if string(*bodyCollectionID) != collectionID {
-		log.Error().Str("BodyCollectionId", string(*bodyCollectionID)).Str("URLCollectionId", itemID).Msg("PUT body collection id does not match URL collection id")
+		log.Ctx(c.UserContext()).Error().Str("BodyCollectionId", string(*bodyCollectionID)).Str("URLCollectionId", itemID).Msg("PUT body collection id does not match URL collection id")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        "ModifyItemFailed",
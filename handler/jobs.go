@@ -0,0 +1,211 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/ingest"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+type jobResponse struct {
+	*ingest.Job
+	Links []stac.Link `json:"links"`
+}
+
+// Job returns the progress and status of an asynchronous ingest job
+// GET /jobs/:jobId
+func Job(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	jobID := c.Params("jobId")
+
+	job, err := ingest.GetJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, ingest.ErrJobNotFound) {
+			c.Status(fiber.StatusNotFound)
+			return c.JSON(stac.Message{
+				Code:        stac.NotFoundError,
+				Description: fmt.Sprintf("job '%s' not found", jobID),
+			})
+		}
+
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        database.QueryErrorCode,
+			Description: "could not query job",
+		})
+	}
+
+	return c.JSON(newJobResponse(c, job))
+}
+
+// asyncRequested reports whether ?async=true asks for the items to be stored
+// as an ingest job. A value other than true or false is rejected rather than
+// quietly creating the items synchronously.
+func asyncRequested(c *fiber.Ctx) (bool, error) {
+	value := c.Query("async")
+	if value == "" {
+		return false, nil
+	}
+	async, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Ctx(c.UserContext()).Warn().Str("async", value).Msg("invalid async parameter")
+		c.Status(fiber.StatusBadRequest)
+		_ = c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: fmt.Sprintf("async must be true or false, got '%s'", value),
+		})
+		return false, err
+	}
+	return async, nil
+}
+
+// createItemsAsync stores the submitted items as an ingest job and returns
+// 202 Accepted with a link to monitor the job. The body is exempt from the
+// server's body limit: newline delimited items are streamed into the job under
+// ingest.maxBodyBytes and a Feature or FeatureCollection, which has to be
+// parsed whole, is read up to jobs.maxBodyBytes.
+// POST /collections/:collectionId/items?async=true
+func createItemsAsync(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	collectionID := c.Params("collectionId")
+
+	// make sure the requested collection exists
+	pool := database.GetInstance(ctx)
+	var dbResult string
+	if err := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID).Scan(&dbResult); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: fmt.Sprintf("collection '%s' not found", collectionID),
+		})
+	}
+
+	var jobID string
+	if ingest.IsStreamMediaType(c.Get(fiber.HeaderContentType)) {
+		maxBytes := viper.GetInt64("ingest.maxBodyBytes")
+		body, err := uploadBody(c, maxBytes)
+		if err != nil {
+			// http response and logging handled by uploadBody
+			return nil
+		}
+		if jobID, err = ingest.SubmitJobStream(ctx, collectionID, body); err != nil {
+			return submitJobFailed(c, err, maxBytes)
+		}
+	} else {
+		maxBytes := viper.GetInt64("jobs.maxBodyBytes")
+		body, err := uploadBody(c, maxBytes)
+		if err != nil {
+			// http response and logging handled by uploadBody
+			return nil
+		}
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return submitJobFailed(c, fmt.Errorf("%w: %w", ingest.ErrStreamRead, err), maxBytes)
+		}
+		features, err := jobFeatures(raw)
+		if err != nil {
+			c.Status(fiber.StatusBadRequest)
+			return c.JSON(stac.Message{
+				Code:        stac.ParameterError,
+				Description: err.Error(),
+			})
+		}
+		if jobID, err = ingest.SubmitJob(ctx, collectionID, features); err != nil {
+			return submitJobFailed(c, err, maxBytes)
+		}
+	}
+
+	job, err := ingest.GetJob(ctx, jobID)
+	if err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        database.QueryErrorCode,
+			Description: "could not query job",
+		})
+	}
+
+	c.Set(fiber.HeaderLocation, fmt.Sprintf("%s/api/stac/v1/jobs/%s", getBaseURL(c), jobID))
+	c.Status(fiber.StatusAccepted)
+	return c.JSON(newJobResponse(c, job))
+}
+
+// jobFeatures returns the items of a Feature or FeatureCollection body
+func jobFeatures(raw []byte) ([]json.RawMessage, error) {
+	var body struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, errors.New("JSON parse failed; items must be a valid JSON object")
+	}
+
+	switch body.Type {
+	case "Feature":
+		return []json.RawMessage{raw}, nil
+	case "FeatureCollection":
+		if body.Features == nil {
+			return nil, errors.New("objects of type 'FeatureCollection' must have a 'features' field")
+		}
+		return body.Features, nil
+	default:
+		return nil, errors.New("invalid geojson type - must be one of 'Feature' or 'FeatureCollection'")
+	}
+}
+
+// submitJobFailed answers an upload whose job could not be stored; nothing
+// is stored when the body could not be read to the end
+func submitJobFailed(c *fiber.Ctx, err error, maxBytes int64) error {
+	if errors.Is(err, ingest.ErrStreamTooLarge) {
+		return uploadTooLarge(c, fmt.Sprintf("request body exceeds the maximum of %d bytes; no job was created", maxBytes))
+	}
+	if errors.Is(err, ingest.ErrStreamRead) {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: "failed to read request body",
+		})
+	}
+	c.Status(fiber.StatusInternalServerError)
+	return c.JSON(stac.Message{
+		Code:        database.QueryErrorCode,
+		Description: "could not store ingest job",
+	})
+}
+
+func newJobResponse(c *fiber.Ctx, job *ingest.Job) jobResponse {
+	baseURL := getBaseURL(c)
+
+	links := make([]stac.Link, 0, 3)
+	links = stac.AddLink(links, baseURL, "self", fmt.Sprintf("/jobs/%s", job.ID), "application/json")
+	links = stac.AddLink(links, baseURL, stac.CollectionKey, fmt.Sprintf("/collections/%s", job.CollectionID), "application/json")
+	links = stac.AddLink(links, baseURL, "root", "/", "application/json")
+
+	return jobResponse{
+		Job:   job,
+		Links: links,
+	}
+}
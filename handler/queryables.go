@@ -16,7 +16,8 @@
 package handler
 
 import (
-	"context"
+	"errors"
+	"fmt"
 
 	"github.com/go-geospatial/go-stac-server/database"
 	"github.com/go-geospatial/go-stac-server/stac"
@@ -26,7 +27,7 @@ import (
 )
 
 func Queryables(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := c.UserContext()
 	collectionID := c.Params("collectionId")
 	var raw json.RawMessage
 
@@ -37,7 +38,7 @@ func Queryables(c *fiber.Ctx) error {
 
 	pool := database.GetInstance(ctx)
 	if err := pool.QueryRow(ctx, "SELECT get_queryables FROM get_queryables($1::text)", pCollectionID).Scan(&raw); err != nil {
-		log.Error().Err(err).Str("collection", collectionID).Msg("failed to get queryables from database")
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to get queryables from database")
 		c.Status(fiber.StatusInternalServerError)
 		return c.JSON(stac.Message{
 			Code:        "DatabaseError",
@@ -47,3 +48,202 @@ func Queryables(c *fiber.Ctx) error {
 
 	return c.JSON(raw)
 }
+
+// queryableFromRequest parses the body into a queryable named by the URL
+// and scoped to the collection in the URL, if any
+func queryableFromRequest(c *fiber.Ctx) (*stac.Queryable, error) {
+	var queryable stac.Queryable
+	if err := json.Unmarshal(c.Body(), &queryable); err != nil {
+		return nil, errors.New("failed to parse http body as JSON")
+	}
+
+	name := c.Params("name")
+	if queryable.Name != "" && queryable.Name != name {
+		return nil, errors.New("queryable name in body does not match name in URL")
+	}
+	queryable.Name = name
+
+	queryable.CollectionIDs = nil
+	if collectionID := c.Params("collectionId"); collectionID != "" {
+		queryable.CollectionIDs = []string{collectionID}
+	}
+
+	if err := queryable.Validate(); err != nil {
+		return nil, err
+	}
+	return &queryable, nil
+}
+
+// reindexFailed reports that the queryable was stored but its index could not be built
+func reindexFailed(c *fiber.Ctx) error {
+	c.Status(fiber.StatusInternalServerError)
+	return c.JSON(stac.Message{
+		Code:        "DatabaseError",
+		Description: "queryable saved but failed to create indexes",
+	})
+}
+
+// CreateQueryable registers a new queryable
+// POST /queryables/:name
+// POST /collections/:collectionId/queryables/:name
+func CreateQueryable(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	queryable, err := queryableFromRequest(c)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: err.Error(),
+		})
+	}
+
+	if err := stac.CreateQueryable(ctx, queryable); err != nil {
+		if errors.Is(err, stac.ErrQueryableExists) {
+			c.Status(fiber.StatusConflict)
+			return c.JSON(stac.Message{
+				Code:        "QueryableExists",
+				Description: "a queryable with this name already exists; use PUT to update it",
+			})
+		}
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        "DatabaseError",
+			Description: "failed to create queryable",
+		})
+	}
+
+	// ?reindex=true creates any index requested by property_index_type
+	if c.QueryBool("reindex") {
+		if err := stac.ReindexQueryables(ctx); err != nil {
+			return reindexFailed(c)
+		}
+	}
+
+	c.Status(fiber.StatusCreated)
+	return c.JSON(queryable)
+}
+
+// UpdateQueryable replaces an existing queryable
+// PUT /queryables/:name
+// PUT /collections/:collectionId/queryables/:name
+func UpdateQueryable(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	queryable, err := queryableFromRequest(c)
+	if err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: err.Error(),
+		})
+	}
+
+	if err := stac.UpdateQueryable(ctx, queryable); err != nil {
+		if errors.Is(err, stac.ErrQueryableNotFound) {
+			c.Status(fiber.StatusNotFound)
+			return c.JSON(stac.Message{
+				Code:        "QueryableNotFound",
+				Description: "cannot find queryable; failed to update",
+			})
+		}
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        "DatabaseError",
+			Description: "failed to update queryable",
+		})
+	}
+
+	// ?reindex=true creates any index requested by property_index_type
+	if c.QueryBool("reindex") {
+		if err := stac.ReindexQueryables(ctx); err != nil {
+			return reindexFailed(c)
+		}
+	}
+
+	return c.JSON(queryable)
+}
+
+// DeleteQueryable removes a queryable
+// DELETE /queryables/:name
+// DELETE /collections/:collectionId/queryables/:name
+func DeleteQueryable(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	if err := stac.DeleteQueryable(ctx, c.Params("name"), c.Params("collectionId")); err != nil {
+		if errors.Is(err, stac.ErrQueryableNotFound) {
+			c.Status(fiber.StatusNotFound)
+			return c.JSON(stac.Message{
+				Code:        "QueryableNotFound",
+				Description: "cannot find queryable; failed to delete",
+			})
+		}
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        "DatabaseError",
+			Description: "failed to delete queryable",
+		})
+	}
+
+	return c.JSON(stac.Message{
+		Code:        "QueryableDeleted",
+		Description: "the queryable has been deleted",
+	})
+}
+
+// DiscoverQueryables samples the items of a collection and proposes queryables
+// for their properties. With ?register=true the proposals are created.
+// POST /collections/:collectionId/queryables:discover
+func DiscoverQueryables(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	collectionID := c.Params("collectionId")
+
+	opts := stac.DiscoverOptions{
+		SampleSize:    c.QueryInt("sample", 1000),
+		MaxEnumValues: c.QueryInt("maxEnum", 20),
+	}
+	if opts.SampleSize < 1 || opts.MaxEnumValues < 0 {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: "sample must be positive and maxEnum must not be negative",
+		})
+	}
+
+	// make sure the requested collection exists
+	pool := database.GetInstance(ctx)
+	var dbResult string
+	if err := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID).Scan(&dbResult); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: fmt.Sprintf("collection '%s' not found", collectionID),
+		})
+	}
+
+	queryables, err := stac.DiscoverQueryables(ctx, collectionID, opts)
+	if err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        "DatabaseError",
+			Description: "failed to sample collection items",
+		})
+	}
+
+	registered := make([]stac.Queryable, 0)
+	if c.QueryBool("register") {
+		if registered, err = stac.RegisterQueryables(ctx, queryables); err != nil {
+			c.Status(fiber.StatusInternalServerError)
+			return c.JSON(stac.Message{
+				Code:        "DatabaseError",
+				Description: "failed to register discovered queryables",
+			})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"proposed":   queryables,
+		"registered": registered,
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// startedAt marks the start of the startup grace period
+var startedAt = time.Now()
+
+// healthCheck is the outcome of one readiness check
+type healthCheck struct {
+	Status    string         `json:"status"`
+	LatencyMs float64        `json:"latencyMs"`
+	Message   string         `json:"message,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+type readinessCheck struct {
+	name string
+	run  func(ctx context.Context) (map[string]any, error)
+}
+
+var readinessChecks = []readinessCheck{
+	{name: "database", run: checkDatabase},
+	{name: "pgstac", run: checkPgstac},
+	{name: "pool", run: checkPool},
+	{name: "search", run: checkSearch},
+}
+
+// Livez reports that the process is up and serving requests. It doesn't
+// touch the database so an outage doesn't get the server restarted.
+// GET /livez
+func Livez(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "OK"})
+}
+
+// Readyz runs the readiness checks concurrently and answers 503 unless all
+// of them pass. During the startup grace period failures are reported as
+// STARTING rather than FAILED.
+// GET /readyz
+func Readyz(c *fiber.Ctx) error {
+	timeout := viper.GetDuration("server.health.timeout")
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	results := make(map[string]healthCheck, len(readinessChecks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range readinessChecks {
+		wg.Add(1)
+		go func(check readinessCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			details, err := check.run(ctx)
+			result := healthCheck{
+				Status:    "OK",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = "FAILED"
+				result.Message = err.Error()
+			}
+
+			mu.Lock()
+			results[check.name] = result
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	status := "OK"
+	for name, result := range results {
+		if result.Status != "OK" {
+			status = "FAILED"
+			if !starting() {
+				log.Ctx(c.UserContext()).Error().Str("check", name).Str("message", result.Message).Msg("readiness check failed")
+			}
+		}
+	}
+	if status != "OK" && starting() {
+		status = "STARTING"
+	}
+
+	if status != "OK" {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(fiber.Map{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// starting reports whether the startup grace period is still running
+func starting() bool {
+	return time.Since(startedAt) < viper.GetDuration("server.health.startupGrace")
+}
+
+func checkDatabase(ctx context.Context) (map[string]any, error) {
+	return nil, database.GetInstance(ctx).Ping(ctx)
+}
+
+func checkPgstac(ctx context.Context) (map[string]any, error) {
+	version, err := database.PgstacVersion(ctx)
+	if err != nil {
+		if errors.Is(err, database.ErrPgstacMissing) {
+			return nil, errors.New("pgstac schema not found")
+		}
+		return nil, err
+	}
+
+	details := map[string]any{"version": version}
+	if !database.IsSupportedPgstac(version) {
+		return details, fmt.Errorf("pgstac %s is not supported; supported versions are >= %s and < %s", version, database.MinPgstacVersion, database.MaxPgstacVersion)
+	}
+	return details, nil
+}
+
+// checkPool fails when more than server.health.maxPoolSaturation of the
+// pool's connections are in use
+func checkPool(ctx context.Context) (map[string]any, error) {
+	stat := database.GetInstance(ctx).Stat()
+	saturation := 0.0
+	if stat.MaxConns() > 0 {
+		saturation = float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+	}
+
+	details := map[string]any{
+		"acquired":   stat.AcquiredConns(),
+		"idle":       stat.IdleConns(),
+		"total":      stat.TotalConns(),
+		"max":        stat.MaxConns(),
+		"saturation": saturation,
+	}
+	if limit := viper.GetFloat64("server.health.maxPoolSaturation"); limit > 0 && saturation > limit {
+		return details, fmt.Errorf("%d of %d connections in use", stat.AcquiredConns(), stat.MaxConns())
+	}
+	return details, nil
+}
+
+// checkSearch runs a one item search through pgstac
+func checkSearch(ctx context.Context) (map[string]any, error) {
+	var result []byte
+	err := database.GetInstance(ctx).QueryRow(ctx, `SELECT search FROM search('{"limit": 1}'::jsonb)`).Scan(&result)
+	return nil, err
+}
@@ -18,8 +18,11 @@ package handler
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/go-geospatial/go-stac-server/auth"
 	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/metrics"
 	"github.com/go-geospatial/go-stac-server/stac"
 	json "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
@@ -60,16 +63,40 @@ func Search(c *fiber.Ctx) error {
 		cql.Token = token
 	}
 
+	// label metrics with the collections asked for, not the visible ones
+	// access control may add; only existing collections the caller may see
+	// are used as labels
+	principal := auth.FromContext(c)
+	collectionLabel := metrics.CollectionLabel(cql.Collections, func(id string) bool {
+		exists, err := stac.IsCollection(c.UserContext(), id)
+		return err == nil && exists && auth.CanAccessCollection(principal, id)
+	})
+
+	// hidden collections never appear in search results
+	allCollections := func() ([]string, error) {
+		return stac.CollectionIDs(c.UserContext())
+	}
+	if err := auth.RestrictCollections(principal, &cql, allCollections); err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        stac.ServerError,
+			Description: "could not determine visible collections",
+		})
+	}
+
 	// do the search
-	featureCollection, err := stac.Search(cql)
+	started := time.Now()
+	featureCollection, err := stac.Search(c.UserContext(), cql)
 	if err != nil {
-		log.Error().Err(err).Msg("stac search returned an error")
+		log.Ctx(c.UserContext()).Error().Err(err).Msg("stac search returned an error")
 		c.Status(fiber.StatusBadRequest)
 		return c.JSON(stac.Message{
 			Code:        stac.ParameterError,
 			Description: err.Error(),
 		})
 	}
+	metrics.ObserveSearch(collectionLabel, cql.FilterLang, cql.Filter != nil, time.Since(started), len(featureCollection.Features))
+	metrics.ObservePage(cql.Token, featureCollection.Next, featureCollection.Prev)
 
 	// enrich links
 	for _, item := range featureCollection.Features {
@@ -78,7 +105,7 @@ func Search(c *fiber.Ctx) error {
 		var links []stac.Link
 
 		if err := json.Unmarshal(*item["id"], &itemID); err != nil {
-			log.Error().Err(err).Msg("error de-serializing id")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("error de-serializing id")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -87,7 +114,7 @@ func Search(c *fiber.Ctx) error {
 		}
 
 		if err := json.Unmarshal(*item["links"], &links); err != nil {
-			log.Error().Err(err).Msg("error de-serializing link")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("error de-serializing link")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -97,7 +124,7 @@ func Search(c *fiber.Ctx) error {
 
 		var collectionID string
 		if err := json.Unmarshal(*item["collection"], &collectionID); err != nil {
-			log.Error().Err(err).Msg("error de-serializing collectionId")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("error de-serializing collectionId")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -118,7 +145,7 @@ func Search(c *fiber.Ctx) error {
 
 		myLinksJSON, err = json.Marshal(links)
 		if err != nil {
-			log.Error().Err(err).Msg("error serializing links")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("error serializing links")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -161,7 +188,7 @@ func Search(c *fiber.Ctx) error {
 	case "POST":
 		var jsonRaw json.RawMessage
 		if jsonRaw, err = json.Marshal(cql); err != nil {
-			log.Error().Err(err).Msg("error serializing cql")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("error serializing cql")
 			c.Status(fiber.StatusInternalServerError)
 			return c.JSON(stac.Message{
 				Code:        stac.ServerError,
@@ -174,7 +201,7 @@ func Search(c *fiber.Ctx) error {
 			var jsonRaw json.RawMessage
 			cql.Token = featureCollection.Next
 			if jsonRaw, err = json.Marshal(cql); err != nil {
-				log.Error().Err(err).Msg("error serializing cql")
+				log.Ctx(c.UserContext()).Error().Err(err).Msg("error serializing cql")
 				c.Status(fiber.StatusInternalServerError)
 				return c.JSON(stac.Message{
 					Code:        stac.ServerError,
@@ -187,7 +214,7 @@ func Search(c *fiber.Ctx) error {
 			var jsonRaw json.RawMessage
 			cql.Token = featureCollection.Prev
 			if jsonRaw, err = json.Marshal(cql); err != nil {
-				log.Error().Err(err).Msg("error serializing cql")
+				log.Ctx(c.UserContext()).Error().Err(err).Msg("error serializing cql")
 				c.Status(fiber.StatusInternalServerError)
 				return c.JSON(stac.Message{
 					Code:        stac.ServerError,
@@ -0,0 +1,160 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5"
+)
+
+// settingValue is the body of a setting update
+type settingValue struct {
+	Value *string `json:"value"`
+}
+
+// requestPrincipal identifies who made a change for audit records
+func requestPrincipal(c *fiber.Ctx) string {
+	return auth.FromContext(c).Name
+}
+
+func settingsDatabaseError(c *fiber.Ctx, description string) error {
+	c.Status(fiber.StatusInternalServerError)
+	return c.JSON(stac.Message{
+		Code:        "DatabaseError",
+		Description: description,
+	})
+}
+
+// Settings lists the pgstac settings that can be managed
+// GET /settings
+func Settings(c *fiber.Ctx) error {
+	settings, err := database.GetSettings(c.UserContext())
+	if err != nil {
+		return settingsDatabaseError(c, "failed to get settings from database")
+	}
+	return c.JSON(fiber.Map{"settings": settings})
+}
+
+// Setting returns a single pgstac setting
+// GET /settings/:name
+func Setting(c *fiber.Ctx) error {
+	setting, err := database.GetSetting(c.UserContext(), c.Params("name"))
+	if errors.Is(err, database.ErrUnknownSetting) {
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: "unknown setting",
+		})
+	}
+	if err != nil {
+		return settingsDatabaseError(c, "failed to get setting from database")
+	}
+	return c.JSON(setting)
+}
+
+// UpdateSetting changes a pgstac setting
+// PUT /settings/:name
+func UpdateSetting(c *fiber.Ctx) error {
+	var body settingValue
+	if err := json.Unmarshal(c.Body(), &body); err != nil || body.Value == nil {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: `body must be a JSON object of the form {"value": "..."}`,
+		})
+	}
+
+	change, err := database.SetSetting(c.UserContext(), c.Params("name"), *body.Value, requestPrincipal(c))
+	switch {
+	case errors.Is(err, database.ErrUnknownSetting):
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: err.Error(),
+		})
+	case errors.Is(err, database.ErrInvalidSetting):
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: err.Error(),
+		})
+	case err != nil:
+		return settingsDatabaseError(c, "failed to update setting")
+	}
+	return c.JSON(change)
+}
+
+// UpdatePartitionTrunc changes how a collection's items are partitioned
+// PUT /collections/:collectionId/settings/partition_trunc
+func UpdatePartitionTrunc(c *fiber.Ctx) error {
+	var body settingValue
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: `body must be a JSON object of the form {"value": "year" | "month" | null}`,
+		})
+	}
+
+	value := ""
+	if body.Value != nil {
+		value = *body.Value
+	}
+
+	change, err := database.SetPartitionTrunc(c.UserContext(), c.Params("collectionId"), value, requestPrincipal(c))
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: "collection not found",
+		})
+	case errors.Is(err, database.ErrInvalidSetting):
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: err.Error(),
+		})
+	case err != nil:
+		return settingsDatabaseError(c, "failed to update partition_trunc")
+	}
+	return c.JSON(change)
+}
+
+// SettingHistory lists recent setting changes
+// GET /settings/history
+func SettingHistory(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 1000 {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: "limit must be between 1 and 1000",
+		})
+	}
+
+	changes, err := database.SettingHistory(c.UserContext(), c.Query("name"), limit)
+	if err != nil {
+		return settingsDatabaseError(c, "failed to get setting history from database")
+	}
+	return c.JSON(fiber.Map{"changes": changes})
+}
@@ -0,0 +1,58 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"fmt"
+
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// Sortables lists the fields that can be used in sortby
+// GET /sortables
+// GET /collections/:collectionId/sortables
+func Sortables(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	collectionID := c.Params("collectionId")
+	baseURL := getBaseURL(c)
+
+	var collections []string
+	id := fmt.Sprintf("%s/sortables", baseURL)
+	if collectionID != "" {
+		collections = []string{collectionID}
+		id = fmt.Sprintf("%s/collections/%s/sortables", baseURL, collectionID)
+	}
+
+	sortables, err := stac.Sortables(ctx, collections)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to get sortables from database")
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        "DatabaseError",
+			Description: "failed to get sortables from database",
+		})
+	}
+
+	return c.JSON(map[string]any{
+		"$schema":    "https://json-schema.org/draft/2019-09/schema",
+		"$id":        id,
+		"type":       "object",
+		"title":      "Sortables",
+		"properties": sortables,
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// CollectionStatistics returns item counts, temporal and spatial extents and
+// storage size of a collection. ?refresh=true bypasses the cache.
+// GET /collections/:collectionId/statistics
+func CollectionStatistics(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	collectionID := c.Params("collectionId")
+
+	refresh, err := statsRefresh(c)
+	if err != nil {
+		// http response and logging handled by statsRefresh
+		return nil
+	}
+
+	stats, err := stac.GetCollectionStatistics(ctx, collectionID, viper.GetDuration("stats.cacheTtl"), refresh)
+	if errors.Is(err, stac.ErrCollectionNotFound) {
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: fmt.Sprintf("collection '%s' not found", collectionID),
+		})
+	}
+	if err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        database.QueryErrorCode,
+			Description: "could not compute collection statistics",
+		})
+	}
+
+	return c.JSON(stats)
+}
+
+// statsRefresh reports whether ?refresh=true asks to recompute cached
+// statistics. Recomputing scans every item of a collection so only writers
+// may ask for it.
+func statsRefresh(c *fiber.Ctx) (bool, error) {
+	if !c.QueryBool("refresh") {
+		return false, nil
+	}
+	if !auth.Permits(c, auth.RoleWriter) {
+		principal := auth.FromContext(c)
+		log.Ctx(c.UserContext()).Warn().Str("principal", principal.Name).Str("Path", c.Path()).Msg("statistics refresh requires the writer role")
+		c.Status(fiber.StatusForbidden)
+		_ = c.JSON(stac.Message{
+			Code:        auth.ForbiddenError,
+			Description: "the writer role is required to refresh statistics",
+		})
+		return false, errors.New("statistics refresh requires the writer role")
+	}
+	return true, nil
+}
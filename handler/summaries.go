@@ -0,0 +1,98 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// RecomputeSummaries rebuilds a collection's summaries and item_assets from
+// its items. ?dryRun=true returns the result without updating the collection;
+// a ?sample= scan can only be previewed that way, and a collection without
+// items is left unchanged.
+// POST /collections/:collectionId/summaries:recompute
+func RecomputeSummaries(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	collectionID := c.Params("collectionId")
+
+	opts := stac.DiscoverOptions{
+		SampleSize:    c.QueryInt("sample", 0),
+		MaxEnumValues: c.QueryInt("maxEnum", 20),
+	}
+	if opts.SampleSize < 0 || opts.MaxEnumValues < 0 {
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: "sample and maxEnum must not be negative",
+		})
+	}
+
+	// make sure the requested collection exists
+	pool := database.GetInstance(ctx)
+	var dbResult string
+	if err := pool.QueryRow(ctx, "SELECT id FROM pgstac.collections WHERE id=$1", collectionID).Scan(&dbResult); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collectionId", collectionID).Msg("collection does not exist in database")
+		c.Status(fiber.StatusNotFound)
+		return c.JSON(stac.Message{
+			Code:        stac.NotFoundError,
+			Description: fmt.Sprintf("collection '%s' not found", collectionID),
+		})
+	}
+
+	dryRun := c.QueryBool("dryRun")
+	computed, err := stac.RecomputeSummaries(ctx, collectionID, opts, dryRun, viper.GetBool("queryables.fromSummaries"))
+	switch {
+	case errors.Is(err, stac.ErrSummariesSampled):
+		c.Status(fiber.StatusBadRequest)
+		return c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: "sample can only be used with dryRun=true; summaries of a sample would replace those of every item",
+		})
+	case errors.Is(err, stac.ErrNoItemsScanned):
+		c.Status(fiber.StatusConflict)
+		return c.JSON(stac.Message{
+			Code:        "NoItems",
+			Description: fmt.Sprintf("collection '%s' has no items; its summaries and item_assets were left unchanged", collectionID),
+		})
+	case err != nil && computed == nil:
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        database.QueryErrorCode,
+			Description: "failed to scan collection items",
+		})
+	case err != nil:
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        "UpdateCollectionFailed",
+			Description: "failed to update collection summaries",
+		})
+	}
+
+	return c.JSON(struct {
+		*stac.ComputedSummaries
+		DryRun bool `json:"dry_run"`
+	}{
+		ComputedSummaries: computed,
+		DryRun:            dryRun,
+	})
+}
@@ -22,10 +22,12 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/go-geospatial/go-stac-server/metrics"
 	"github.com/go-geospatial/go-stac-server/stac"
 	json "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
 )
 
 var CQLJSON = "cql-json"
@@ -49,53 +51,86 @@ func buildQueryArray(c *fiber.Ctx) []string {
 func getCQLFromBody(c *fiber.Ctx) (stac.CQL, error) {
 	var cql stac.CQL
 	if err := json.Unmarshal(c.Body(), &cql); err != nil {
-		log.Error().Err(err).Msg("could not parse search body")
+		metrics.ValidationFailed("body")
+		log.Ctx(c.UserContext()).Error().Err(err).Msg("could not parse search body")
 		c.Status(fiber.StatusBadRequest)
-		return stac.CQL{}, c.JSON(stac.Message{
+		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
 			Description: "could not parse search body",
 		})
+		return stac.CQL{}, errors.New("invalid search body")
 	}
 
 	// check if sort is a string
 	var sortBy interface{}
 	if cql.SortBy != nil {
 		if err := json.Unmarshal(*cql.SortBy, &sortBy); err != nil {
-			log.Error().Err(err).Msg("could not parse sort by field")
+			metrics.ValidationFailed("sortby")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not parse sort by field")
 			c.Status(fiber.StatusBadRequest)
-			return stac.CQL{}, c.JSON(stac.Message{
+			_ = c.JSON(stac.Message{
 				Code:        stac.ParameterError,
 				Description: "could not parse sort by",
 			})
+			return stac.CQL{}, errors.New("invalid sortby")
 		}
 	}
 
 	if sortByStr, ok := sortBy.(string); ok {
 		sort, err := parseSort(c, sortByStr)
 		if err != nil {
-			log.Error().Err(err).Msg("could not parse sort by field")
+			metrics.ValidationFailed("sortby")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not parse sort by field")
 			c.Status(fiber.StatusBadRequest)
-			return stac.CQL{}, c.JSON(stac.Message{
+			_ = c.JSON(stac.Message{
 				Code:        stac.ParameterError,
 				Description: "could not parse sort by",
 			})
+			return stac.CQL{}, errors.New("invalid sortby")
 		}
 
 		var sortJson json.RawMessage
 		sortJson, err = json.Marshal(sort)
 		if err != nil {
-			log.Error().Err(err).Msg("could not serialize sort by field")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not serialize sort by field")
 			c.Status(fiber.StatusBadRequest)
-			return stac.CQL{}, c.JSON(stac.Message{
+			_ = c.JSON(stac.Message{
 				Code:        stac.ParameterError,
 				Description: "could not serialize sort by",
 			})
+			return stac.CQL{}, errors.New("invalid sortby")
 		}
 		cql.SortBy = &sortJson
 	}
 
+	if cql.SortBy != nil {
+		var sort []stac.CQLSort
+		if err := json.Unmarshal(*cql.SortBy, &sort); err != nil {
+			metrics.ValidationFailed("sortby")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not parse sort by field")
+			c.Status(fiber.StatusBadRequest)
+			_ = c.JSON(stac.Message{
+				Code:        stac.ParameterError,
+				Description: "sortby must be a string or a list of objects with field and direction",
+			})
+			return stac.CQL{}, errors.New("invalid sortby")
+		}
+		if err := validateSortFields(c, cql.Collections, sort); err != nil {
+			metrics.ValidationFailed("sortby")
+			// http response and logging handled by validateSortFields
+			return stac.CQL{}, err
+		}
+	}
+
+	if err := validateFilterProperties(c, cql.Collections, cql); err != nil {
+		metrics.ValidationFailed("queryables")
+		// http response and logging handled by validateFilterProperties
+		return stac.CQL{}, err
+	}
+
 	if len(cql.Bbox) != 0 && cql.Intersects != nil {
-		log.Error().Msg("cannot specify both bbox and intersects")
+		metrics.ValidationFailed("bbox_intersects")
+		log.Ctx(c.UserContext()).Error().Msg("cannot specify both bbox and intersects")
 		c.Status(fiber.StatusBadRequest)
 		c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -105,18 +140,26 @@ func getCQLFromBody(c *fiber.Ctx) (stac.CQL, error) {
 	}
 
 	// update default value for limit
+	defaultLimit, maxLimit := searchLimits(cql.Collections)
 	if cql.Limit == 0 {
-		cql.Limit = 10
+		cql.Limit = defaultLimit
 	}
 
-	if limit, err := validateLimit(c, cql.Limit); err == nil {
+	if limit, err := validateLimit(c, cql.Limit, maxLimit); err == nil {
 		cql.Limit = limit
 	} else {
+		metrics.ValidationFailed("limit")
 		return stac.CQL{}, err
 	}
 
 	// validate bbox
 	if _, err := validateBbox(c, cql.Bbox); err != nil {
+		metrics.ValidationFailed("bbox")
+		return stac.CQL{}, err
+	}
+
+	if err := validateComplexity(c, cql); err != nil {
+		// http response and logging handled by validateComplexity
 		return stac.CQL{}, err
 	}
 
@@ -130,7 +173,7 @@ func getCQLFromBody(c *fiber.Ctx) (stac.CQL, error) {
 func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	collectionsStr := c.Query("collections", "")
 	idsStr := c.Query("ids", "")
-	limitStr := c.Query("limit", "10")
+	limitStr := c.Query("limit", "")
 	intersectsStr := c.Query("intersects", "")
 	bboxStr := c.Query("bbox", "")
 	dateStr := c.Query("datetime", "")
@@ -141,7 +184,8 @@ func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	token := c.Query("token", "")
 
 	if bboxStr != "" && intersectsStr != "" {
-		log.Error().Msg("cannot specify both bbox and intersects")
+		metrics.ValidationFailed("bbox_intersects")
+		log.Ctx(c.UserContext()).Error().Msg("cannot specify both bbox and intersects")
 		c.Status(fiber.StatusBadRequest)
 		c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -156,22 +200,35 @@ func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	// parse IDs
 	ids := parseStringList(idsStr)
 
+	// on /collections/:collectionId/items the collection comes from the URL
+	urlCollections := collections
+	if collectionID := c.Params("collectionId"); collectionID != "" {
+		urlCollections = []string{collectionID}
+	}
+
 	// parse limit
-	limit, err := parseLimit(c, limitStr)
-	if err != nil {
-		// response and logging handled by parseLimit
-		return stac.CQL{}, err
+	defaultLimit, maxLimit := searchLimits(urlCollections)
+	limit := defaultLimit
+	if limitStr != "" {
+		var err error
+		if limit, err = parseLimit(c, limitStr, maxLimit); err != nil {
+			metrics.ValidationFailed("limit")
+			// response and logging handled by parseLimit
+			return stac.CQL{}, err
+		}
 	}
 
 	// parse bbox
 	bbox, err := parseBboxQuery(c, bboxStr)
 	if err != nil {
+		metrics.ValidationFailed("bbox")
 		// response and logging handled by parseBbox
 		return stac.CQL{}, err
 	}
 
 	// parse date string (must be RFC 3339)
 	if err := parseRFC3339Date(c, dateStr); err != nil {
+		metrics.ValidationFailed("datetime")
 		// http response and logging handled by parseRFC3339Date
 		return stac.CQL{}, err
 	}
@@ -179,6 +236,7 @@ func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	// parse CQL-2 filter
 	var filter *json.RawMessage
 	if filter, filterLang, err = parseCQL2Filter(c, filterStr, filterLang); err != nil {
+		metrics.ValidationFailed("filter")
 		// http response and logging handled by parseCQL2Filter
 		return stac.CQL{}, err
 	}
@@ -186,13 +244,21 @@ func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	// parse sortby
 	var sort []stac.CQLSort
 	if sort, err = parseSort(c, sortByStr); err != nil {
+		metrics.ValidationFailed("sortby")
 		// http response and logging handled by parseSort
 		return stac.CQL{}, err
 	}
 
+	if err = validateSortFields(c, urlCollections, sort); err != nil {
+		metrics.ValidationFailed("sortby")
+		// http response and logging handled by validateSortFields
+		return stac.CQL{}, err
+	}
+
 	// parse fields
 	var fields stac.CQLFields
 	if fields, err = parseFields(c, fieldStr); err != nil {
+		metrics.ValidationFailed("fields")
 		// http response and logging handled by parseFields
 		return stac.CQL{}, err
 	}
@@ -200,6 +266,7 @@ func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	// parse intersects
 	var intersects *stac.GeoJSON
 	if intersects, err = parseIntersects(c, intersectsStr); err != nil {
+		metrics.ValidationFailed("intersects")
 		// http response and logging handled by parseIntersectsQuery
 		return stac.CQL{}, err
 	}
@@ -243,12 +310,23 @@ func getCQLFromQuery(c *fiber.Ctx) (stac.CQL, error) {
 	if sortByStr != "" {
 		var rawJson json.RawMessage
 		if rawJson, err = json.Marshal(sort); err != nil {
-			log.Error().Err(err).Msg("could not serialize sort")
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not serialize sort")
 		} else {
 			cql.SortBy = &rawJson
 		}
 	}
 
+	if err = validateFilterProperties(c, urlCollections, cql); err != nil {
+		metrics.ValidationFailed("queryables")
+		// http response and logging handled by validateFilterProperties
+		return stac.CQL{}, err
+	}
+
+	if err = validateComplexity(c, cql); err != nil {
+		// http response and logging handled by validateComplexity
+		return stac.CQL{}, err
+	}
+
 	return cql, nil
 }
 
@@ -267,7 +345,8 @@ func parseSort(c *fiber.Ctx, sortByStr string) ([]stac.CQLSort, error) {
 		sortRe := regexp.MustCompile(`^([\+-]?)(.*)$`)
 		tokens := strings.Split(sortByStr, ",")
 		for _, token := range tokens {
-			groups := sortRe.FindStringSubmatch(token)
+			// a leading + arrives as a space when the query string isn't escaped
+			groups := sortRe.FindStringSubmatch(strings.TrimSpace(token))
 			if len(groups) > 0 {
 				direction := "asc"
 				if groups[1] == "-" {
@@ -279,7 +358,7 @@ func parseSort(c *fiber.Ctx, sortByStr string) ([]stac.CQLSort, error) {
 				})
 			} else {
 				err := errors.New("sort field does not match regex")
-				log.Error().Err(err).Msg("sort field does not match regex")
+				log.Ctx(c.UserContext()).Error().Err(err).Msg("sort field does not match regex")
 				c.Status(fiber.StatusInternalServerError)
 				_ = c.JSON(stac.Message{
 					Code:        stac.ServerError,
@@ -293,6 +372,107 @@ func parseSort(c *fiber.Ctx, sortByStr string) ([]stac.CQLSort, error) {
 	return sort, nil
 }
 
+// validateSortFields checks every sort field is sortable for the requested collections
+func validateSortFields(c *fiber.Ctx, collections []string, sort []stac.CQLSort) error {
+	if len(sort) == 0 {
+		return nil
+	}
+
+	sortables, err := stac.Sortables(c.UserContext(), collections)
+	if err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		_ = c.JSON(stac.Message{
+			Code:        stac.ServerError,
+			Description: "could not determine sortable fields",
+		})
+		return err
+	}
+
+	for _, s := range sort {
+		if _, ok := sortables[stac.SortableName(s.Field)]; !ok {
+			err := fmt.Errorf("field %q is not sortable", s.Field)
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("invalid sortby")
+			c.Status(fiber.StatusBadRequest)
+			_ = c.JSON(stac.Message{
+				Code:        stac.ParameterError,
+				Description: fmt.Sprintf("field '%s' is not sortable; sortable fields are: %s", s.Field, strings.Join(stac.SortableNames(sortables), ", ")),
+			})
+			return err
+		}
+		if s.Direction != "" && s.Direction != "asc" && s.Direction != "desc" {
+			err := fmt.Errorf("invalid sort direction %q", s.Direction)
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("invalid sortby")
+			c.Status(fiber.StatusBadRequest)
+			_ = c.JSON(stac.Message{
+				Code:        stac.ParameterError,
+				Description: "sort direction must be one of: asc, desc",
+			})
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFilterProperties rejects filters and query expressions that use
+// properties which aren't queryable for the requested collections. It only
+// applies when filter.strict is enabled.
+func validateFilterProperties(c *fiber.Ctx, collections []string, cql stac.CQL) error {
+	if !viper.GetBool("filter.strict") || (cql.Filter == nil && cql.Query == nil) {
+		return nil
+	}
+
+	properties := make([]string, 0)
+	if cql.Filter != nil {
+		var filterProperties []string
+		var filterText string
+		if err := json.Unmarshal(*cql.Filter, &filterText); err == nil {
+			// CQL2 text posted as a JSON string
+			filterProperties = stac.TextFilterProperties(filterText)
+		} else if filterProperties, err = stac.FilterProperties(*cql.Filter); err != nil {
+			// CQL2 text arrives unquoted from query parameters
+			filterProperties = stac.TextFilterProperties(string(*cql.Filter))
+		}
+		properties = append(properties, filterProperties...)
+	}
+	if cql.Query != nil {
+		queryProperties, err := stac.QueryProperties(*cql.Query)
+		if err != nil {
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("could not parse query")
+			c.Status(fiber.StatusBadRequest)
+			_ = c.JSON(stac.Message{
+				Code:        stac.ParameterError,
+				Description: "query must be an object of property names to expressions",
+			})
+			return err
+		}
+		properties = append(properties, queryProperties...)
+	}
+
+	queryables, err := stac.QueryableNames(c.UserContext(), collections)
+	if err != nil {
+		c.Status(fiber.StatusInternalServerError)
+		_ = c.JSON(stac.Message{
+			Code:        stac.ServerError,
+			Description: "could not determine queryables",
+		})
+		return err
+	}
+
+	if unknown := stac.UnknownProperties(properties, queryables); len(unknown) > 0 {
+		err := fmt.Errorf("unknown queryables: %s", strings.Join(unknown, ", "))
+		log.Ctx(c.UserContext()).Error().Err(err).Msg("filter references unknown queryables")
+		c.Status(fiber.StatusBadRequest)
+		_ = c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: fmt.Sprintf("filter references properties that are not queryable: %s; see /queryables", strings.Join(unknown, ", ")),
+		})
+		return err
+	}
+
+	return nil
+}
+
 func parseFields(c *fiber.Ctx, fieldStr string) (stac.CQLFields, error) {
 	var fields stac.CQLFields
 	if fieldStr != "" {
@@ -311,7 +491,7 @@ func parseFields(c *fiber.Ctx, fieldStr string) (stac.CQLFields, error) {
 				}
 			} else {
 				err := errors.New("sort field does not match regex")
-				log.Error().Err(err).Msg("sort field does not match regex")
+				log.Ctx(c.UserContext()).Error().Err(err).Msg("sort field does not match regex")
 				c.Status(fiber.StatusInternalServerError)
 				_ = c.JSON(stac.Message{
 					Code:        stac.ServerError,
@@ -325,10 +505,10 @@ func parseFields(c *fiber.Ctx, fieldStr string) (stac.CQLFields, error) {
 	return fields, nil
 }
 
-func parseLimit(c *fiber.Ctx, limitStr string) (int, error) {
+func parseLimit(c *fiber.Ctx, limitStr string, maxLimit int) (int, error) {
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
-		log.Error().Err(err).Str("limit", limitStr).Msg("could not convert limit to int")
+		log.Ctx(c.UserContext()).Error().Err(err).Str("limit", limitStr).Msg("could not convert limit to int")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -337,17 +517,29 @@ func parseLimit(c *fiber.Ctx, limitStr string) (int, error) {
 		return 0, err
 	}
 
-	return validateLimit(c, limit)
+	return validateLimit(c, limit, maxLimit)
 }
 
-func validateLimit(c *fiber.Ctx, limit int) (int, error) {
-	if limit > 10_000 {
-		log.Warn().Int("limit", limit).Msg("limit out of bounds: limit > 10,000")
-		return 10_000, nil
+// validateLimit lowers limits above maxLimit to maxLimit, or rejects them
+// when limits.rejectOverLimit is set; a maxLimit of 0 allows any page size
+func validateLimit(c *fiber.Ctx, limit int, maxLimit int) (int, error) {
+	if maxLimit > 0 && limit > maxLimit && viper.GetBool("limits.rejectOverLimit") {
+		err := errors.New("limit out of bounds")
+		log.Ctx(c.UserContext()).Warn().Int("limit", limit).Int("maxLimit", maxLimit).Msg("limit out of bounds: limit > maxLimit")
+		c.Status(fiber.StatusBadRequest)
+		_ = c.JSON(stac.Message{
+			Code:        stac.ParameterError,
+			Description: fmt.Sprintf("limit '%d' must be at most %d", limit, maxLimit),
+		})
+		return 0, err
+	}
+	if maxLimit > 0 && limit > maxLimit {
+		log.Ctx(c.UserContext()).Warn().Int("limit", limit).Int("maxLimit", maxLimit).Msg("limit out of bounds: lowered to the maximum")
+		return maxLimit, nil
 	}
 	if limit < 0 {
 		err := errors.New("limit out of bounds")
-		log.Warn().Int("limit", limit).Msg("limit out of bounds: limit < 0")
+		log.Ctx(c.UserContext()).Warn().Int("limit", limit).Msg("limit out of bounds: limit < 0")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -393,7 +585,7 @@ func parseBboxQuery(c *fiber.Ctx, bboxStr string) ([]float64, error) {
 		for _, bboxCoord := range bboxParts {
 			var coord float64
 			if coord, err = strconv.ParseFloat(bboxCoord, 64); err != nil {
-				log.Error().Err(err).Str("Coord", bboxCoord).Msg("could not convert bbox coordinate to float64")
+				log.Ctx(c.UserContext()).Error().Err(err).Str("Coord", bboxCoord).Msg("could not convert bbox coordinate to float64")
 				c.Status(fiber.StatusBadRequest)
 				_ = c.JSON(stac.Message{
 					Code:        stac.ParameterError,
@@ -411,7 +603,7 @@ func parseBboxQuery(c *fiber.Ctx, bboxStr string) ([]float64, error) {
 func validateBbox(c *fiber.Ctx, bbox []float64) ([]float64, error) {
 	if len(bbox) != 0 && len(bbox) != 4 && len(bbox) != 6 {
 		err := errors.New("bbox must be length 4 or 6")
-		log.Error().Err(err).Floats64("bbox", bbox).Int("len", len(bbox)).Msg("bbox invalid length. must be 4 or 6.")
+		log.Ctx(c.UserContext()).Error().Err(err).Floats64("bbox", bbox).Int("len", len(bbox)).Msg("bbox invalid length. must be 4 or 6.")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -422,7 +614,7 @@ func validateBbox(c *fiber.Ctx, bbox []float64) ([]float64, error) {
 
 	if len(bbox) == 4 && bbox[1] > bbox[3] {
 		err := errors.New("bbox lat1 > lat2")
-		log.Error().Err(err).Floats64("bbox", bbox).Msg("lat1 > lat2")
+		log.Ctx(c.UserContext()).Error().Err(err).Floats64("bbox", bbox).Msg("lat1 > lat2")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -433,7 +625,7 @@ func validateBbox(c *fiber.Ctx, bbox []float64) ([]float64, error) {
 
 	if len(bbox) == 6 && bbox[1] > bbox[4] {
 		err := errors.New("bbox lat1 > lat2")
-		log.Error().Err(err).Floats64("bbox", bbox).Msg("lat1 > lat2")
+		log.Ctx(c.UserContext()).Error().Err(err).Floats64("bbox", bbox).Msg("lat1 > lat2")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
@@ -449,7 +641,7 @@ func parseIntersects(c *fiber.Ctx, intersectsStr string) (*stac.GeoJSON, error)
 	var intersects stac.GeoJSON
 	if intersectsStr != "" {
 		if err := json.Unmarshal([]byte(intersectsStr), &intersects); err != nil {
-			log.Error().Err(err).Str("intersects", intersectsStr).Msg("error parsing GeoJson intersects query")
+			log.Ctx(c.UserContext()).Error().Err(err).Str("intersects", intersectsStr).Msg("error parsing GeoJson intersects query")
 			c.Status(fiber.StatusBadRequest)
 			_ = c.JSON(stac.Message{
 				Code:        stac.ParameterError,
@@ -474,7 +666,7 @@ func parseCQL2Filter(c *fiber.Ctx, filterStr string, filterLang string) (*json.R
 		jsonRaw = []byte(filterStr)
 	default:
 		err := errors.New("filter-lang must be one of 'cql2-text' or 'cql2-json'")
-		log.Error().Err(err).Str("filter-lang", filterLang).Msg("invalid filter-lang provided")
+		log.Ctx(c.UserContext()).Error().Err(err).Str("filter-lang", filterLang).Msg("invalid filter-lang provided")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(stac.Message{
 			Code:        stac.ParameterError,
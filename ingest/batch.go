@@ -0,0 +1,171 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ingest loads STAC collections and items into pgstac in batches. It
+// backs the asynchronous ingest jobs and streaming uploads exposed through the
+// HTTP API as well as the ingest command.
+package ingest
+
+import (
+	"context"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/metrics"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Mode controls how InsertBatch writes items to the database
+type Mode int
+
+const (
+	// ModeCreate creates items and fails on items that already exist
+	ModeCreate Mode = iota
+	// ModeUpsert creates new items and replaces existing items
+	ModeUpsert
+	// ModeValidate only validates items without touching the database
+	ModeValidate
+)
+
+// Feature is a single item waiting to be inserted along with its position in
+// the submitted payload
+type Feature struct {
+	Index  int
+	Source string
+	Raw    json.RawMessage
+}
+
+// Failure describes an item that could not be inserted
+type Failure struct {
+	Index  int    `json:"index"`
+	Source string `json:"source,omitempty"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// batchDB is where a batch of items is written; *pgxpool.Pool and pgx.Tx
+// satisfy it. A transaction nests each write in a savepoint.
+type batchDB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// InsertBatch validates features and creates the valid ones in collectionID
+// with a single call to create_items (or upsert_items). If the batch insert
+// fails each item is retried individually so failures can be attributed to
+// specific items. Returns the number of items created and the items that
+// failed.
+func InsertBatch(ctx context.Context, collectionID string, features []Feature, mode Mode) (int, []Failure) {
+	// validating doesn't need a database connection
+	if mode == ModeValidate {
+		return insertBatch(ctx, nil, collectionID, features, mode)
+	}
+
+	created, failures := insertBatch(ctx, database.GetInstance(ctx), collectionID, features, mode)
+	metrics.ItemsWritten(collectionID, metrics.OperationIngested, created)
+	return created, failures
+}
+
+// insertBatch is InsertBatch writing to db, which lets ingest jobs record
+// their progress in the same transaction as the items
+func insertBatch(ctx context.Context, db batchDB, collectionID string, features []Feature, mode Mode) (int, []Failure) {
+	failures := make([]Failure, 0)
+	valid := make([]Feature, 0, len(features))
+	ids := make([]string, 0, len(features))
+
+	for _, feature := range features {
+		obj := make(map[string]*json.RawMessage)
+		if err := json.Unmarshal(feature.Raw, &obj); err != nil {
+			metrics.ValidationFailed("item_json")
+			failures = append(failures, Failure{
+				Index:  feature.Index,
+				Source: feature.Source,
+				Reason: "item must be a valid JSON object",
+			})
+			continue
+		}
+
+		id, err := stac.CheckID(obj)
+		if err != nil {
+			metrics.ValidationFailed("item_id")
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, Reason: err.Error()})
+			continue
+		}
+
+		if err := stac.CheckCollectionID(obj, collectionID); err != nil {
+			metrics.ValidationFailed("item_collection")
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, ID: id, Reason: err.Error()})
+			continue
+		}
+
+		valid = append(valid, feature)
+		ids = append(ids, id)
+	}
+
+	if len(valid) == 0 || mode == ModeValidate {
+		return len(valid), failures
+	}
+
+	batchQuery, itemQuery := "SELECT create_items($1::text::jsonb)", "SELECT create_item($1::text::jsonb)"
+	if mode == ModeUpsert {
+		batchQuery, itemQuery = "SELECT upsert_items($1::text::jsonb)", "SELECT upsert_item($1::text::jsonb)"
+	}
+
+	raws := make([]json.RawMessage, len(valid))
+	for idx, feature := range valid {
+		raws[idx] = feature.Raw
+	}
+
+	batchJSON, err := json.Marshal(raws)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to marshal item batch")
+		for idx, feature := range valid {
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, ID: ids[idx], Reason: "could not serialize item"})
+		}
+		return 0, failures
+	}
+
+	if err = execIsolated(ctx, db, batchQuery, batchJSON); err == nil {
+		return len(valid), failures
+	}
+	log.Ctx(ctx).Warn().Err(err).Str("collection", collectionID).Int("count", len(valid)).Msg("batch insert failed; retrying items individually")
+
+	created := 0
+	for idx, feature := range valid {
+		if err := execIsolated(ctx, db, itemQuery, []byte(feature.Raw)); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Str("id", ids[idx]).Msg("failed to create item")
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, ID: ids[idx], Reason: err.Error()})
+			continue
+		}
+		created++
+	}
+
+	return created, failures
+}
+
+// execIsolated runs query in its own transaction, or savepoint when db is a
+// transaction, so a failure doesn't abort the writes around it
+func execIsolated(ctx context.Context, db batchDB, query string, arg []byte) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, query, arg); err != nil {
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	return tx.Commit(ctx)
+}
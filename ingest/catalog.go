@@ -0,0 +1,458 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// hierarchyRels are link relations that only make sense inside a static
+// catalog; the server generates its own when serving collections and items
+var hierarchyRels = []string{"self", "root", "parent", "child", "item"}
+
+// Report summarizes the result of loading a catalog
+type Report struct {
+	Collections    int64
+	Items          int64
+	FailedItems    int64
+	CollectionErrs []Failure
+	ItemErrs       []Failure
+}
+
+// Loader loads a static STAC catalog from the local filesystem
+type Loader struct {
+	Mode      Mode
+	BatchSize int
+	Parallel  int
+
+	batches chan batch
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	report  Report
+}
+
+type batch struct {
+	collectionID string
+	paths        []string
+	features     []Feature
+}
+
+// LoadCatalog walks the static catalog, collection, item or item collection
+// at path and loads every collection and item reachable through child and item
+// links. Collections are written before their items; items are inserted in
+// batches by Parallel workers.
+func (l *Loader) LoadCatalog(ctx context.Context, path string) (*Report, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = findRoot(path)
+	}
+
+	l.start(ctx)
+	stop := l.progress()
+	err := l.walk(ctx, path, "", make(map[string]bool))
+	l.finish()
+	stop()
+
+	return &l.report, err
+}
+
+// LoadNDJSONFile loads a newline delimited file of items into collectionID.
+// Like the items of a catalog they are inserted in batches by Parallel
+// workers, and failures are numbered by line.
+func (l *Loader) LoadNDJSONFile(ctx context.Context, path string, collectionID string) (*Report, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return &l.report, err
+	}
+	defer fh.Close()
+
+	l.start(ctx)
+	stop := l.progress()
+
+	invalid := make([]Failure, 0)
+	b := batch{collectionID: collectionID}
+	err = ReadRecords(fh, func(line int, raw json.RawMessage) error {
+		if raw == nil {
+			invalid = append(invalid, Failure{Index: line, Source: path, Reason: "line is not valid JSON"})
+			return nil
+		}
+		b.features = append(b.features, Feature{Index: line, Source: path, Raw: raw})
+		if len(b.features) >= l.BatchSize {
+			l.batches <- b
+			b = batch{collectionID: collectionID}
+		}
+		return nil
+	})
+	// the lines read before a read error are still loaded
+	if len(b.features) > 0 {
+		l.batches <- b
+	}
+	l.finish()
+	stop()
+
+	l.report.FailedItems += int64(len(invalid))
+	l.report.ItemErrs = append(l.report.ItemErrs, invalid...)
+	sort.Slice(l.report.ItemErrs, func(i, j int) bool {
+		return l.report.ItemErrs[i].Index < l.report.ItemErrs[j].Index
+	})
+
+	return &l.report, err
+}
+
+// findRoot returns the entry point of a static catalog stored in dir
+func findRoot(dir string) string {
+	for _, name := range []string{"catalog.json", "collection.json"} {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return filepath.Join(dir, "catalog.json")
+}
+
+func (l *Loader) start(ctx context.Context) {
+	if l.BatchSize <= 0 {
+		l.BatchSize = 500
+	}
+	if l.Parallel <= 0 {
+		l.Parallel = 1
+	}
+
+	l.batches = make(chan batch, l.Parallel)
+	for i := 0; i < l.Parallel; i++ {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			for b := range l.batches {
+				l.insert(ctx, b)
+			}
+		}()
+	}
+}
+
+func (l *Loader) finish() {
+	close(l.batches)
+	l.wg.Wait()
+}
+
+// progress periodically logs how much has been loaded until the returned
+// function is called
+func (l *Loader) progress() func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.Info().
+					Int64("collections", atomic.LoadInt64(&l.report.Collections)).
+					Int64("items", atomic.LoadInt64(&l.report.Items)).
+					Int64("failed", atomic.LoadInt64(&l.report.FailedItems)).
+					Msg("ingest progress")
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
+
+func (l *Loader) walk(ctx context.Context, path string, collectionID string, visited map[string]bool) error {
+	path = filepath.Clean(path)
+	if visited[path] {
+		return nil
+	}
+	visited[path] = true
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("path", path).Msg("failed to read STAC file")
+		return err
+	}
+
+	obj := make(map[string]*json.RawMessage)
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("path", path).Msg("STAC file is not a JSON object")
+		return fmt.Errorf("%s: not a valid JSON object", path)
+	}
+
+	stacType := objectType(obj)
+
+	links, err := readLinks(obj)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch stacType {
+	case "Catalog":
+		// items directly below a catalog are validated against their own collection field
+		collectionID = ""
+	case "Collection":
+		if collectionID, err = l.writeCollection(ctx, obj); err != nil {
+			l.mu.Lock()
+			l.report.CollectionErrs = append(l.report.CollectionErrs, Failure{Source: path, Reason: err.Error()})
+			l.mu.Unlock()
+			// the items cannot be loaded without their collection
+			return nil
+		}
+	case "Feature":
+		l.queueItems(collectionID, []string{path})
+		return nil
+	case "FeatureCollection":
+		return l.queueFeatureCollection(path, obj, collectionID)
+	default:
+		return fmt.Errorf("%s: unsupported STAC type '%s'", path, stacType)
+	}
+
+	itemPaths := make([]string, 0)
+	for _, link := range links {
+		if link.Rel != "item" {
+			continue
+		}
+		if itemPath, ok := resolveHref(path, link.Href); ok {
+			itemPaths = append(itemPaths, itemPath)
+		}
+	}
+	l.queueItems(collectionID, itemPaths)
+
+	for _, link := range links {
+		if link.Rel != "child" {
+			continue
+		}
+		if childPath, ok := resolveHref(path, link.Href); ok {
+			// a child that can't be loaded is reported and its siblings are
+			// still loaded
+			if err := l.walk(ctx, childPath, collectionID, visited); err != nil {
+				l.mu.Lock()
+				l.report.CollectionErrs = append(l.report.CollectionErrs, Failure{Source: childPath, Reason: err.Error()})
+				l.mu.Unlock()
+			}
+		}
+	}
+
+	return nil
+}
+
+// objectType returns the STAC type of obj. Collections and catalogs written
+// before STAC 1.0.0 have no type field so it is inferred from their fields.
+func objectType(obj map[string]*json.RawMessage) string {
+	var stacType string
+	if rawType, ok := obj["type"]; ok {
+		_ = json.Unmarshal(*rawType, &stacType)
+	}
+
+	if stacType == "" {
+		if _, ok := obj["extent"]; ok {
+			return "Collection"
+		}
+		if _, ok := obj["description"]; ok {
+			return "Catalog"
+		}
+	}
+
+	return stacType
+}
+
+// IsNDJSON reports whether the file at path holds more than one JSON value
+// separated by newlines
+func IsNDJSON(path string) bool {
+	fh, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer fh.Close()
+
+	reader := bufio.NewReader(fh)
+	first, err := reader.ReadBytes('\n')
+	if err != nil {
+		return false
+	}
+	first = bytes.TrimSpace(bytes.TrimLeft(first, recordSeparator))
+	if !json.Valid(first) {
+		return false
+	}
+
+	rest, err := reader.Peek(1)
+	return err == nil && len(rest) > 0
+}
+
+func readLinks(obj map[string]*json.RawMessage) ([]stac.Link, error) {
+	links := make([]stac.Link, 0)
+	if rawLinks, ok := obj["links"]; ok {
+		if err := json.Unmarshal(*rawLinks, &links); err != nil {
+			return nil, errors.New("links must be an array of link objects")
+		}
+	}
+	return links, nil
+}
+
+// resolveHref resolves a link href relative to the file containing it. Remote
+// links cannot be followed.
+func resolveHref(path string, href string) (string, bool) {
+	href = strings.TrimPrefix(href, "file://")
+	if strings.Contains(href, "://") {
+		log.Warn().Str("href", href).Str("path", path).Msg("skipping remote link")
+		return "", false
+	}
+	if filepath.IsAbs(href) {
+		return href, true
+	}
+	return filepath.Join(filepath.Dir(path), href), true
+}
+
+func (l *Loader) writeCollection(ctx context.Context, obj map[string]*json.RawMessage) (string, error) {
+	id, err := stac.CheckID(obj)
+	if err != nil {
+		return "", err
+	}
+
+	if err := stac.StripLinks(obj, hierarchyRels...); err != nil {
+		return id, err
+	}
+
+	if l.Mode != ModeValidate {
+		collectionJSON, err := json.Marshal(obj)
+		if err != nil {
+			return id, err
+		}
+
+		query := "SELECT create_collection($1::text::jsonb)"
+		if l.Mode == ModeUpsert {
+			query = "SELECT upsert_collection($1::text::jsonb)"
+		}
+
+		pool := database.GetInstance(ctx)
+		if _, err := pool.Exec(ctx, query, collectionJSON); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("id", id).Msg("failed to create collection")
+			return id, err
+		}
+	}
+
+	atomic.AddInt64(&l.report.Collections, 1)
+	log.Ctx(ctx).Info().Str("id", id).Msg("loaded collection")
+	return id, nil
+}
+
+func (l *Loader) queueItems(collectionID string, paths []string) {
+	for start := 0; start < len(paths); start += l.BatchSize {
+		end := start + l.BatchSize
+		if end > len(paths) {
+			end = len(paths)
+		}
+		l.batches <- batch{collectionID: collectionID, paths: paths[start:end]}
+	}
+}
+
+func (l *Loader) queueFeatureCollection(path string, obj map[string]*json.RawMessage, collectionID string) error {
+	var features []json.RawMessage
+	if rawFeatures, ok := obj["features"]; !ok || json.Unmarshal(*rawFeatures, &features) != nil {
+		return fmt.Errorf("%s: objects of type 'FeatureCollection' must have a valid 'features' field", path)
+	}
+
+	for start := 0; start < len(features); start += l.BatchSize {
+		end := start + l.BatchSize
+		if end > len(features) {
+			end = len(features)
+		}
+		b := batch{collectionID: collectionID, features: make([]Feature, 0, end-start)}
+		for idx := start; idx < end; idx++ {
+			// features are numbered from 1 like the lines of an NDJSON
+			// file; an index of 0 is left for files holding a single item
+			b.features = append(b.features, Feature{Index: idx + 1, Source: path, Raw: features[idx]})
+		}
+		l.batches <- b
+	}
+
+	return nil
+}
+
+// insert reads and writes one batch of items. Items are grouped by their
+// collection so items found below a catalog can still be batched.
+func (l *Loader) insert(ctx context.Context, b batch) {
+	features := b.features
+	failures := make([]Failure, 0)
+	for _, path := range b.paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			failures = append(failures, Failure{Source: path, Reason: err.Error()})
+			continue
+		}
+		features = append(features, Feature{Source: path, Raw: raw})
+	}
+
+	groups := make(map[string][]Feature)
+	for _, feature := range features {
+		obj := make(map[string]*json.RawMessage)
+		if err := json.Unmarshal(feature.Raw, &obj); err != nil {
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, Reason: "item must be a valid JSON object"})
+			continue
+		}
+
+		if err := stac.StripLinks(obj, hierarchyRels...); err != nil {
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, Reason: err.Error()})
+			continue
+		}
+
+		collectionID := b.collectionID
+		if collectionID == "" {
+			if rawCollection, ok := obj["collection"]; ok {
+				_ = json.Unmarshal(*rawCollection, &collectionID)
+			}
+		}
+
+		serialized, err := json.Marshal(obj)
+		if err != nil {
+			failures = append(failures, Failure{Index: feature.Index, Source: feature.Source, Reason: "could not serialize item"})
+			continue
+		}
+		feature.Raw = serialized
+		groups[collectionID] = append(groups[collectionID], feature)
+	}
+
+	var created int
+	for collectionID, group := range groups {
+		n, groupFailures := InsertBatch(ctx, collectionID, group, l.Mode)
+		created += n
+		failures = append(failures, groupFailures...)
+	}
+
+	atomic.AddInt64(&l.report.Items, int64(created))
+	atomic.AddInt64(&l.report.FailedItems, int64(len(failures)))
+	if len(failures) > 0 {
+		l.mu.Lock()
+		l.report.ItemErrs = append(l.report.ItemErrs, failures...)
+		l.mu.Unlock()
+	}
+}
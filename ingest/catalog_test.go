@@ -0,0 +1,99 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadCatalogReportsUnreadableChildren(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"catalog.json": `{"type":"Catalog","id":"root","description":"root","links":[
+			{"rel":"child","href":"./c1/collection.json"},
+			{"rel":"child","href":"./missing/collection.json"},
+			{"rel":"child","href":"./broken/collection.json"}]}`,
+		"c1/collection.json": `{"type":"Collection","id":"c1","description":"c1","extent":{},"links":[
+			{"rel":"item","href":"./a.json"},{"rel":"item","href":"./b.json"}]}`,
+		"c1/a.json":              item("a"),
+		"c1/b.json":              item("b"),
+		"broken/collection.json": `{"type":"Collection",`,
+	})
+
+	loader := &Loader{Mode: ModeValidate, Parallel: 2}
+	report, err := loader.LoadCatalog(context.Background(), dir)
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if report.Collections != 1 || report.Items != 2 {
+		t.Errorf("loaded %d collections and %d items, want 1 and 2", report.Collections, report.Items)
+	}
+
+	sources := make([]string, 0)
+	for _, failure := range report.CollectionErrs {
+		sources = append(sources, filepath.Base(filepath.Dir(failure.Source)))
+	}
+	if want := []string{"missing", "broken"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("collection failures for %v, want %v", sources, want)
+	}
+}
+
+func TestLoadNDJSONFile(t *testing.T) {
+	lines := []string{
+		item("a"),
+		"nope",
+		item("c"),
+		`{"type":"Feature","id":"d","collection":"c2"}`,
+		item("e"),
+		item("f"),
+	}
+	dir := writeFiles(t, map[string]string{"items.ndjson": strings.Join(lines, "\n") + "\n"})
+
+	loader := &Loader{Mode: ModeValidate, BatchSize: 2, Parallel: 3}
+	report, err := loader.LoadNDJSONFile(context.Background(), filepath.Join(dir, "items.ndjson"), "c1")
+	if err != nil {
+		t.Fatalf("LoadNDJSONFile() error = %v", err)
+	}
+	if report.Items != 4 || report.FailedItems != 2 {
+		t.Errorf("loaded %d items with %d failures, want 4 and 2", report.Items, report.FailedItems)
+	}
+
+	failed := make([]int, 0)
+	for _, failure := range report.ItemErrs {
+		failed = append(failed, failure.Index)
+	}
+	if want := []int{2, 4}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("failed lines = %v, want %v", failed, want)
+	}
+}
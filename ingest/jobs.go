@@ -0,0 +1,474 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/metrics"
+	json "github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrStreamRead is returned when the items of a job could not be read
+var ErrStreamRead = errors.New("failed to read items")
+
+// JobStatus is the state of an ingest job as stored in stac_server.jobs
+type JobStatus string
+
+const (
+	JobPending             JobStatus = "pending"
+	JobRunning             JobStatus = "running"
+	JobSucceeded           JobStatus = "succeeded"
+	JobCompletedWithErrors JobStatus = "completed_with_errors"
+	JobFailed              JobStatus = "failed"
+)
+
+// jobLease is how long a running job may go without its worker renewing the
+// lease before another worker (possibly in another process) may take it over
+var jobLease = 5 * time.Minute
+
+var pollInterval = 2 * time.Second
+
+// wake is used to notify idle workers that a job was submitted
+var wake = make(chan struct{}, 1)
+
+// jobCopyRows is the number of streamed job items sent to the database at once
+var jobCopyRows = 1000
+
+// Job is the state of an asynchronous ingest job
+type Job struct {
+	ID           string     `json:"id"`
+	CollectionID string     `json:"collection"`
+	Status       JobStatus  `json:"status"`
+	Total        int        `json:"total"`
+	Processed    int        `json:"processed"`
+	Succeeded    int        `json:"succeeded"`
+	Failed       int        `json:"failed"`
+	Failures     []Failure  `json:"failures"`
+	Message      string     `json:"message,omitempty"`
+	Created      time.Time  `json:"created"`
+	Updated      time.Time  `json:"updated"`
+	Started      *time.Time `json:"started,omitempty"`
+	Finished     *time.Time `json:"finished,omitempty"`
+}
+
+// SubmitJob stores features for later processing by the worker pool and
+// returns the ID of the new job
+func SubmitJob(ctx context.Context, collectionID string, features []json.RawMessage) (string, error) {
+	id := uuid.NewString()
+
+	pool := database.GetInstance(ctx)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to begin job transaction")
+		return "", err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, "INSERT INTO stac_server.jobs (id, collection_id, total) VALUES ($1, $2, $3)", id, collectionID, len(features)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to insert job")
+		return "", err
+	}
+
+	// items are numbered from 1 like the lines of a newline delimited upload
+	rows := make([][]any, len(features))
+	for idx, feature := range features {
+		rows[idx] = []any{id, idx + 1, []byte(feature)}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"stac_server", "job_items"}, []string{"job_id", "idx", "content"}, pgx.CopyFromRows(rows)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to store job items")
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to commit job")
+		return "", err
+	}
+	notifyWorkers()
+
+	return id, nil
+}
+
+// SubmitJobStream stores the records of a newline delimited JSON or GeoJSON
+// text sequence stream as a job without holding the stream in memory. Items
+// are numbered by their line; lines that aren't valid JSON are recorded as
+// failures straight away. Nothing is stored if r cannot be read to the end, in
+// which case the error wraps ErrStreamRead and the read error, for instance
+// ErrStreamTooLarge.
+func SubmitJobStream(ctx context.Context, collectionID string, r io.Reader) (string, error) {
+	id := uuid.NewString()
+
+	pool := database.GetInstance(ctx)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to begin job transaction")
+		return "", err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err := tx.Exec(ctx, "INSERT INTO stac_server.jobs (id, collection_id) VALUES ($1, $2)", id, collectionID); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to insert job")
+		return "", err
+	}
+
+	total := 0
+	failures := make([]Failure, 0)
+	rows := make([][]any, 0, jobCopyRows)
+	copyRows := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"stac_server", "job_items"}, []string{"job_id", "idx", "content"}, pgx.CopyFromRows(rows)); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to store job items")
+			return err
+		}
+		rows = rows[:0]
+		return nil
+	}
+
+	var copyErr error
+	readErr := ReadRecords(r, func(line int, raw json.RawMessage) error {
+		total++
+		if raw == nil {
+			failures = append(failures, Failure{Index: line, Reason: "line is not valid JSON"})
+			return nil
+		}
+		rows = append(rows, []any{id, line, []byte(raw)})
+		if len(rows) >= jobCopyRows {
+			copyErr = copyRows()
+		}
+		return copyErr
+	})
+	if copyErr != nil {
+		return "", copyErr
+	}
+	if readErr != nil {
+		return "", fmt.Errorf("%w: %w", ErrStreamRead, readErr)
+	}
+	if err := copyRows(); err != nil {
+		return "", err
+	}
+
+	// lines that aren't JSON are done with before the job starts
+	failuresJSON, err := json.Marshal(failures)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to marshal job failures")
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE stac_server.jobs SET total = $2, processed = $3, failed = $3, failures = $4::text::jsonb
+		WHERE id = $1`, id, total, len(failures), failuresJSON); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to update job totals")
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to commit job")
+		return "", err
+	}
+	notifyWorkers()
+
+	return id, nil
+}
+
+// notifyWorkers wakes an idle worker to pick up a submitted job
+func notifyWorkers() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// GetJob returns the current state of a job
+func GetJob(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	var failures []byte
+
+	pool := database.GetInstance(ctx)
+	row := pool.QueryRow(ctx, `SELECT id, collection_id, status, total, processed, succeeded, failed, failures::text,
+		coalesce(message, ''), created_at, updated_at, started_at, finished_at
+		FROM stac_server.jobs WHERE id = $1`, id)
+	err := row.Scan(&job.ID, &job.CollectionID, &job.Status, &job.Total, &job.Processed, &job.Succeeded, &job.Failed,
+		&failures, &job.Message, &job.Created, &job.Updated, &job.Started, &job.Finished)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to query job")
+		return nil, err
+	}
+
+	if err := json.Unmarshal(failures, &job.Failures); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to unmarshal job failures")
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// StartWorkers starts a pool of goroutines that process pending jobs until ctx
+// is canceled. Jobs abandoned by a stopped server are picked up once their
+// lease expires.
+func StartWorkers(ctx context.Context, workers int, batchSize int) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	log.Ctx(ctx).Info().Int("workers", workers).Int("batchSize", batchSize).Msg("starting ingest job workers")
+	for i := 0; i < workers; i++ {
+		go worker(ctx, batchSize)
+	}
+}
+
+func worker(ctx context.Context, batchSize int) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// drain all available jobs before going back to sleep
+		for {
+			claim, ok := claimJob(ctx)
+			if !ok {
+				break
+			}
+			runJob(ctx, claim, batchSize)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// jobClaim is a worker's hold on a job. Progress is only recorded while the
+// job's claim column still holds token, so a worker whose lease expired and
+// was taken over cannot write to the job any more.
+type jobClaim struct {
+	id           string
+	collectionID string
+	token        string
+	// lastIndex is the index of the last job item processed
+	lastIndex int
+}
+
+// errLeaseLost is returned when a job was claimed by another worker
+var errLeaseLost = errors.New("job lease was taken over by another worker")
+
+func claimJob(ctx context.Context) (jobClaim, bool) {
+	claim := jobClaim{token: uuid.NewString()}
+
+	pool := database.GetInstance(ctx)
+	row := pool.QueryRow(ctx, `UPDATE stac_server.jobs
+		SET status = $1, claim = $4, started_at = coalesce(started_at, now()), updated_at = now()
+		WHERE id = (
+			SELECT id FROM stac_server.jobs
+			WHERE status = $2 OR (status = $1 AND updated_at < now() - $3::text::interval)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, collection_id, last_idx`, JobRunning, JobPending, fmt.Sprintf("%d seconds", int(jobLease.Seconds())), claim.token)
+	if err := row.Scan(&claim.id, &claim.collectionID, &claim.lastIndex); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to claim ingest job")
+		}
+		return claim, false
+	}
+
+	return claim, true
+}
+
+// heartbeat renews the lease of a claimed job until ctx is done. If the job
+// was taken over by another worker cancel is called to stop processing it.
+func heartbeat(ctx context.Context, cancel context.CancelFunc, claim jobClaim) {
+	ticker := time.NewTicker(jobLease / 3)
+	defer ticker.Stop()
+
+	pool := database.GetInstance(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tag, err := pool.Exec(ctx, "UPDATE stac_server.jobs SET updated_at = now() WHERE id = $1 AND claim = $2",
+			claim.id, claim.token)
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("job", claim.id).Msg("failed to renew ingest job lease")
+			continue
+		}
+		if tag.RowsAffected() == 0 {
+			log.Ctx(ctx).Warn().Str("job", claim.id).Msg(errLeaseLost.Error())
+			cancel()
+			return
+		}
+	}
+}
+
+func runJob(ctx context.Context, claim jobClaim, batchSize int) {
+	log.Ctx(ctx).Info().Str("job", claim.id).Str("collection", claim.collectionID).Int("lastIndex", claim.lastIndex).Msg("processing ingest job")
+
+	// a batch may take longer than the lease when items are retried one by
+	// one, so the lease is renewed while the job runs
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go heartbeat(jobCtx, cancel, claim)
+
+	for {
+		features, err := loadBatch(jobCtx, claim.id, claim.lastIndex, batchSize)
+		if err == nil && len(features) == 0 {
+			break
+		}
+		if err == nil {
+			err = processBatch(jobCtx, claim, features)
+		}
+		if err != nil {
+			// the job is left to whichever worker holds it, or to the
+			// next one once its lease expires
+			if jobCtx.Err() != nil || errors.Is(err, errLeaseLost) {
+				log.Ctx(ctx).Warn().Err(err).Str("job", claim.id).Msg("stopped processing ingest job")
+				return
+			}
+			finishJob(ctx, claim, JobFailed, "failed to process job items")
+			return
+		}
+		claim.lastIndex = features[len(features)-1].Index
+		log.Ctx(ctx).Debug().Str("job", claim.id).Int("lastIndex", claim.lastIndex).Msg("ingest job progress")
+	}
+
+	var succeeded, failed int
+	pool := database.GetInstance(ctx)
+	if err := pool.QueryRow(ctx, "SELECT succeeded, failed FROM stac_server.jobs WHERE id = $1", claim.id).Scan(&succeeded, &failed); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to query job totals")
+		finishJob(ctx, claim, JobFailed, "failed to query job totals")
+		return
+	}
+
+	switch {
+	case failed == 0:
+		finishJob(ctx, claim, JobSucceeded, "")
+	case succeeded == 0:
+		finishJob(ctx, claim, JobFailed, "no items could be created")
+	default:
+		finishJob(ctx, claim, JobCompletedWithErrors, "")
+	}
+}
+
+// processBatch creates features and records the job's progress in one
+// transaction. A batch interrupted by a crash is rolled back along with its
+// progress, so the worker that takes the job over replays it from scratch
+// instead of counting its items as duplicates.
+func processBatch(ctx context.Context, claim jobClaim, features []Feature) error {
+	pool := database.GetInstance(ctx)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to begin job batch")
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	created, failures := insertBatch(ctx, tx, claim.collectionID, features, ModeCreate)
+	failuresJSON, err := json.Marshal(failures)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to marshal job failures")
+		return err
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE stac_server.jobs
+		SET processed = processed + $3, last_idx = $4, succeeded = succeeded + $5, failed = failed + $6,
+			failures = failures || $7::text::jsonb, updated_at = now()
+		WHERE id = $1 AND claim = $2`,
+		claim.id, claim.token, len(features), features[len(features)-1].Index, created, len(failures), failuresJSON)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to record job progress")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errLeaseLost
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to commit job batch")
+		return err
+	}
+	metrics.ItemsWritten(claim.collectionID, metrics.OperationIngested, created)
+	return nil
+}
+
+func loadBatch(ctx context.Context, id string, lastIndex int, batchSize int) ([]Feature, error) {
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, `SELECT idx, content::text FROM stac_server.job_items
+		WHERE job_id = $1 AND idx > $2 ORDER BY idx LIMIT $3`, id, lastIndex, batchSize)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to query job items")
+		return nil, err
+	}
+	defer rows.Close()
+
+	features := make([]Feature, 0, batchSize)
+	for rows.Next() {
+		var feature Feature
+		var content []byte
+		if err := rows.Scan(&feature.Index, &content); err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("job", id).Msg("failed to scan job item")
+			return nil, err
+		}
+		feature.Raw = content
+		features = append(features, feature)
+	}
+
+	return features, rows.Err()
+}
+
+func finishJob(ctx context.Context, claim jobClaim, status JobStatus, message string) {
+	pool := database.GetInstance(ctx)
+	tag, err := pool.Exec(ctx, `UPDATE stac_server.jobs
+		SET status = $3, message = nullif($4, ''), claim = NULL, finished_at = now(), updated_at = now()
+		WHERE id = $1 AND claim = $2`, claim.id, claim.token, status, message)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to update job status")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		log.Ctx(ctx).Warn().Str("job", claim.id).Msg(errLeaseLost.Error())
+		return
+	}
+	log.Ctx(ctx).Info().Str("job", claim.id).Str("status", string(status)).Msg("ingest job finished")
+
+	// the payload is no longer needed once the job is done
+	if _, err := pool.Exec(ctx, "DELETE FROM stac_server.job_items WHERE job_id = $1", claim.id); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("job", claim.id).Msg("failed to delete job items")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrStreamTooLarge is returned when an item stream is longer than allowed
+var ErrStreamTooLarge = errors.New("item stream exceeds the maximum size")
+
+// IsStreamMediaType reports whether contentType is one of the newline
+// delimited formats that are ingested as a stream
+func IsStreamMediaType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.ToLower(strings.TrimSpace(mediaType)) {
+	case "application/x-ndjson", "application/geo+json-seq":
+		return true
+	}
+	return false
+}
+
+// limitedReader fails with ErrStreamTooLarge once more than max bytes have
+// been read, unlike io.LimitReader which silently ends the stream
+type limitedReader struct {
+	r    io.Reader
+	max  int64
+	read int64
+}
+
+// LimitReader reads at most maxBytes from r; a maxBytes of 0 or less doesn't
+// limit r
+func LimitReader(r io.Reader, maxBytes int64) io.Reader {
+	if maxBytes <= 0 {
+		return r
+	}
+	return &limitedReader{r: io.LimitReader(r, maxBytes+1), max: maxBytes}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n - int(l.read-l.max), ErrStreamTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,135 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// recordSeparator prefixes each record of a GeoJSON text sequence (RFC 8142)
+const recordSeparator = "\x1e"
+
+// Summary reports the line numbers inserted and rejected by a streaming ingest
+type Summary struct {
+	Inserted []int     `json:"inserted"`
+	Rejected []Failure `json:"rejected"`
+}
+
+// LoadNDJSON reads one feature per line from r and writes them to
+// collectionID, sending batchSize features at a time to the database. Both
+// newline delimited JSON and GeoJSON text sequences are accepted. The index of
+// each reported failure is its 1-based line number. When reading r fails, for
+// instance with ErrStreamTooLarge, the lines read so far are still written and
+// the summary describes them along with the error.
+func LoadNDJSON(ctx context.Context, r io.Reader, collectionID string, batchSize int, mode Mode) (*Summary, error) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	summary := &Summary{
+		Inserted: make([]int, 0),
+		Rejected: make([]Failure, 0),
+	}
+
+	batch := make([]Feature, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		_, failures := InsertBatch(ctx, collectionID, batch, mode)
+		failed := make(map[int]bool, len(failures))
+		for _, failure := range failures {
+			failed[failure.Index] = true
+		}
+		for _, feature := range batch {
+			if !failed[feature.Index] {
+				summary.Inserted = append(summary.Inserted, feature.Index)
+			}
+		}
+		summary.Rejected = append(summary.Rejected, failures...)
+		batch = batch[:0]
+	}
+
+	err := ReadRecords(r, func(line int, raw json.RawMessage) error {
+		if raw == nil {
+			summary.Rejected = append(summary.Rejected, Failure{
+				Index:  line,
+				Reason: "line is not valid JSON",
+			})
+			return nil
+		}
+		batch = append(batch, Feature{Index: line, Raw: raw})
+		if len(batch) >= batchSize {
+			flush()
+		}
+		return nil
+	})
+	flush()
+
+	sort.Slice(summary.Rejected, func(i, j int) bool {
+		return summary.Rejected[i].Index < summary.Rejected[j].Index
+	})
+
+	return summary, err
+}
+
+// ReadRecords calls fn with the 1-based line number and content of every
+// record of a newline delimited JSON or GeoJSON text sequence stream. Blank
+// lines are skipped; a line that is not valid JSON is passed with a nil
+// content. A line cut short by a read error is not passed to fn.
+func ReadRecords(r io.Reader, fn func(line int, raw json.RawMessage) error) error {
+	reader := bufio.NewReader(r)
+	lineNumber := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			log.Error().Err(err).Int("line", lineNumber+1).Msg("failed to read item stream")
+			return err
+		}
+
+		if len(line) > 0 {
+			lineNumber++
+			line = bytes.TrimSpace(bytes.TrimLeft(line, recordSeparator))
+			switch {
+			case len(line) == 0:
+				// blank lines are allowed between records
+			case !json.Valid(line):
+				if err := fn(lineNumber, nil); err != nil {
+					return err
+				}
+			default:
+				raw := make(json.RawMessage, len(line))
+				copy(raw, line)
+				if err := fn(lineNumber, raw); err != nil {
+					return err
+				}
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	json "github.com/goccy/go-json"
+)
+
+func item(id string) string {
+	return `{"type":"Feature","id":"` + id + `","collection":"c1"}`
+}
+
+func TestReadRecords(t *testing.T) {
+	type record struct {
+		line  int
+		valid bool
+	}
+	tests := []struct {
+		name  string
+		input string
+		want  []record
+	}{
+		{"one per line", item("a") + "\n" + item("b") + "\n", []record{{1, true}, {2, true}}},
+		{"no trailing newline", item("a") + "\n" + item("b"), []record{{1, true}, {2, true}}},
+		{"blank lines keep their numbers", "\n" + item("a") + "\n  \n" + item("b") + "\n", []record{{2, true}, {4, true}}},
+		{"crlf", item("a") + "\r\n" + item("b") + "\r\n", []record{{1, true}, {2, true}}},
+		{"geojson text sequence", "\x1e" + item("a") + "\n\x1e" + item("b") + "\n", []record{{1, true}, {2, true}}},
+		{"invalid json", item("a") + "\n{not json\n" + item("b"), []record{{1, true}, {2, false}, {3, true}}},
+		{"empty", "", []record{}},
+	}
+	for _, tt := range tests {
+		got := make([]record, 0)
+		err := ReadRecords(strings.NewReader(tt.input), func(line int, raw json.RawMessage) error {
+			got = append(got, record{line, raw != nil})
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: ReadRecords() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ReadRecords() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadRecordsStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := ReadRecords(strings.NewReader(item("a")+"\n"+item("b")+"\n"), func(line int, raw json.RawMessage) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ReadRecords() = %v after %d calls, want the callback error after 1 call", err, calls)
+	}
+}
+
+func TestLoadNDJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		batchSize int
+		inserted  []int
+		rejected  []int
+	}{
+		{"all valid", item("a") + "\n" + item("b") + "\n" + item("c") + "\n", 2, []int{1, 2, 3}, []int{}},
+		{"batch boundary", item("a") + "\n" + item("b") + "\n", 2, []int{1, 2}, []int{}},
+		{"invalid json", item("a") + "\nnope\n" + item("c") + "\n", 500, []int{1, 3}, []int{2}},
+		{"missing id", item("a") + "\n" + `{"type":"Feature","collection":"c1"}` + "\n", 500, []int{1}, []int{2}},
+		{"other collection", `{"type":"Feature","id":"a","collection":"c2"}` + "\n" + item("b") + "\n", 1, []int{2}, []int{1}},
+		{"not an object", "[1,2]\n" + item("b") + "\n", 500, []int{2}, []int{1}},
+		{"rejections are sorted", "nope\n" + item("b") + "\n" + `{"id":"c"}` + "\nnope\n", 500, []int{2}, []int{1, 3, 4}},
+	}
+	for _, tt := range tests {
+		summary, err := LoadNDJSON(context.Background(), strings.NewReader(tt.input), "c1", tt.batchSize, ModeValidate)
+		if err != nil {
+			t.Errorf("%s: LoadNDJSON() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(summary.Inserted, tt.inserted) {
+			t.Errorf("%s: inserted = %v, want %v", tt.name, summary.Inserted, tt.inserted)
+		}
+		rejected := make([]int, 0)
+		for _, failure := range summary.Rejected {
+			rejected = append(rejected, failure.Index)
+		}
+		if !reflect.DeepEqual(rejected, tt.rejected) {
+			t.Errorf("%s: rejected = %v, want %v", tt.name, rejected, tt.rejected)
+		}
+	}
+}
+
+func TestLoadNDJSONTooLarge(t *testing.T) {
+	// the limit falls inside the fourth line; the three complete lines are
+	// still written even though they don't fill a batch
+	lines := item("a") + "\n" + item("b") + "\n" + item("c") + "\n" + item("d") + "\n"
+	limit := int64(len(lines) - 10)
+
+	summary, err := LoadNDJSON(context.Background(), LimitReader(strings.NewReader(lines), limit), "c1", 500, ModeValidate)
+	if !errors.Is(err, ErrStreamTooLarge) {
+		t.Fatalf("LoadNDJSON() error = %v, want ErrStreamTooLarge", err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(summary.Inserted, want) {
+		t.Errorf("inserted = %v, want %v", summary.Inserted, want)
+	}
+	if len(summary.Rejected) != 0 {
+		t.Errorf("rejected = %v, want none", summary.Rejected)
+	}
+}
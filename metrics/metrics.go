@@ -0,0 +1,153 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the STAC specific Prometheus metrics served next
+// to the HTTP metrics at /api/stac/v1/metrics
+package metrics
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "stac"
+
+var (
+	searchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_duration_seconds",
+		Help:      "Time taken by pgstac searches, by collection and filter language",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"collection", "filter_lang"})
+
+	searchResults = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_results",
+		Help:      "Number of items returned per search page, by collection and filter language",
+		Buckets:   []float64{0, 1, 10, 100, 1000, 10000},
+	}, []string{"collection", "filter_lang"})
+
+	pageDepth = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "search_page_depth",
+		Help:      "Page number reached by following next and previous tokens, 1 for the first page",
+		Buckets:   []float64{1, 2, 3, 5, 10, 20, 50, 100, 500, 1000},
+	})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "pgstac_query_duration_seconds",
+		Help:      "Time taken by database queries, by pgstac function called",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"function", "status"})
+
+	itemsWritten = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "items_total",
+		Help:      "Items ingested, updated and deleted, by collection",
+	}, []string{"collection", "operation"})
+
+	validationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validation_failures_total",
+		Help:      "Searches and items rejected by validation, by reason",
+	}, []string{"reason"})
+)
+
+// Item operations counted by ItemsWritten
+const (
+	OperationIngested = "ingested"
+	OperationUpdated  = "updated"
+	OperationDeleted  = "deleted"
+)
+
+// filterLangs are the filter languages given their own label value; anything
+// else is reported as other to keep the number of series bounded
+var filterLangs = map[string]bool{
+	"cql-json":  true,
+	"cql-text":  true,
+	"cql2-json": true,
+	"cql2-text": true,
+}
+
+// functionCall finds the function a statement such as
+// "SELECT search FROM search($1::text::jsonb)" calls
+var functionCall = regexp.MustCompile(`(?i)^\s*select\s+(?:[a-z_]+\s+from\s+)?(?:pgstac\.)?([a-z_]+)\s*\(`)
+
+// CollectionLabel is the collection label of a search: the collection when
+// exactly one is searched, _all when none are and _multiple otherwise.
+// Collection ids come from the client, so an id that known rejects, e.g.
+// because it isn't a collection the caller may see, is labeled _other to keep
+// the number of series bounded.
+func CollectionLabel(collections []string, known func(id string) bool) string {
+	switch len(collections) {
+	case 0:
+		return "_all"
+	case 1:
+		if !known(collections[0]) {
+			return "_other"
+		}
+		return collections[0]
+	default:
+		return "_multiple"
+	}
+}
+
+// ObserveSearch records the duration and number of results of a search
+// against the collection label returned by CollectionLabel
+func ObserveSearch(collection string, filterLang string, filtered bool, duration time.Duration, results int) {
+	lang := "none"
+	if filtered {
+		lang = strings.ToLower(filterLang)
+		if !filterLangs[lang] {
+			lang = "other"
+		}
+	}
+
+	searchDuration.WithLabelValues(collection, lang).Observe(duration.Seconds())
+	searchResults.WithLabelValues(collection, lang).Observe(float64(results))
+}
+
+// ObserveQuery records the duration of a database query, labeled with the
+// pgstac function it calls or other for plain SQL
+func ObserveQuery(sql string, duration time.Duration, err error) {
+	function := "other"
+	if match := functionCall.FindStringSubmatch(sql); match != nil {
+		function = strings.ToLower(match[1])
+	}
+
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	queryDuration.WithLabelValues(function, status).Observe(duration.Seconds())
+}
+
+// ItemsWritten counts items written to a collection by operation
+func ItemsWritten(collectionID string, operation string, count int) {
+	if count <= 0 {
+		return
+	}
+	itemsWritten.WithLabelValues(collectionID, operation).Add(float64(count))
+}
+
+// ValidationFailed counts a request or item rejected for reason
+func ValidationFailed(reason string) {
+	validationFailures.WithLabelValues(reason).Inc()
+}
@@ -0,0 +1,43 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import "testing"
+
+func TestCollectionLabel(t *testing.T) {
+	known := func(id string) bool {
+		return id == "sentinel-2-l2a" || id == "landsat-c2-l2"
+	}
+
+	tests := []struct {
+		name        string
+		collections []string
+		want        string
+	}{
+		{"no collections", nil, "_all"},
+		{"empty list", []string{}, "_all"},
+		{"known collection", []string{"sentinel-2-l2a"}, "sentinel-2-l2a"},
+		{"unknown collection", []string{"made-up-123"}, "_other"},
+		{"empty id", []string{""}, "_other"},
+		{"several known collections", []string{"sentinel-2-l2a", "landsat-c2-l2"}, "_multiple"},
+		{"several unknown collections", []string{"a", "b"}, "_multiple"},
+	}
+	for _, tt := range tests {
+		if got := CollectionLabel(tt.collections, known); got != tt.want {
+			t.Errorf("%s: CollectionLabel(%q) = %q, want %q", tt.name, tt.collections, got, tt.want)
+		}
+	}
+}
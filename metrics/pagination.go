@@ -0,0 +1,77 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// pgstac tokens don't say how deep into the results they point, so the depth
+// of the page each token leads to is remembered when the token is handed out.
+// Tokens issued by other replicas or forgotten after tokenTTL aren't observed.
+const (
+	maxTrackedTokens = 10000
+	tokenTTL         = time.Hour
+)
+
+type tokenDepth struct {
+	depth   int
+	expires time.Time
+}
+
+var (
+	tokenDepthsMu sync.Mutex
+	tokenDepths   = make(map[string]tokenDepth)
+)
+
+// ObservePage records the depth of a search page fetched with token, empty
+// for the first page, and remembers the depth of the next and previous pages
+func ObservePage(token string, next string, prev string) {
+	tokenDepthsMu.Lock()
+	defer tokenDepthsMu.Unlock()
+
+	now := time.Now()
+	depth := 1
+	if token != "" {
+		known, ok := tokenDepths[token]
+		if !ok || now.After(known.expires) {
+			return
+		}
+		depth = known.depth
+	}
+	pageDepth.Observe(float64(depth))
+
+	if len(tokenDepths) >= maxTrackedTokens {
+		for key, known := range tokenDepths {
+			if now.After(known.expires) {
+				delete(tokenDepths, key)
+			}
+		}
+		if len(tokenDepths) >= maxTrackedTokens {
+			// still full of live tokens; start over rather than grow unbounded
+			tokenDepths = make(map[string]tokenDepth)
+		}
+	}
+
+	expires := now.Add(tokenTTL)
+	if next != "" {
+		tokenDepths[next] = tokenDepth{depth: depth + 1, expires: expires}
+	}
+	if prev != "" && depth > 1 {
+		tokenDepths[prev] = tokenDepth{depth: depth - 1, expires: expires}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquired = prometheus.NewDesc("stac_db_pool_acquired_connections",
+		"Connections currently checked out of the pool", nil, nil)
+	poolIdle = prometheus.NewDesc("stac_db_pool_idle_connections",
+		"Idle connections in the pool", nil, nil)
+	poolTotal = prometheus.NewDesc("stac_db_pool_total_connections",
+		"Connections open in the pool, including ones being established", nil, nil)
+	poolMax = prometheus.NewDesc("stac_db_pool_max_connections",
+		"Maximum size of the pool", nil, nil)
+	poolAcquires = prometheus.NewDesc("stac_db_pool_acquires_total",
+		"Connections acquired from the pool", nil, nil)
+	poolEmptyAcquires = prometheus.NewDesc("stac_db_pool_empty_acquires_total",
+		"Acquires that had to wait because no idle connection was available", nil, nil)
+	poolCanceledAcquires = prometheus.NewDesc("stac_db_pool_canceled_acquires_total",
+		"Acquires canceled before a connection became available", nil, nil)
+	poolWait = prometheus.NewDesc("stac_db_pool_acquire_wait_seconds_total",
+		"Time spent acquiring connections from the pool", nil, nil)
+)
+
+// poolCollector reports pgxpool statistics each time metrics are scraped
+type poolCollector struct {
+	pool *pgxpool.Pool
+}
+
+// RegisterPool exports the statistics of pool
+func RegisterPool(pool *pgxpool.Pool) error {
+	return prometheus.Register(poolCollector{pool: pool})
+}
+
+func (p poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquired
+	ch <- poolIdle
+	ch <- poolTotal
+	ch <- poolMax
+	ch <- poolAcquires
+	ch <- poolEmptyAcquires
+	ch <- poolCanceledAcquires
+	ch <- poolWait
+}
+
+func (p poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := p.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,90 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+
+	"github.com/go-geospatial/go-stac-server/ingest"
+	"github.com/go-geospatial/go-stac-server/metrics"
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// ingestPath is the route accepting item uploads
+var ingestPath = regexp.MustCompile(`^/api/stac/v1/collections/[^/]+/items/?$`)
+
+// BodyLimit answers 413 to requests with a body larger than maxBytes. The
+// server streams request bodies so items can be uploaded as newline delimited
+// JSON of any size, which means fasthttp hands larger and chunked bodies to
+// handlers instead of rejecting them; they are read here up to the limit.
+// Item uploads that are streamed or stored as ingest jobs read their body
+// directly, under their own limits.
+func BodyLimit(maxBytes int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if maxBytes <= 0 || isIngestUpload(c) {
+			return c.Next()
+		}
+
+		if length := c.Request().Header.ContentLength(); length > maxBytes {
+			return bodyTooLarge(c, maxBytes, fmt.Sprintf("request body of %d bytes exceeds the maximum of %d bytes", length, maxBytes))
+		}
+
+		if c.Request().IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(c.Context().RequestBodyStream(), int64(maxBytes)+1))
+			if err != nil {
+				log.Error().Err(err).Msg("failed to read request body")
+				c.Status(fiber.StatusBadRequest)
+				return c.JSON(stac.Message{
+					Code:        stac.ParameterError,
+					Description: "failed to read request body",
+				})
+			}
+			if len(body) > maxBytes {
+				return bodyTooLarge(c, maxBytes, fmt.Sprintf("request body exceeds the maximum of %d bytes", maxBytes))
+			}
+			c.Request().SetBody(body)
+		}
+
+		return c.Next()
+	}
+}
+
+// isIngestUpload reports whether c uploads newline delimited items or asks
+// for an ingest job with async=true
+func isIngestUpload(c *fiber.Ctx) bool {
+	if c.Method() != fiber.MethodPost || !ingestPath.MatchString(c.Path()) {
+		return false
+	}
+	async, _ := strconv.ParseBool(c.Query("async"))
+	return async || ingest.IsStreamMediaType(c.Get(fiber.HeaderContentType))
+}
+
+func bodyTooLarge(c *fiber.Ctx, maxBytes int, description string) error {
+	metrics.ValidationFailed("body_size")
+	log.Warn().Int("max", maxBytes).Str("path", c.Path()).Msg("request body too large")
+	// the rest of the body is left unread
+	c.Context().SetConnectionClose()
+	c.Status(fiber.StatusRequestEntityTooLarge)
+	return c.JSON(stac.Message{
+		Code:        stac.PayloadTooLargeError,
+		Description: description,
+	})
+}
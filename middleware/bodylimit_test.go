@@ -0,0 +1,66 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIsIngestUpload(t *testing.T) {
+	tests := []struct {
+		method      string
+		target      string
+		contentType string
+		want        bool
+	}{
+		{"POST", "/api/stac/v1/collections/a/items", "application/json", false},
+		{"POST", "/api/stac/v1/collections/a/items?async=true", "application/json", true},
+		{"POST", "/api/stac/v1/collections/a/items/?async=1", "application/json", true},
+		{"POST", "/api/stac/v1/collections/a/items?async=false", "application/json", false},
+		{"POST", "/api/stac/v1/collections/a/items?async=yes", "application/json", false},
+		{"POST", "/api/stac/v1/collections/a/items", "application/x-ndjson", true},
+		{"POST", "/api/stac/v1/collections/a/items", "application/geo+json-seq", true},
+		{"PUT", "/api/stac/v1/collections/a/items?async=true", "application/json", false},
+		{"POST", "/api/stac/v1/collections?async=true", "application/json", false},
+		{"POST", "/api/stac/v1/search?async=true", "application/json", false},
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(strconv.FormatBool(isIngestUpload(c)))
+	})
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		req.Header.Set(fiber.HeaderContentType, tt.contentType)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(body) == "true"; got != tt.want {
+			t.Errorf("isIngestUpload(%s %s, %s) = %v, want %v", tt.method, tt.target, tt.contentType, got, tt.want)
+		}
+	}
+}
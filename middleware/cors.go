@@ -0,0 +1,129 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/spf13/viper"
+)
+
+// CORS applies the cors.* policy to reads, including POST /search, and the
+// cors.write.* policy, which falls back to cors.* for anything it doesn't
+// set, to writes. Preflight requests are matched on the method they announce.
+func CORS() (fiber.Handler, error) {
+	readConfig, err := corsConfig("cors", nil)
+	if err != nil {
+		return nil, err
+	}
+	writeConfig, err := corsConfig("cors.write", &readConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	read := cors.New(readConfig)
+	write := cors.New(writeConfig)
+
+	return func(c *fiber.Ctx) error {
+		if isWriteRequest(c) {
+			return write(c)
+		}
+		return read(c)
+	}, nil
+}
+
+// corsConfig reads the CORS policy under prefix, taking unset values from
+// fallback
+func corsConfig(prefix string, fallback *cors.Config) (cors.Config, error) {
+	config := cors.Config{
+		AllowOrigins:     strings.Join(settingList(prefix+".allowOrigins"), ","),
+		AllowMethods:     strings.Join(settingList(prefix+".allowMethods"), ","),
+		AllowHeaders:     strings.Join(settingList(prefix+".allowHeaders"), ","),
+		ExposeHeaders:    strings.Join(settingList(prefix+".exposeHeaders"), ","),
+		AllowCredentials: viper.GetBool(prefix + ".allowCredentials"),
+		MaxAge:           viper.GetInt(prefix + ".maxAge"),
+	}
+
+	if fallback != nil {
+		if config.AllowOrigins == "" {
+			config.AllowOrigins = fallback.AllowOrigins
+		}
+		if config.AllowMethods == "" {
+			config.AllowMethods = fallback.AllowMethods
+		}
+		if config.AllowHeaders == "" {
+			config.AllowHeaders = fallback.AllowHeaders
+		}
+		if config.ExposeHeaders == "" {
+			config.ExposeHeaders = fallback.ExposeHeaders
+		}
+		if !viper.IsSet(prefix + ".allowCredentials") {
+			config.AllowCredentials = fallback.AllowCredentials
+		}
+		if !viper.IsSet(prefix + ".maxAge") {
+			config.MaxAge = fallback.MaxAge
+		}
+	}
+
+	if config.AllowOrigins == "" {
+		return config, fmt.Errorf("%s.allowOrigins must list at least one origin", prefix)
+	}
+	// browsers reject credentialed responses allowing any origin
+	if config.AllowCredentials {
+		for _, origin := range strings.Split(config.AllowOrigins, ",") {
+			if origin == "*" {
+				return config, errors.New(prefix + ".allowCredentials requires explicit origins instead of *")
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// isWriteRequest reports whether a request, or the request a preflight
+// announces, modifies the catalog
+func isWriteRequest(c *fiber.Ctx) bool {
+	method := c.Method()
+	if method == fiber.MethodOptions {
+		method = strings.ToUpper(c.Get(fiber.HeaderAccessControlRequestMethod))
+	}
+
+	switch method {
+	case fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	case fiber.MethodPost:
+		return !strings.HasSuffix(strings.TrimSuffix(c.Path(), "/"), "/search")
+	}
+	return false
+}
+
+// settingList reads a list setting given either as a list in the config
+// file or as a comma separated string in a flag or environment variable
+func settingList(key string) []string {
+	values := make([]string, 0)
+	for _, value := range viper.GetStringSlice(key) {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,71 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIsWriteRequest(t *testing.T) {
+	tests := []struct {
+		method          string
+		path            string
+		preflightMethod string
+		want            bool
+	}{
+		{"GET", "/api/stac/v1/collections", "", false},
+		{"POST", "/api/stac/v1/search", "", false},
+		{"POST", "/api/stac/v1/search/", "", false},
+		{"POST", "/api/stac/v1/collections", "", true},
+		{"POST", "/api/stac/v1/collections/a/items", "", true},
+		{"PUT", "/api/stac/v1/collections/a/items/b", "", true},
+		{"PATCH", "/api/stac/v1/collections/a/items/b", "", true},
+		{"DELETE", "/api/stac/v1/collections/a", "", true},
+		{"OPTIONS", "/api/stac/v1/search", "POST", false},
+		{"OPTIONS", "/api/stac/v1/collections/a/items", "post", true},
+		{"OPTIONS", "/api/stac/v1/collections/a", "DELETE", true},
+		{"OPTIONS", "/api/stac/v1/collections/a", "GET", false},
+		{"OPTIONS", "/api/stac/v1/collections/a", "", false},
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(strconv.FormatBool(isWriteRequest(c)))
+	})
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.preflightMethod != "" {
+			req.Header.Set(fiber.HeaderAccessControlRequestMethod, tt.preflightMethod)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(body) == "true"; got != tt.want {
+			t.Errorf("isWriteRequest(%s %s, preflight %q) = %v, want %v", tt.method, tt.path, tt.preflightMethod, got, tt.want)
+		}
+	}
+}
@@ -19,8 +19,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-geospatial/go-stac-server/auth"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // NewLogger creates a new middleware handler
@@ -56,10 +58,17 @@ func NewLogger() fiber.Handler {
 		// Set latency stop time
 		stop = time.Now()
 
-		subLog := log.With().
+		logContext := log.With()
+		if spanContext := trace.SpanContextFromContext(c.UserContext()); spanContext.IsValid() {
+			logContext = logContext.
+				Str("TraceID", spanContext.TraceID().String()).
+				Str("SpanID", spanContext.SpanID().String())
+		}
+
+		subLog := logContext.
 			Int("StatusCode", c.Response().StatusCode()).
 			Dur("Latency", stop.Sub(start).Round(time.Millisecond)).
-			Str("IP", c.IP()).
+			Str("IP", auth.ClientIP(c)).
 			Str("Method", c.Method()).
 			Str("Path", c.Path()).
 			Str("Referer", c.Get(fiber.HeaderReferer)).
@@ -0,0 +1,332 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/go-geospatial/go-stac-server/stac"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+var TooManyRequestsError = "TooManyRequests"
+
+// rate limited route classes
+const (
+	classSearch       = "search"
+	classItems        = "items"
+	classTransactions = "transactions"
+)
+
+// bucketIdle is how long an unused bucket is kept; by then it has refilled
+const bucketIdle = time.Hour
+
+// limit is a token bucket refilling perMinute tokens a minute up to burst
+type limit struct {
+	perMinute float64
+	burst     float64
+}
+
+// bucketStore takes a token from the bucket identified by key, or reports
+// how many tokens the bucket holds without taking one
+type bucketStore interface {
+	take(ctx context.Context, key string, l limit) (remaining float64, allowed bool, err error)
+	peek(ctx context.Context, key string, l limit) (remaining float64, err error)
+}
+
+func newBucketStore() bucketStore {
+	if viper.GetBool("ratelimit.shared") {
+		return newPostgresStore()
+	}
+	return newMemoryStore()
+}
+
+// RateLimit limits how often each caller may search, read items and write.
+// Callers are identified by API key name, token subject or IP address.
+func RateLimit() fiber.Handler {
+	limits := map[string]limit{}
+	for _, class := range []string{classSearch, classItems, classTransactions} {
+		perMinute := viper.GetFloat64("ratelimit." + class + ".perMinute")
+		if perMinute <= 0 {
+			continue
+		}
+		burst := viper.GetFloat64("ratelimit." + class + ".burst")
+		if burst <= 0 {
+			burst = perMinute
+		}
+		limits[class] = limit{perMinute: perMinute, burst: burst}
+	}
+
+	if len(limits) == 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	store := newBucketStore()
+	log.Info().Int("classes", len(limits)).Bool("shared", viper.GetBool("ratelimit.shared")).Msg("rate limiting enabled")
+
+	return func(c *fiber.Ctx) error {
+		class := routeClass(c)
+		l, ok := limits[class]
+		if !ok {
+			return c.Next()
+		}
+
+		principal := auth.FromContext(c)
+		key := "ip:" + principal.Name
+		if !principal.Anonymous() {
+			key = principal.Method + ":" + principal.Name
+		}
+
+		remaining, allowed, err := store.take(context.Background(), class+"|"+key, l)
+		if err != nil {
+			// don't turn a rate limit store outage into an API outage
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("rate limit check failed; allowing request")
+			return c.Next()
+		}
+
+		// seconds until the next token and until the bucket is full again
+		perSecond := l.perMinute / 60
+		reset := int(math.Ceil((l.burst - remaining) / perSecond))
+		c.Set("RateLimit-Limit", strconv.Itoa(int(l.burst)))
+		c.Set("RateLimit-Remaining", strconv.Itoa(int(math.Max(0, math.Floor(remaining)))))
+		c.Set("RateLimit-Reset", strconv.Itoa(reset))
+		c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=60;burst=%d", int(l.perMinute), int(l.burst)))
+
+		if !allowed {
+			retryAfter := int(math.Ceil((1 - remaining) / perSecond))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			log.Ctx(c.UserContext()).Warn().Str("key", key).Str("class", class).Msg("rate limit exceeded")
+			c.Status(fiber.StatusTooManyRequests)
+			return c.JSON(stac.Message{
+				Code:        TooManyRequestsError,
+				Description: fmt.Sprintf("too many %s requests; retry in %d seconds", class, retryAfter),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// AuthFailureLimit limits how often an IP address may present invalid
+// credentials. It runs before authentication so that once an address is over
+// the limit its credentials aren't checked at all, which keeps API keys from
+// being guessed and each guess from costing a database lookup.
+func AuthFailureLimit() fiber.Handler {
+	perMinute := viper.GetFloat64("ratelimit.authFailures.perMinute")
+	if perMinute <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+	burst := viper.GetFloat64("ratelimit.authFailures.burst")
+	if burst <= 0 {
+		burst = perMinute
+	}
+	l := limit{perMinute: perMinute, burst: burst}
+	store := newBucketStore()
+
+	return func(c *fiber.Ctx) error {
+		if c.Get("X-API-Key") == "" && c.Get(fiber.HeaderAuthorization) == "" {
+			return c.Next()
+		}
+
+		key := "authfailures|ip:" + auth.ClientIP(c)
+		remaining, err := store.peek(context.Background(), key, l)
+		if err != nil {
+			log.Ctx(c.UserContext()).Error().Err(err).Msg("authentication failure limit check failed; allowing request")
+			return c.Next()
+		}
+		if remaining < 1 {
+			retryAfter := int(math.Ceil((1 - remaining) / (l.perMinute / 60)))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			log.Ctx(c.UserContext()).Warn().Str("key", key).Msg("too many failed authentication attempts")
+			c.Status(fiber.StatusTooManyRequests)
+			return c.JSON(stac.Message{
+				Code:        TooManyRequestsError,
+				Description: fmt.Sprintf("too many failed authentication attempts; retry in %d seconds", retryAfter),
+			})
+		}
+
+		err = c.Next()
+		if c.Response().StatusCode() == fiber.StatusUnauthorized {
+			if _, _, takeErr := store.take(context.Background(), key, l); takeErr != nil {
+				log.Ctx(c.UserContext()).Error().Err(takeErr).Msg("could not record failed authentication")
+			}
+		}
+		return err
+	}
+}
+
+// routeClass returns which limit applies to a request, or "" for none
+func routeClass(c *fiber.Ctx) string {
+	path := strings.TrimSuffix(c.Path(), "/")
+	if !strings.HasPrefix(path, "/api/stac/v1/") {
+		return ""
+	}
+
+	switch c.Method() {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		if strings.HasSuffix(path, "/search") {
+			return classSearch
+		}
+		return classTransactions
+	case fiber.MethodGet, fiber.MethodHead:
+		if strings.HasSuffix(path, "/search") {
+			return classSearch
+		}
+		// /collections/{collectionId}/items and /collections/{collectionId}/items/{itemId}
+		parts := strings.Split(strings.TrimPrefix(path, "/api/stac/v1/"), "/")
+		if len(parts) >= 3 && parts[0] == "collections" && parts[2] == "items" {
+			return classItems
+		}
+	}
+	return ""
+}
+
+// memoryStore keeps buckets in this process
+type memoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: make(map[string]*bucket), pruned: time.Now()}
+}
+
+func (s *memoryStore) take(_ context.Context, key string, l limit) (float64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.pruned) > bucketIdle {
+		for k, b := range s.buckets {
+			if now.Sub(b.updated) > bucketIdle {
+				delete(s.buckets, k)
+			}
+		}
+		s.pruned = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, updated: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = b.refilled(now, l)
+	b.updated = now
+	if b.tokens < 1 {
+		return b.tokens, false, nil
+	}
+	b.tokens--
+	return b.tokens, true, nil
+}
+
+func (s *memoryStore) peek(_ context.Context, key string, l limit) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		return l.burst, nil
+	}
+	return b.refilled(time.Now(), l), nil
+}
+
+// refilled returns the tokens in the bucket at now
+func (b *bucket) refilled(now time.Time, l limit) float64 {
+	return math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.perMinute/60)
+}
+
+// postgresStore keeps buckets in stac_server.rate_limits so every replica
+// shares them
+type postgresStore struct {
+	mu     sync.Mutex
+	pruned time.Time
+}
+
+func newPostgresStore() *postgresStore {
+	return &postgresStore{pruned: time.Now()}
+}
+
+func (s *postgresStore) take(ctx context.Context, key string, l limit) (float64, bool, error) {
+	pool := database.GetInstance(ctx)
+	s.prune(ctx)
+
+	var remaining float64
+	var allowed bool
+	err := pool.QueryRow(ctx, `WITH refill AS (
+			SELECT least($2::float8, coalesce(
+				(SELECT tokens + extract(epoch FROM clock_timestamp() - updated_at) * $3::float8
+				 FROM stac_server.rate_limits WHERE key = $1), $2::float8)) AS tokens
+		)
+		INSERT INTO stac_server.rate_limits AS r (key, tokens, allowed, updated_at)
+		SELECT $1, CASE WHEN tokens >= 1 THEN tokens - 1 ELSE tokens END, tokens >= 1, clock_timestamp() FROM refill
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE
+				WHEN least($2::float8, r.tokens + extract(epoch FROM clock_timestamp() - r.updated_at) * $3::float8) >= 1
+				THEN least($2::float8, r.tokens + extract(epoch FROM clock_timestamp() - r.updated_at) * $3::float8) - 1
+				ELSE least($2::float8, r.tokens + extract(epoch FROM clock_timestamp() - r.updated_at) * $3::float8)
+			END,
+			allowed = least($2::float8, r.tokens + extract(epoch FROM clock_timestamp() - r.updated_at) * $3::float8) >= 1,
+			updated_at = clock_timestamp()
+		RETURNING tokens, allowed`, key, l.burst, l.perMinute/60).Scan(&remaining, &allowed)
+	return remaining, allowed, err
+}
+
+func (s *postgresStore) peek(ctx context.Context, key string, l limit) (float64, error) {
+	pool := database.GetInstance(ctx)
+
+	var remaining float64
+	err := pool.QueryRow(ctx, `SELECT least($2::float8, coalesce(
+			(SELECT tokens + extract(epoch FROM clock_timestamp() - updated_at) * $3::float8
+			 FROM stac_server.rate_limits WHERE key = $1), $2::float8))`, key, l.burst, l.perMinute/60).Scan(&remaining)
+	return remaining, err
+}
+
+// prune removes idle buckets at most once per bucketIdle
+func (s *postgresStore) prune(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.pruned) < bucketIdle {
+		s.mu.Unlock()
+		return
+	}
+	s.pruned = time.Now()
+	s.mu.Unlock()
+
+	pool := database.GetInstance(ctx)
+	if _, err := pool.Exec(ctx, "DELETE FROM stac_server.rate_limits WHERE updated_at < now() - interval '1 hour'"); err != nil {
+		log.Warn().Err(err).Msg("could not prune rate limit buckets")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	l := limit{perMinute: 60, burst: 3}
+
+	// each step waits (by backdating the bucket) and then takes a token
+	tests := []struct {
+		name      string
+		wait      time.Duration
+		remaining float64
+		allowed   bool
+	}{
+		{"new bucket starts full", 0, 2, true},
+		{"second token", 0, 1, true},
+		{"last token", 0, 0, true},
+		{"empty bucket", 0, 0, false},
+		{"half a token refilled", 500 * time.Millisecond, 0.5, false},
+		{"a token refilled", 500 * time.Millisecond, 0, true},
+		{"refill is capped at burst", time.Hour, 2, true},
+	}
+
+	store := newMemoryStore()
+	for _, tt := range tests {
+		if b, ok := store.buckets["key"]; ok {
+			b.updated = b.updated.Add(-tt.wait)
+		}
+		remaining, allowed, err := store.take(context.Background(), "key", l)
+		if err != nil {
+			t.Fatalf("%s: take returned %v", tt.name, err)
+		}
+		// allow for the time passing between steps
+		if allowed != tt.allowed || math.Abs(remaining-tt.remaining) > 0.05 {
+			t.Errorf("%s: take = (%.3f, %v), want (%.3f, %v)", tt.name, remaining, allowed, tt.remaining, tt.allowed)
+		}
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	l := limit{perMinute: 1, burst: 1}
+	store := newMemoryStore()
+
+	if _, allowed, _ := store.take(context.Background(), "a", l); !allowed {
+		t.Fatal("first request of a was denied")
+	}
+	if _, allowed, _ := store.take(context.Background(), "a", l); allowed {
+		t.Error("second request of a was allowed")
+	}
+	if _, allowed, _ := store.take(context.Background(), "b", l); !allowed {
+		t.Error("first request of b was denied")
+	}
+}
+
+func TestRouteClass(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/api/stac/v1/search", classSearch},
+		{"POST", "/api/stac/v1/search", classSearch},
+		{"GET", "/api/stac/v1/collections/a/items", classItems},
+		{"GET", "/api/stac/v1/collections/a/items/b", classItems},
+		{"GET", "/api/stac/v1/collections/a/items/", classItems},
+		{"POST", "/api/stac/v1/collections/a/items", classTransactions},
+		{"DELETE", "/api/stac/v1/collections/a", classTransactions},
+		{"PATCH", "/api/stac/v1/collections/a/items/b", classTransactions},
+		{"GET", "/api/stac/v1/collections", ""},
+		{"GET", "/api/stac/v1/collections/a/queryables", ""},
+		{"GET", "/api/stac/v1/", ""},
+		{"POST", "/other/search", ""},
+	}
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		return c.SendString(routeClass(c))
+	})
+
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tt.want {
+			t.Errorf("routeClass(%s %s) = %q, want %q", tt.method, tt.path, body, tt.want)
+		}
+	}
+}
+
+func TestAuthFailureLimit(t *testing.T) {
+	viper.Set("ratelimit.authFailures.perMinute", 2)
+	defer viper.Set("ratelimit.authFailures.perMinute", nil)
+
+	app := fiber.New()
+	app.Use(AuthFailureLimit())
+	app.Use(func(c *fiber.Ctx) error {
+		if c.Get("X-API-Key") == "valid" || c.Get("X-API-Key") == "" {
+			return c.SendStatus(fiber.StatusOK)
+		}
+		return c.SendStatus(fiber.StatusUnauthorized)
+	})
+
+	// the bucket holds two failures; the third request with credentials is
+	// turned away, while requests without credentials are never limited
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"valid", fiber.StatusOK},
+		{"guess-1", fiber.StatusUnauthorized},
+		{"valid", fiber.StatusOK},
+		{"guess-2", fiber.StatusUnauthorized},
+		{"guess-3", fiber.StatusTooManyRequests},
+		{"valid", fiber.StatusTooManyRequests},
+		{"", fiber.StatusOK},
+	}
+	for idx, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/stac/v1/collections", nil)
+		if tt.key != "" {
+			req.Header.Set("X-API-Key", tt.key)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("request %d with key %q = %d, want %d", idx, tt.key, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -16,6 +16,9 @@
 package router
 
 import (
+	"github.com/go-geospatial/go-stac-server/audit"
+	"github.com/go-geospatial/go-stac-server/auth"
+	"github.com/go-geospatial/go-stac-server/common"
 	"github.com/go-geospatial/go-stac-server/handler"
 	"github.com/go-geospatial/go-stac-server/static"
 	"github.com/gofiber/fiber/v2"
@@ -25,38 +28,84 @@ import (
 func SetupRoutes(app *fiber.App) {
 	// config.js - used to configure Stac Browser
 	app.Get("/config.js", handler.StacBrowserConfig)
-	// openapi.json
+	// openapi.json and openapi.yml, without the operations of disabled routes
 	app.Get("/openapi.json", static.OpenAPIHandler)
+	app.Get("/openapi.yml", static.OpenAPIYAMLHandler)
 
 	// STAC API
 	api := app.Group("api")
 	stac := api.Group("stac")
 	stacV1 := stac.Group("v1")
 
-	stacV1.Get("/", handler.Catalog)
-	stacV1.Get("/collections", handler.Collections)
-	stacV1.Get("/conformance", handler.Conformance)
-	stacV1.Get("/collections/:collectionId", handler.Collection)
-	stacV1.Get("/collections/:collectionId/items", handler.Items)
-	stacV1.Get("/collections/:collectionId/items/:itemId", handler.Item)
+	// route groups by the role they require when authentication is enabled
+	reader := auth.Require(auth.RoleReader)
+	writer := auth.Require(auth.RoleWriter)
+	admin := auth.Require(auth.RoleAdmin)
+	// collections hidden from the caller by ACLs answer 404
+	visible := auth.RequireCollectionAccess()
 
-	stacV1.Get("/search", handler.Search)
-	stacV1.Post("/search", handler.Search)
+	stacV1.Get("/", reader, handler.Catalog)
+	stacV1.Get("/collections", reader, handler.Collections)
+	stacV1.Get("/conformance", reader, handler.Conformance)
+	stacV1.Get("/collections/:collectionId", visible, reader, handler.Collection)
+	stacV1.Get("/collections/:collectionId/items", visible, reader, handler.Items)
+	stacV1.Get("/collections/:collectionId/items/:itemId", visible, reader, handler.Item)
+	stacV1.Get("/collections/:collectionId/statistics", visible, reader, handler.CollectionStatistics)
+
+	stacV1.Get("/search", reader, handler.Search)
+	stacV1.Post("/search", reader, handler.Search)
 
 	// Filter Extension
-	stacV1.Get("/collections/:collectionId/queryables", handler.Queryables)
-	stacV1.Get("/queryables", handler.Queryables)
+	stacV1.Get("/collections/:collectionId/queryables", visible, reader, handler.Queryables)
+	stacV1.Get("/queryables", reader, handler.Queryables)
+
+	// Sort Extension
+	stacV1.Get("/collections/:collectionId/sortables", visible, reader, handler.Sortables)
+	stacV1.Get("/sortables", reader, handler.Sortables)
+
+	// write routes are left out in read-only mode
+	if common.TransactionsEnabled() {
+		// transactions are recorded in the audit log once authorized
+		audited := audit.Middleware()
+
+		// Transactions extension
+		stacV1.Post("/collections", writer, audited, handler.ModifyCollection)
+		stacV1.Put("/collections", writer, audited, handler.ModifyCollection)
+		stacV1.Delete("/collections/:collectionId", visible, writer, audited, handler.DeleteCollection)
+
+		stacV1.Post("/collections/:collectionId/items", visible, writer, audited, handler.CreateItems)
+		stacV1.Delete("/collections/:collectionId/items/:itemId", visible, writer, audited, handler.DeleteItem)
+		stacV1.Put("/collections/:collectionId/items/:itemId", visible, writer, audited, handler.UpdateItem)
+		stacV1.Patch("/collections/:collectionId/items/:itemId", visible, writer, audited, handler.PatchItem)
+
+		// asynchronous ingest jobs
+		stacV1.Get("/jobs/:jobId", writer, handler.Job)
+	}
+
+	if common.AdminEnabled() {
+		// queryables management
+		stacV1.Post("/queryables/:name", admin, handler.CreateQueryable)
+		stacV1.Put("/queryables/:name", admin, handler.UpdateQueryable)
+		stacV1.Delete("/queryables/:name", admin, handler.DeleteQueryable)
+		stacV1.Post("/collections/:collectionId/queryables/:name", visible, admin, handler.CreateQueryable)
+		stacV1.Put("/collections/:collectionId/queryables/:name", visible, admin, handler.UpdateQueryable)
+		stacV1.Delete("/collections/:collectionId/queryables/:name", visible, admin, handler.DeleteQueryable)
+		stacV1.Post("/collections/:collectionId/queryables\\:discover", visible, admin, handler.DiscoverQueryables)
+		stacV1.Post("/collections/:collectionId/summaries\\:recompute", visible, admin, handler.RecomputeSummaries)
 
-	// Transactions extension
-	stacV1.Post("/collections", handler.ModifyCollection)
-	stacV1.Put("/collections", handler.ModifyCollection)
-	stacV1.Delete("/collections/:collectionId", handler.DeleteCollection)
+		// pgstac settings
+		stacV1.Get("/settings", admin, handler.Settings)
+		stacV1.Get("/settings/history", admin, handler.SettingHistory)
+		stacV1.Get("/settings/:name", admin, handler.Setting)
+		stacV1.Put("/settings/:name", admin, handler.UpdateSetting)
+		stacV1.Put("/collections/:collectionId/settings/partition_trunc", visible, admin, handler.UpdatePartitionTrunc)
 
-	stacV1.Post("/collections/:collectionId/items", handler.CreateItems)
-	stacV1.Delete("/collections/:collectionId/items/:itemId", handler.DeleteItem)
-	stacV1.Put("/collections/:collectionId/items/:itemId", handler.UpdateItem)
-	stacV1.Patch("/collections/:collectionId/items/:itemId", handler.PatchItem)
+		// audit log of transactions
+		stacV1.Get("/audit", admin, handler.AuditLog)
+	}
 
-	// healthz
+	// healthz, and liveness and readiness probes
 	stacV1.Get("/healthz", handler.Healthz)
+	stacV1.Get("/livez", handler.Livez)
+	stacV1.Get("/readyz", handler.Readyz)
 }
@@ -51,3 +51,32 @@ var Conformance = []string{
 	"https://api.stacspec.org/v1.0.0-rc.2/ogcapi-features/extensions/transaction",
 	"http://www.opengis.net/spec/ogcapi-features-4/1.0/conf/simpletx",
 }
+
+// TransactionConformance are the conformance classes of the write routes
+var TransactionConformance = []string{
+	"https://api.stacspec.org/v1.0.0-rc.2/ogcapi-features/extensions/transaction",
+	"http://www.opengis.net/spec/ogcapi-features-4/1.0/conf/simpletx",
+}
+
+// ConformsTo returns the conformance classes the server implements, leaving
+// out the transaction classes when writes are disabled
+func ConformsTo(transactions bool) []string {
+	if transactions {
+		return Conformance
+	}
+
+	conformsTo := make([]string, 0, len(Conformance))
+	for _, class := range Conformance {
+		isTransaction := false
+		for _, tx := range TransactionConformance {
+			if class == tx {
+				isTransaction = true
+				break
+			}
+		}
+		if !isTransaction {
+			conformsTo = append(conformsTo, class)
+		}
+	}
+	return conformsTo
+}
@@ -0,0 +1,95 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/rs/zerolog/log"
+)
+
+// collectionIDsTTL bounds how long collections created or deleted by another
+// server process take to be noticed
+var collectionIDsTTL = 30 * time.Second
+
+var collectionIDsCache struct {
+	sync.Mutex
+	ids     []string
+	known   map[string]bool
+	expires time.Time
+}
+
+// CollectionIDs returns the ids of every collection in id order. The list is
+// cached for a short time and dropped by InvalidateCollectionIDs whenever
+// this server writes a collection; callers must not modify it.
+func CollectionIDs(ctx context.Context) ([]string, error) {
+	collectionIDsCache.Lock()
+	defer collectionIDsCache.Unlock()
+	if collectionIDsCache.ids != nil && time.Now().Before(collectionIDsCache.expires) {
+		return collectionIDsCache.ids, nil
+	}
+
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, "SELECT id FROM pgstac.collections ORDER BY id")
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("error querying collection ids")
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	collectionIDsCache.ids = ids
+	collectionIDsCache.known = known
+	collectionIDsCache.expires = time.Now().Add(collectionIDsTTL)
+	return ids, nil
+}
+
+// IsCollection reports whether a collection called id exists, using the list
+// cached by CollectionIDs
+func IsCollection(ctx context.Context, id string) (bool, error) {
+	if _, err := CollectionIDs(ctx); err != nil {
+		return false, err
+	}
+	collectionIDsCache.Lock()
+	defer collectionIDsCache.Unlock()
+	return collectionIDsCache.known[id], nil
+}
+
+// InvalidateCollectionIDs drops the cached collection ids so the next call to
+// CollectionIDs sees a collection that was just created or deleted
+func InvalidateCollectionIDs() {
+	collectionIDsCache.Lock()
+	defer collectionIDsCache.Unlock()
+	collectionIDsCache.ids = nil
+}
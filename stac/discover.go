@@ -0,0 +1,408 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// DiscoverOptions control how item properties are sampled and summarized
+type DiscoverOptions struct {
+	// SampleSize is the maximum number of items inspected
+	SampleSize int
+	// MaxEnumValues is the largest number of distinct strings reported as an enum
+	MaxEnumValues int
+}
+
+// propertyStats accumulates what has been seen of one property across the sample
+type propertyStats struct {
+	types    map[string]int
+	integers bool
+	minimum  float64
+	maximum  float64
+	values   map[string]struct{}
+	dates    int
+	strings  int
+}
+
+func newPropertyStats() *propertyStats {
+	return &propertyStats{
+		types:    make(map[string]int),
+		integers: true,
+		minimum:  math.Inf(1),
+		maximum:  math.Inf(-1),
+		values:   make(map[string]struct{}),
+	}
+}
+
+func (p *propertyStats) add(value any, maxEnumValues int) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case bool:
+		p.types["boolean"]++
+	case float64:
+		p.types["number"]++
+		p.minimum = math.Min(p.minimum, v)
+		p.maximum = math.Max(p.maximum, v)
+		if v != math.Trunc(v) {
+			p.integers = false
+		}
+	case string:
+		p.types["string"]++
+		p.strings++
+		if _, err := time.Parse(time.RFC3339, v); err == nil {
+			p.dates++
+		}
+		// stop collecting once the property clearly isn't an enumeration
+		if len(p.values) <= maxEnumValues {
+			p.values[v] = struct{}{}
+		}
+	case []any:
+		p.types["array"]++
+	case map[string]any:
+		p.types["object"]++
+	}
+}
+
+// queryable turns the accumulated statistics into a queryable definition
+func (p *propertyStats) queryable(name string, maxEnumValues int) (*Queryable, bool) {
+	jsonType := ""
+	for t := range p.types {
+		if jsonType != "" {
+			// mixed types can't be described by a single wrapper
+			return nil, false
+		}
+		jsonType = t
+	}
+
+	definition := map[string]any{
+		"title": name,
+	}
+	var wrapper string
+
+	switch jsonType {
+	case "number":
+		wrapper = "to_float"
+		definition["type"] = "number"
+		if p.integers {
+			wrapper = "to_int"
+			definition["type"] = "integer"
+		}
+		definition["minimum"] = p.minimum
+		definition["maximum"] = p.maximum
+	case "string":
+		wrapper = "to_text"
+		definition["type"] = "string"
+		switch {
+		case p.dates == p.strings:
+			wrapper = "to_tstz"
+			definition["format"] = "date-time"
+		case len(p.values) <= maxEnumValues:
+			enum := make([]string, 0, len(p.values))
+			for v := range p.values {
+				enum = append(enum, v)
+			}
+			sort.Strings(enum)
+			definition["enum"] = enum
+		}
+	case "boolean":
+		definition["type"] = "boolean"
+	case "array":
+		wrapper = "to_text_array"
+		definition["type"] = "array"
+	default:
+		return nil, false
+	}
+
+	return newQueryable(name, definition, wrapper)
+}
+
+func newQueryable(name string, definition map[string]any, wrapper string) (*Queryable, bool) {
+	raw, err := json.Marshal(definition)
+	if err != nil {
+		return nil, false
+	}
+	rawMessage := json.RawMessage(raw)
+
+	queryable := &Queryable{
+		Name:       name,
+		Definition: &rawMessage,
+	}
+	if wrapper != "" {
+		queryable.PropertyWrapper = &wrapper
+	}
+	return queryable, true
+}
+
+// DiscoverQueryables samples the items of a collection and proposes a
+// queryable for every property with a consistent JSON type. Properties that
+// already have a queryable for the collection are skipped.
+func DiscoverQueryables(ctx context.Context, collectionID string, opts DiscoverOptions) ([]Queryable, error) {
+	known, err := knownQueryables(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := opts.SampleSize
+	if pageSize > 1000 {
+		pageSize = 1000
+	}
+
+	stats := make(map[string]*propertyStats)
+	params := CQL{
+		Collections: []string{collectionID},
+		Limit:       pageSize,
+		Fields:      &CQLFields{Include: []string{"properties"}},
+		FilterLang:  "cql2-json",
+	}
+
+	sampled := 0
+	for sampled < opts.SampleSize {
+		page, err := Search(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, feature := range page.Features {
+			if feature["properties"] == nil {
+				continue
+			}
+			var properties map[string]any
+			if err := json.Unmarshal(*feature["properties"], &properties); err != nil {
+				log.Ctx(ctx).Warn().Err(err).Str("collection", collectionID).Msg("skipping item with unreadable properties")
+				continue
+			}
+			for name, value := range properties {
+				if known[name] {
+					continue
+				}
+				if stats[name] == nil {
+					stats[name] = newPropertyStats()
+				}
+				stats[name].add(value, opts.MaxEnumValues)
+			}
+			sampled++
+		}
+
+		if page.Next == "" || len(page.Features) == 0 {
+			break
+		}
+		params.Token = page.Next
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queryables := make([]Queryable, 0, len(names))
+	for _, name := range names {
+		if queryable, ok := stats[name].queryable(name, opts.MaxEnumValues); ok {
+			queryable.CollectionIDs = []string{collectionID}
+			queryables = append(queryables, *queryable)
+		}
+	}
+	return queryables, nil
+}
+
+// QueryablesFromSummaries builds queryables from a collection's summaries.
+// Range summaries become numeric bounds, value lists become enums and JSON
+// Schema summaries are used as is. Scalar fields declared in item_assets are
+// treated like value lists.
+func QueryablesFromSummaries(collection map[string]*json.RawMessage) ([]Queryable, error) {
+	var id string
+	if collection["id"] == nil {
+		return nil, errors.New("collection has no id")
+	}
+	if err := json.Unmarshal(*collection["id"], &id); err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]json.RawMessage)
+	if collection["summaries"] != nil {
+		if err := json.Unmarshal(*collection["summaries"], &summaries); err != nil {
+			return nil, errors.New("summaries must be an object")
+		}
+	}
+
+	// gather values of asset level fields such as gsd or proj:epsg
+	assetValues := make(map[string][]any)
+	if collection["item_assets"] != nil {
+		itemAssets := make(map[string]map[string]any)
+		if err := json.Unmarshal(*collection["item_assets"], &itemAssets); err != nil {
+			return nil, errors.New("item_assets must be an object of asset definitions")
+		}
+		for _, asset := range itemAssets {
+			for field, value := range asset {
+				switch field {
+				case "title", "description", "type", "roles", "href":
+					continue
+				}
+				switch value.(type) {
+				case string, float64, bool:
+					assetValues[field] = append(assetValues[field], value)
+				}
+			}
+		}
+	}
+	for field, values := range assetValues {
+		if _, ok := summaries[field]; ok {
+			continue
+		}
+		raw, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		summaries[field] = raw
+	}
+
+	names := make([]string, 0, len(summaries))
+	for name := range summaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queryables := make([]Queryable, 0, len(names))
+	for _, name := range names {
+		queryable, ok := queryableFromSummary(name, summaries[name])
+		if !ok {
+			continue
+		}
+		queryable.CollectionIDs = []string{id}
+		queryables = append(queryables, *queryable)
+	}
+	return queryables, nil
+}
+
+func queryableFromSummary(name string, summary json.RawMessage) (*Queryable, bool) {
+	var values []any
+	if err := json.Unmarshal(summary, &values); err == nil {
+		stats := newPropertyStats()
+		for _, value := range values {
+			stats.add(value, len(values))
+		}
+		queryable, ok := stats.queryable(name, len(values))
+		if !ok {
+			return nil, false
+		}
+		// summaries list every allowed value so numbers become enums too
+		if stats.types["number"] > 0 {
+			var definition map[string]any
+			_ = json.Unmarshal(*queryable.Definition, &definition)
+			delete(definition, "minimum")
+			delete(definition, "maximum")
+			definition["enum"] = values
+			return newQueryable(name, definition, *queryable.PropertyWrapper)
+		}
+		return queryable, true
+	}
+
+	var object map[string]any
+	if err := json.Unmarshal(summary, &object); err != nil {
+		return nil, false
+	}
+
+	// a range object has minimum and maximum, anything else is a JSON Schema
+	minimum, hasMin := object["minimum"]
+	maximum, hasMax := object["maximum"]
+	if hasMin && hasMax && len(object) == 2 {
+		switch minimum.(type) {
+		case float64:
+			return newQueryable(name, map[string]any{
+				"title":   name,
+				"type":    "number",
+				"minimum": minimum,
+				"maximum": maximum,
+			}, "to_float")
+		case string:
+			return newQueryable(name, map[string]any{
+				"title":  name,
+				"type":   "string",
+				"format": "date-time",
+			}, "to_tstz")
+		}
+		return nil, false
+	}
+
+	if err := ValidateJSONSchema(summary); err != nil {
+		return nil, false
+	}
+	raw := json.RawMessage(summary)
+	return &Queryable{Name: name, Definition: &raw}, true
+}
+
+// RegisterQueryables creates the queryables whose name isn't already
+// registered for their collection, including as a global queryable, and
+// returns the ones created. A failure to create one queryable doesn't stop
+// the others from being registered; all failures are returned together.
+func RegisterQueryables(ctx context.Context, queryables []Queryable) ([]Queryable, error) {
+	created := make([]Queryable, 0, len(queryables))
+	known := make(map[string]map[string]bool)
+	var errs []error
+	for i := range queryables {
+		queryable := queryables[i]
+		collectionID := ""
+		if len(queryable.CollectionIDs) > 0 {
+			collectionID = queryable.CollectionIDs[0]
+		}
+		if known[collectionID] == nil {
+			names, err := knownQueryables(ctx, collectionID)
+			if err != nil {
+				return created, err
+			}
+			known[collectionID] = names
+		}
+		if known[collectionID][queryable.Name] {
+			continue
+		}
+
+		err := CreateQueryable(ctx, &queryable)
+		if errors.Is(err, ErrQueryableExists) {
+			continue
+		}
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("name", queryable.Name).Str("collection", collectionID).Msg("could not register queryable")
+			errs = append(errs, fmt.Errorf("queryable %s: %w", queryable.Name, err))
+			continue
+		}
+		known[collectionID][queryable.Name] = true
+		created = append(created, queryable)
+	}
+	return created, errors.Join(errs...)
+}
+
+// knownQueryables returns the names of queryables already applying to collectionID
+func knownQueryables(ctx context.Context, collectionID string) (map[string]bool, error) {
+	existing, err := ListQueryables(ctx, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, q := range existing {
+		known[q.Name] = true
+	}
+	return known, nil
+}
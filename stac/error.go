@@ -25,3 +25,4 @@ var NotFoundError = "NotFoundError"
 var DatabaseError = "DatabaseError"
 var ParameterError = "ParameterError"
 var ServerError = "ServerError"
+var PayloadTooLargeError = "PayloadTooLarge"
@@ -0,0 +1,277 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// FilterProperties returns the property names referenced by a CQL2 JSON (or
+// legacy cql-json) filter
+func FilterProperties(filter json.RawMessage) ([]string, error) {
+	var node any
+	if err := json.Unmarshal(filter, &node); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]struct{})
+	var walk func(node any)
+	walk = func(node any) {
+		switch n := node.(type) {
+		case map[string]any:
+			if property, ok := n["property"].(string); ok {
+				found[property] = struct{}{}
+			}
+			for _, child := range n {
+				walk(child)
+			}
+		case []any:
+			for _, child := range n {
+				walk(child)
+			}
+		}
+	}
+	walk(node)
+
+	return sortedKeys(found), nil
+}
+
+// QueryProperties returns the property names used by a query extension expression
+func QueryProperties(query json.RawMessage) ([]string, error) {
+	expressions := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(query, &expressions); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]struct{}, len(expressions))
+	for property := range expressions {
+		found[property] = struct{}{}
+	}
+	return sortedKeys(found), nil
+}
+
+// cql2TextKeywords are words of CQL2 text that are not property references
+var cql2TextKeywords = map[string]bool{
+	"AND": true, "OR": true, "NOT": true, "LIKE": true, "BETWEEN": true, "IN": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true,
+}
+
+// TextFilterProperties returns the property names referenced by a CQL2 text
+// filter. Identifiers followed by ( are functions, spatial/temporal operators
+// or literals such as TIMESTAMP('...') and POINT(...), and are skipped.
+func TextFilterProperties(filter string) []string {
+	found := make(map[string]struct{})
+	runes := []rune(filter)
+
+	isIdentifier := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == ':' || r == '.'
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			// skip string literal, '' escapes a quote
+			i++
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case r == '"':
+			// double quoted identifier
+			i++
+			start := i
+			for i < len(runes) && runes[i] != '"' {
+				i++
+			}
+			found[string(runes[start:i])] = struct{}{}
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && isIdentifier(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+
+			next := i
+			for next < len(runes) && unicode.IsSpace(runes[next]) {
+				next++
+			}
+			if (next < len(runes) && runes[next] == '(') || cql2TextKeywords[strings.ToUpper(word)] {
+				continue
+			}
+			found[word] = struct{}{}
+		case unicode.IsDigit(r):
+			// numeric literal, including exponents such as 1e10
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E') {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+
+	return sortedKeys(found)
+}
+
+// FilterComplexity returns the nesting depth of operators and the number of
+// nodes in a CQL2 JSON filter. Property references and geometry literals
+// count as a single node.
+func FilterComplexity(filter json.RawMessage) (depth int, nodes int, err error) {
+	var root any
+	if err := json.Unmarshal(filter, &root); err != nil {
+		return 0, 0, err
+	}
+
+	var walk func(node any, level int)
+	walk = func(node any, level int) {
+		nodes++
+		switch n := node.(type) {
+		case map[string]any:
+			if _, ok := n["coordinates"]; ok {
+				return
+			}
+			if _, ok := n["property"]; ok {
+				return
+			}
+			if _, ok := n["op"]; ok {
+				level++
+				if level > depth {
+					depth = level
+				}
+			}
+			for key, child := range n {
+				if key == "op" {
+					continue
+				}
+				walk(child, level)
+			}
+		case []any:
+			// the array itself only groups arguments
+			nodes--
+			for _, child := range n {
+				walk(child, level)
+			}
+		}
+	}
+	walk(root, 0)
+
+	return depth, nodes, nil
+}
+
+// TextFilterComplexity returns the parenthesis nesting depth and the number
+// of identifiers, literals and operators in a CQL2 text filter
+func TextFilterComplexity(filter string) (depth int, nodes int) {
+	level := 0
+	inString := false
+	inWord := false
+	for _, r := range filter {
+		if inString {
+			if r == '\'' {
+				inString = false
+			}
+			continue
+		}
+
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == ':' || r == '"'
+		if isWord && !inWord {
+			nodes++
+		}
+		inWord = isWord
+
+		switch r {
+		case '\'':
+			inString = true
+			nodes++
+		case '(':
+			level++
+			if level > depth {
+				depth = level
+			}
+		case ')':
+			level--
+		case '=', '<', '>':
+			nodes++
+		}
+	}
+
+	return depth, nodes
+}
+
+// QueryableNames returns the names of the queryables advertised by
+// get_queryables for the collections, or for all collections when none
+// are given
+func QueryableNames(ctx context.Context, collectionIDs []string) (map[string]bool, error) {
+	var raw []byte
+	pool := database.GetInstance(ctx)
+
+	var collections *[]string
+	if len(collectionIDs) > 0 {
+		collections = &collectionIDs
+	}
+	if err := pool.QueryRow(ctx, "SELECT get_queryables FROM get_queryables($1::text[])", collections).Scan(&raw); err != nil {
+		log.Ctx(ctx).Error().Err(err).Strs("collections", collectionIDs).Msg("failed to get queryables from database")
+		return nil, err
+	}
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(schema.Properties))
+	for name := range schema.Properties {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// UnknownProperties returns the properties that aren't queryable
+func UnknownProperties(properties []string, queryables map[string]bool) []string {
+	unknown := make([]string, 0)
+	for _, property := range properties {
+		if !queryables[property] && !queryables[strings.TrimPrefix(property, "properties.")] {
+			unknown = append(unknown, property)
+		}
+	}
+	return unknown
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
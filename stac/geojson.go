@@ -21,3 +21,35 @@ type GeoJSON struct {
 	Type        string           `json:"type"`
 	Coordinates *json.RawMessage `json:"coordinates"`
 }
+
+// Vertices returns the number of positions in a GeoJSON geometry
+func (g *GeoJSON) Vertices() (int, error) {
+	if g == nil || g.Coordinates == nil {
+		return 0, nil
+	}
+
+	var coordinates any
+	if err := json.Unmarshal(*g.Coordinates, &coordinates); err != nil {
+		return 0, err
+	}
+
+	var count func(node any) int
+	count = func(node any) int {
+		arr, ok := node.([]any)
+		if !ok {
+			return 0
+		}
+		if len(arr) > 0 {
+			if _, ok := arr[0].(float64); ok {
+				return 1
+			}
+		}
+		total := 0
+		for _, child := range arr {
+			total += count(child)
+		}
+		return total
+	}
+
+	return count(coordinates), nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"bytes"
+	"fmt"
+
+	json "github.com/goccy/go-json"
+)
+
+var jsonSchemaTypes = map[string]bool{
+	"array":   true,
+	"boolean": true,
+	"integer": true,
+	"null":    true,
+	"number":  true,
+	"object":  true,
+	"string":  true,
+}
+
+// schemaKeywordsWithSchema are keywords whose value is itself a schema
+var schemaKeywordsWithSchema = []string{"items", "not", "additionalProperties", "contains", "if", "then", "else"}
+
+// schemaKeywordsWithSchemaArray are keywords whose value is an array of schemas
+var schemaKeywordsWithSchemaArray = []string{"allOf", "anyOf", "oneOf", "prefixItems"}
+
+// schemaKeywordsWithSchemaMap are keywords whose value maps names to schemas
+var schemaKeywordsWithSchemaMap = []string{"properties", "patternProperties", "$defs", "definitions"}
+
+var schemaNumberKeywords = []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf", "minLength", "maxLength", "minItems", "maxItems"}
+
+var schemaStringKeywords = []string{"$ref", "$id", "$schema", "title", "description", "format", "pattern"}
+
+// ValidateJSONSchema checks that raw is a structurally valid JSON Schema: an
+// object (or boolean) whose well known keywords have values of the right type.
+// Unknown keywords are allowed.
+func ValidateJSONSchema(raw []byte) error {
+	return validateSchema(raw, "#")
+}
+
+func validateSchema(raw []byte, pointer string) error {
+	if trimmed := string(bytes.TrimSpace(raw)); trimmed == "true" || trimmed == "false" {
+		return nil
+	}
+
+	schema := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &schema); err != nil {
+		return fmt.Errorf("%s: schema must be an object or boolean", pointer)
+	}
+
+	if rawType, ok := schema["type"]; ok {
+		if err := validateSchemaType(rawType, pointer); err != nil {
+			return err
+		}
+	}
+
+	if rawEnum, ok := schema["enum"]; ok {
+		var enum []json.RawMessage
+		if err := json.Unmarshal(rawEnum, &enum); err != nil || len(enum) == 0 {
+			return fmt.Errorf("%s/enum: must be a non-empty array", pointer)
+		}
+	}
+
+	if rawRequired, ok := schema["required"]; ok {
+		var required []string
+		if err := json.Unmarshal(rawRequired, &required); err != nil {
+			return fmt.Errorf("%s/required: must be an array of strings", pointer)
+		}
+	}
+
+	for _, keyword := range schemaNumberKeywords {
+		if rawNumber, ok := schema[keyword]; ok {
+			var number float64
+			if err := json.Unmarshal(rawNumber, &number); err != nil {
+				return fmt.Errorf("%s/%s: must be a number", pointer, keyword)
+			}
+		}
+	}
+
+	for _, keyword := range schemaStringKeywords {
+		if rawString, ok := schema[keyword]; ok {
+			var str string
+			if err := json.Unmarshal(rawString, &str); err != nil {
+				return fmt.Errorf("%s/%s: must be a string", pointer, keyword)
+			}
+		}
+	}
+
+	for _, keyword := range schemaKeywordsWithSchema {
+		if sub, ok := schema[keyword]; ok {
+			if err := validateSchema(sub, pointer+"/"+keyword); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyword := range schemaKeywordsWithSchemaArray {
+		if rawList, ok := schema[keyword]; ok {
+			var subs []json.RawMessage
+			if err := json.Unmarshal(rawList, &subs); err != nil {
+				return fmt.Errorf("%s/%s: must be an array of schemas", pointer, keyword)
+			}
+			for idx, sub := range subs {
+				if err := validateSchema(sub, fmt.Sprintf("%s/%s/%d", pointer, keyword, idx)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, keyword := range schemaKeywordsWithSchemaMap {
+		if rawMap, ok := schema[keyword]; ok {
+			subs := make(map[string]json.RawMessage)
+			if err := json.Unmarshal(rawMap, &subs); err != nil {
+				return fmt.Errorf("%s/%s: must be an object of schemas", pointer, keyword)
+			}
+			for name, sub := range subs {
+				if err := validateSchema(sub, fmt.Sprintf("%s/%s/%s", pointer, keyword, name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateSchemaType(raw []byte, pointer string) error {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if !jsonSchemaTypes[single] {
+			return fmt.Errorf("%s/type: '%s' is not a JSON Schema type", pointer, single)
+		}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(raw, &multiple); err != nil || len(multiple) == 0 {
+		return fmt.Errorf("%s/type: must be a string or array of strings", pointer)
+	}
+	for _, t := range multiple {
+		if !jsonSchemaTypes[t] {
+			return fmt.Errorf("%s/type: '%s' is not a JSON Schema type", pointer, t)
+		}
+	}
+	return nil
+}
@@ -16,6 +16,7 @@
 package stac
 
 import (
+	"errors"
 	"fmt"
 
 	json "github.com/goccy/go-json"
@@ -61,3 +62,45 @@ func AddLinkPost(links []Link, baseURL string, rel string, endpoint string, mime
 
 	return links
 }
+
+// StripLinks removes every link whose relationship is one of rels from the
+// links array of a STAC object. Other links are left untouched.
+func StripLinks(obj map[string]*json.RawMessage, rels ...string) error {
+	rawLinks, ok := obj["links"]
+	if !ok {
+		return nil
+	}
+
+	var links []map[string]*json.RawMessage
+	if err := json.Unmarshal(*rawLinks, &links); err != nil {
+		return errors.New("links must be an array of link objects")
+	}
+
+	kept := make([]map[string]*json.RawMessage, 0, len(links))
+	for _, link := range links {
+		var rel string
+		if rawRel, ok := link["rel"]; ok {
+			_ = json.Unmarshal(*rawRel, &rel)
+		}
+		if !containsString(rels, rel) {
+			kept = append(kept, link)
+		}
+	}
+
+	serialized, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(serialized)
+	obj["links"] = &raw
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	json "github.com/goccy/go-json"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrQueryableNotFound = errors.New("queryable not found")
+var ErrQueryableExists = errors.New("queryable already exists")
+
+// PropertyWrappers are the pgstac functions used to cast a property value
+var PropertyWrappers = []string{"to_int", "to_float", "to_tstz", "to_text", "to_text_array"}
+
+// PropertyIndexTypes are the index access methods pgstac can create for a queryable
+var PropertyIndexTypes = []string{"BTREE", "HASH", "GIN", "GIST", "SPGIST", "BRIN"}
+
+// Queryable is a row of the pgstac.queryables table. A queryable without
+// collection ids applies to every collection.
+type Queryable struct {
+	Name              string           `json:"name"`
+	CollectionIDs     []string         `json:"collection_ids,omitempty"`
+	Definition        *json.RawMessage `json:"definition"`
+	PropertyPath      *string          `json:"property_path,omitempty"`
+	PropertyWrapper   *string          `json:"property_wrapper,omitempty"`
+	PropertyIndexType *string          `json:"property_index_type,omitempty"`
+}
+
+// Validate checks the definition is a JSON Schema and that the wrapper and
+// index type are supported by pgstac
+func (q *Queryable) Validate() error {
+	if q.Name == "" {
+		return errors.New("queryable name is required")
+	}
+
+	if q.Definition == nil {
+		return errors.New("queryable definition is required")
+	}
+	if err := ValidateJSONSchema(*q.Definition); err != nil {
+		return fmt.Errorf("definition is not a valid JSON Schema: %w", err)
+	}
+
+	if q.PropertyWrapper != nil && !containsString(PropertyWrappers, *q.PropertyWrapper) {
+		return fmt.Errorf("property_wrapper must be one of: %s", strings.Join(PropertyWrappers, ", "))
+	}
+
+	if q.PropertyIndexType != nil {
+		indexType := strings.ToUpper(*q.PropertyIndexType)
+		if !containsString(PropertyIndexTypes, indexType) {
+			return fmt.Errorf("property_index_type must be one of: %s", strings.Join(PropertyIndexTypes, ", "))
+		}
+		q.PropertyIndexType = &indexType
+	}
+
+	return nil
+}
+
+// collectionFilter matches queryables scoped to exactly collectionID, or
+// global queryables when collectionID is empty
+func collectionFilter(collectionID string) (string, []string) {
+	if collectionID == "" {
+		return "collection_ids IS NULL", nil
+	}
+	return "collection_ids = $2::text[]", []string{collectionID}
+}
+
+// ListQueryables returns the queryables that apply to collectionID, including
+// global ones. An empty collectionID lists every queryable.
+func ListQueryables(ctx context.Context, collectionID string) ([]Queryable, error) {
+	query := `SELECT name, collection_ids, definition::text, property_path, property_wrapper, property_index_type
+		FROM pgstac.queryables ORDER BY name`
+	args := []any{}
+	if collectionID != "" {
+		query = `SELECT name, collection_ids, definition::text, property_path, property_wrapper, property_index_type
+			FROM pgstac.queryables
+			WHERE collection_ids IS NULL OR $1 = ANY(collection_ids)
+			ORDER BY name`
+		args = append(args, collectionID)
+	}
+
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to query queryables")
+		return nil, err
+	}
+	defer rows.Close()
+
+	queryables := make([]Queryable, 0)
+	for rows.Next() {
+		queryable, err := scanQueryable(rows)
+		if err != nil {
+			return nil, err
+		}
+		queryables = append(queryables, *queryable)
+	}
+
+	return queryables, rows.Err()
+}
+
+// GetQueryable returns the queryable called name scoped to collectionID, or
+// the global queryable when collectionID is empty
+func GetQueryable(ctx context.Context, name string, collectionID string) (*Queryable, error) {
+	filter, collectionIDs := collectionFilter(collectionID)
+	query := fmt.Sprintf(`SELECT name, collection_ids, definition::text, property_path, property_wrapper, property_index_type
+		FROM pgstac.queryables WHERE name = $1 AND %s`, filter)
+	args := []any{name}
+	if collectionIDs != nil {
+		args = append(args, collectionIDs)
+	}
+
+	pool := database.GetInstance(ctx)
+	queryable, err := scanQueryable(pool.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrQueryableNotFound
+	}
+	return queryable, err
+}
+
+// CreateQueryable inserts a new queryable
+func CreateQueryable(ctx context.Context, q *Queryable) error {
+	collectionID := ""
+	if len(q.CollectionIDs) > 0 {
+		collectionID = q.CollectionIDs[0]
+	}
+	if _, err := GetQueryable(ctx, q.Name, collectionID); err == nil {
+		return ErrQueryableExists
+	} else if !errors.Is(err, ErrQueryableNotFound) {
+		return err
+	}
+
+	pool := database.GetInstance(ctx)
+	if _, err := pool.Exec(ctx, `INSERT INTO pgstac.queryables
+		(name, collection_ids, definition, property_path, property_wrapper, property_index_type)
+		VALUES ($1, $2, $3::text::jsonb, $4, $5, $6)`,
+		q.Name, nullableStrings(q.CollectionIDs), []byte(*q.Definition), q.PropertyPath, q.PropertyWrapper, q.PropertyIndexType); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("name", q.Name).Msg("failed to insert queryable")
+		return err
+	}
+	return nil
+}
+
+// UpdateQueryable replaces the definition and options of an existing queryable
+func UpdateQueryable(ctx context.Context, q *Queryable) error {
+	collectionID := ""
+	if len(q.CollectionIDs) > 0 {
+		collectionID = q.CollectionIDs[0]
+	}
+	filter, collectionIDs := collectionFilter(collectionID)
+	args := []any{q.Name}
+	if collectionIDs != nil {
+		args = append(args, collectionIDs)
+	}
+	args = append(args, []byte(*q.Definition), q.PropertyPath, q.PropertyWrapper, q.PropertyIndexType)
+
+	// parameter numbers shift by one when the collection filter takes $2
+	offset := len(args) - 4
+	query := fmt.Sprintf(`UPDATE pgstac.queryables
+		SET definition = $%d::text::jsonb, property_path = $%d, property_wrapper = $%d, property_index_type = $%d
+		WHERE name = $1 AND %s`, offset+1, offset+2, offset+3, offset+4, filter)
+
+	pool := database.GetInstance(ctx)
+	tag, err := pool.Exec(ctx, query, args...)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("name", q.Name).Msg("failed to update queryable")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrQueryableNotFound
+	}
+	return nil
+}
+
+// DeleteQueryable removes the queryable called name scoped to collectionID,
+// or the global queryable when collectionID is empty
+func DeleteQueryable(ctx context.Context, name string, collectionID string) error {
+	filter, collectionIDs := collectionFilter(collectionID)
+	args := []any{name}
+	if collectionIDs != nil {
+		args = append(args, collectionIDs)
+	}
+
+	pool := database.GetInstance(ctx)
+	tag, err := pool.Exec(ctx, fmt.Sprintf("DELETE FROM pgstac.queryables WHERE name = $1 AND %s", filter), args...)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("name", name).Msg("failed to delete queryable")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrQueryableNotFound
+	}
+	return nil
+}
+
+// ReindexQueryables asks pgstac to create the indexes requested by
+// property_index_type on every items partition
+func ReindexQueryables(ctx context.Context) error {
+	pool := database.GetInstance(ctx)
+	if _, err := pool.Exec(ctx, "SELECT pgstac.maintain_partitions()"); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to create queryable indexes")
+		return err
+	}
+	return nil
+}
+
+func scanQueryable(row pgx.Row) (*Queryable, error) {
+	var q Queryable
+	var definition *string
+	if err := row.Scan(&q.Name, &q.CollectionIDs, &definition, &q.PropertyPath, &q.PropertyWrapper, &q.PropertyIndexType); err != nil {
+		return nil, err
+	}
+	if definition != nil {
+		raw := json.RawMessage(*definition)
+		q.Definition = &raw
+	}
+	return &q, nil
+}
+
+func nullableStrings(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -57,13 +57,12 @@ type SearchResponse struct {
 	Prev     string                        `json:"prev"`
 }
 
-// Item returns details of a specific item
-func Search(params CQL) (*SearchResponse, error) {
-	ctx := context.Background()
-
+// Search runs a pgstac search. The query is traced as part of the request
+// whose context is passed.
+func Search(ctx context.Context, params CQL) (*SearchResponse, error) {
 	paramsJSON, err := json.Marshal(params)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal search parameters")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to marshal search parameters")
 		return nil, err
 	}
 
@@ -72,13 +71,13 @@ func Search(params CQL) (*SearchResponse, error) {
 
 	var searchJSON []byte
 	if err := row.Scan(&searchJSON); err != nil {
-		log.Error().Err(err).Msg("failed to scan JSON from postgresql search query")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to scan JSON from postgresql search query")
 		return nil, err
 	}
 
 	var searchResponse SearchResponse
 	if err = json.Unmarshal(searchJSON, &searchResponse); err != nil {
-		log.Error().Err(err).Msg("failed to unmarshal search JSON")
+		log.Ctx(ctx).Error().Err(err).Msg("failed to unmarshal search JSON")
 		return nil, err
 	}
 
@@ -0,0 +1,93 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	json "github.com/goccy/go-json"
+	"github.com/rs/zerolog/log"
+)
+
+// coreSortables are backed by columns of the pgstac items table and can
+// always be sorted on
+var coreSortables = map[string]string{
+	"id":           `{"title": "Item ID", "type": "string"}`,
+	"collection":   `{"title": "Collection ID", "type": "string"}`,
+	"datetime":     `{"title": "Acquired", "type": "string", "format": "date-time"}`,
+	"end_datetime": `{"title": "End datetime", "type": "string", "format": "date-time"}`,
+}
+
+// Sortables returns the fields that can be used in sortby for the given
+// collections keyed by name. A field is sortable when it is a core item
+// column or a queryable with an index. No collections means all collections.
+func Sortables(ctx context.Context, collectionIDs []string) (map[string]*json.RawMessage, error) {
+	sortables := make(map[string]*json.RawMessage, len(coreSortables))
+	for name, definition := range coreSortables {
+		raw := json.RawMessage(definition)
+		sortables[name] = &raw
+	}
+
+	query := `SELECT name, definition::text FROM pgstac.queryables
+		WHERE property_index_type IS NOT NULL`
+	args := []any{}
+	if len(collectionIDs) > 0 {
+		query += " AND (collection_ids IS NULL OR collection_ids && $1::text[])"
+		args = append(args, collectionIDs)
+	}
+
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to query sortables")
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var definition *string
+		if err := rows.Scan(&name, &definition); err != nil {
+			return nil, err
+		}
+		if _, ok := coreSortables[name]; ok || definition == nil {
+			continue
+		}
+		raw := json.RawMessage(*definition)
+		sortables[name] = &raw
+	}
+
+	return sortables, rows.Err()
+}
+
+// SortableName normalizes a sortby field so properties.eo:cloud_cover and
+// eo:cloud_cover refer to the same sortable
+func SortableName(field string) string {
+	return strings.TrimPrefix(field, "properties.")
+}
+
+// SortableNames returns the sorted names of the sortables
+func SortableNames(sortables map[string]*json.RawMessage) []string {
+	names := make([]string, 0, len(sortables))
+	for name := range sortables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,236 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrCollectionNotFound = errors.New("collection not found")
+
+// MonthCount is the number of items acquired in a month
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int64  `json:"count"`
+}
+
+// PartitionSize is the on-disk size of one items partition
+type PartitionSize struct {
+	Name       string `json:"name"`
+	Rows       int64  `json:"estimated_rows"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// CollectionStatistics describes the items stored for a collection
+type CollectionStatistics struct {
+	Collection    string          `json:"collection"`
+	ItemCount     int64           `json:"item_count"`
+	MinDatetime   *time.Time      `json:"min_datetime"`
+	MaxDatetime   *time.Time      `json:"max_datetime"`
+	SpatialExtent []float64       `json:"spatial_extent"`
+	Histogram     []MonthCount    `json:"monthly_histogram"`
+	Partitions    []PartitionSize `json:"partitions"`
+	TotalBytes    int64           `json:"total_bytes"`
+	LastUpdated   *time.Time      `json:"last_updated"`
+	ComputedAt    time.Time       `json:"computed_at"`
+}
+
+// ItemCounts is the compact form of the statistics returned by /collections?stats=true
+type ItemCounts struct {
+	ItemCount   int64      `json:"item_count"`
+	MinDatetime *time.Time `json:"min_datetime"`
+	MaxDatetime *time.Time `json:"max_datetime"`
+}
+
+type cachedStatistics struct {
+	stats   *CollectionStatistics
+	expires time.Time
+}
+
+// fresh reports whether a cached entry may still be served; entries cached
+// without a ttl are kept until they are refreshed
+func (c cachedStatistics) fresh() bool {
+	return c.expires.IsZero() || time.Now().Before(c.expires)
+}
+
+var statisticsCache sync.Map
+
+// statisticsLocks holds a mutex per collection so concurrent requests that
+// miss the cache share one computation instead of each scanning the items
+var statisticsLocks sync.Map
+
+// GetCollectionStatistics computes statistics for a collection from the pgstac
+// tables. Results are cached for ttl, or until refreshed when ttl is zero;
+// only refresh recomputes them before they expire.
+func GetCollectionStatistics(ctx context.Context, collectionID string, ttl time.Duration, refresh bool) (*CollectionStatistics, error) {
+	lock, _ := statisticsLocks.LoadOrStore(collectionID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if !refresh {
+		if cached, ok := statisticsCache.Load(collectionID); ok && cached.(cachedStatistics).fresh() {
+			return cached.(cachedStatistics).stats, nil
+		}
+	}
+
+	stats, err := computeCollectionStatistics(ctx, collectionID)
+	if err != nil {
+		if errors.Is(err, ErrCollectionNotFound) {
+			statisticsCache.Delete(collectionID)
+		}
+		return nil, err
+	}
+
+	entry := cachedStatistics{stats: stats}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	statisticsCache.Store(collectionID, entry)
+	return stats, nil
+}
+
+func computeCollectionStatistics(ctx context.Context, collectionID string) (*CollectionStatistics, error) {
+	pool := database.GetInstance(ctx)
+
+	var key int64
+	if err := pool.QueryRow(ctx, "SELECT key FROM pgstac.collections WHERE id = $1", collectionID).Scan(&key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCollectionNotFound
+		}
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to look up collection")
+		return nil, err
+	}
+
+	stats := CollectionStatistics{
+		Collection:    collectionID,
+		SpatialExtent: []float64{},
+		Histogram:     make([]MonthCount, 0),
+		Partitions:    make([]PartitionSize, 0),
+		ComputedAt:    time.Now().UTC(),
+	}
+
+	var xmin, ymin, xmax, ymax *float64
+	if err := pool.QueryRow(ctx, `SELECT count(*), min(datetime), max(end_datetime),
+			ST_XMin(ST_Extent(geometry)), ST_YMin(ST_Extent(geometry)),
+			ST_XMax(ST_Extent(geometry)), ST_YMax(ST_Extent(geometry)),
+			max(to_tstz(content->'properties'->'updated'))
+		FROM pgstac.items WHERE collection = $1`, collectionID).Scan(&stats.ItemCount, &stats.MinDatetime, &stats.MaxDatetime,
+		&xmin, &ymin, &xmax, &ymax, &stats.LastUpdated); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to compute item statistics")
+		return nil, err
+	}
+	if xmin != nil && ymin != nil && xmax != nil && ymax != nil {
+		stats.SpatialExtent = []float64{*xmin, *ymin, *xmax, *ymax}
+	}
+
+	rows, err := pool.Query(ctx, `SELECT to_char(date_trunc('month', datetime), 'YYYY-MM'), count(*)
+		FROM pgstac.items WHERE collection = $1
+		GROUP BY 1 ORDER BY 1`, collectionID)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to compute monthly histogram")
+		return nil, err
+	}
+	for rows.Next() {
+		var month MonthCount
+		if err := rows.Scan(&month.Month, &month.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		stats.Histogram = append(stats.Histogram, month)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// pgstac names partitions _items_<collection key>, with sub partitions
+	// _items_<collection key>_<period> when partition_trunc is set
+	rows, err = pool.Query(ctx, `SELECT c.relname, greatest(c.reltuples, 0)::bigint, pg_total_relation_size(c.oid)
+		FROM pg_partition_tree('pgstac.items') t
+		JOIN pg_class c ON c.oid = t.relid
+		WHERE t.isleaf AND (c.relname = '_items_' || $1 OR c.relname LIKE '\_items\_' || $1 || '\_%')
+		ORDER BY c.relname`, key)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to compute partition sizes")
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var partition PartitionSize
+		if err := rows.Scan(&partition.Name, &partition.Rows, &partition.TotalBytes); err != nil {
+			return nil, err
+		}
+		stats.TotalBytes += partition.TotalBytes
+		stats.Partitions = append(stats.Partitions, partition)
+	}
+
+	return &stats, rows.Err()
+}
+
+var itemCountsCache struct {
+	sync.Mutex
+	counts  map[string]ItemCounts
+	expires time.Time
+}
+
+// GetItemCounts returns item counts and datetime ranges for every collection
+// with items, keyed by collection id. Like the collection statistics they are
+// cached for ttl, or until refreshed when ttl is zero.
+func GetItemCounts(ctx context.Context, ttl time.Duration, refresh bool) (map[string]ItemCounts, error) {
+	itemCountsCache.Lock()
+	defer itemCountsCache.Unlock()
+	if !refresh && itemCountsCache.counts != nil && (ttl <= 0 || time.Now().Before(itemCountsCache.expires)) {
+		return itemCountsCache.counts, nil
+	}
+
+	counts, err := countItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	itemCountsCache.counts = counts
+	itemCountsCache.expires = time.Now().Add(ttl)
+	return counts, nil
+}
+
+func countItems(ctx context.Context) (map[string]ItemCounts, error) {
+	pool := database.GetInstance(ctx)
+	rows, err := pool.Query(ctx, `SELECT collection, count(*), min(datetime), max(end_datetime)
+		FROM pgstac.items GROUP BY collection`)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to count items per collection")
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]ItemCounts)
+	for rows.Next() {
+		var collectionID string
+		var count ItemCounts
+		if err := rows.Scan(&collectionID, &count.ItemCount, &count.MinDatetime, &count.MaxDatetime); err != nil {
+			return nil, err
+		}
+		counts[collectionID] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,337 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stac
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/go-geospatial/go-stac-server/database"
+	json "github.com/goccy/go-json"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// summarySkipped are properties that describe individual items rather than the collection
+var summarySkipped = map[string]bool{
+	"datetime":       true,
+	"start_datetime": true,
+	"end_datetime":   true,
+	"created":        true,
+	"updated":        true,
+	"title":          true,
+	"description":    true,
+}
+
+// ComputedSummaries are the summaries and item_assets built from a collection's items
+type ComputedSummaries struct {
+	ItemsScanned int                       `json:"items_scanned"`
+	Summaries    map[string]any            `json:"summaries"`
+	ItemAssets   map[string]map[string]any `json:"item_assets"`
+}
+
+// valueSummary accumulates the values seen for one property
+type valueSummary struct {
+	numeric  bool
+	mixed    bool
+	minimum  float64
+	maximum  float64
+	values   map[string]any
+	overflow bool
+}
+
+func (v *valueSummary) add(value any, maxValues int) {
+	switch val := value.(type) {
+	case nil, map[string]any:
+		v.mixed = true
+	case float64:
+		if !v.numeric && len(v.values) > 0 {
+			v.mixed = true
+		}
+		v.numeric = true
+		v.minimum = math.Min(v.minimum, val)
+		v.maximum = math.Max(v.maximum, val)
+	case []any:
+		// arrays such as instruments are summarized by their elements
+		for _, element := range val {
+			switch element.(type) {
+			case string, bool:
+				v.addDistinct(element, maxValues)
+			default:
+				v.mixed = true
+			}
+		}
+	case string, bool:
+		v.addDistinct(val, maxValues)
+	}
+}
+
+func (v *valueSummary) addDistinct(value any, maxValues int) {
+	if v.numeric {
+		v.mixed = true
+		return
+	}
+	key, _ := json.Marshal(value)
+	if _, ok := v.values[string(key)]; ok {
+		return
+	}
+	if len(v.values) >= maxValues {
+		v.overflow = true
+		return
+	}
+	v.values[string(key)] = value
+}
+
+func (v *valueSummary) summary() (any, bool) {
+	switch {
+	case v.mixed:
+		return nil, false
+	case v.numeric:
+		return map[string]any{"minimum": v.minimum, "maximum": v.maximum}, true
+	case v.overflow || len(v.values) == 0:
+		return nil, false
+	}
+
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]any, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, v.values[key])
+	}
+	return values, true
+}
+
+// assetSummary accumulates what is common to an asset key across items
+type assetSummary struct {
+	types  map[string]struct{}
+	titles map[string]struct{}
+	roles  map[string]struct{}
+}
+
+// ErrSummariesSampled is returned when summaries computed from a sample of
+// the items would replace the summaries of the whole collection
+var ErrSummariesSampled = errors.New("summaries computed from a sample of the items can only be previewed with a dry run")
+
+// ErrNoItemsScanned is returned when a collection without items would have its
+// summaries and item_assets replaced by empty ones
+var ErrNoItemsScanned = errors.New("the collection has no items to compute summaries from")
+
+// RecomputeSummaries computes the summaries of a collection and, unless
+// dryRun is set, replaces its summaries and item_assets with them. Summaries
+// computed from a sample, or from a collection without items, are only
+// returned with dryRun; applying them fails with ErrSummariesSampled or
+// ErrNoItemsScanned without changing the collection. With registerQueryables
+// the updated summaries are registered as queryables.
+func RecomputeSummaries(ctx context.Context, collectionID string, opts DiscoverOptions, dryRun bool, registerQueryables bool) (*ComputedSummaries, error) {
+	// refuse before scanning rather than after
+	if opts.SampleSize > 0 && !dryRun {
+		return nil, ErrSummariesSampled
+	}
+
+	computed, err := ComputeSummaries(ctx, collectionID, opts)
+	if err != nil || dryRun {
+		return computed, err
+	}
+	if computed.ItemsScanned == 0 {
+		return computed, ErrNoItemsScanned
+	}
+
+	collection, err := ApplySummaries(ctx, collectionID, computed)
+	if err != nil {
+		return computed, err
+	}
+	if registerQueryables {
+		RegisterSummaryQueryables(ctx, collectionID, collection)
+	}
+	return computed, nil
+}
+
+// RegisterSummaryQueryables registers queryables described by summaries and
+// item_assets. Names that already have a queryable, whether for the
+// collection or global, are left alone; failures are logged rather than
+// returned since they shouldn't fail the collection write
+func RegisterSummaryQueryables(ctx context.Context, id string, collection map[string]*json.RawMessage) {
+	if queryables, err := QueryablesFromSummaries(collection); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", id).Msg("could not derive queryables from collection summaries")
+	} else if _, err := RegisterQueryables(ctx, queryables); err != nil {
+		log.Ctx(ctx).Warn().Err(err).Str("id", id).Msg("could not register queryables from collection summaries")
+	}
+}
+
+// ComputeSummaries scans the items of a collection and builds summaries:
+// numeric properties become ranges and properties with at most
+// opts.MaxEnumValues distinct values become value lists. item_assets are
+// inferred from the asset keys, media types and roles. A SampleSize of 0
+// scans every item.
+func ComputeSummaries(ctx context.Context, collectionID string, opts DiscoverOptions) (*ComputedSummaries, error) {
+	pageSize := 1000
+	if opts.SampleSize > 0 && opts.SampleSize < pageSize {
+		pageSize = opts.SampleSize
+	}
+
+	params := CQL{
+		Collections: []string{collectionID},
+		Limit:       pageSize,
+		Fields:      &CQLFields{Include: []string{"properties", "assets"}},
+		FilterLang:  "cql2-json",
+	}
+
+	properties := make(map[string]*valueSummary)
+	assets := make(map[string]*assetSummary)
+	scanned := 0
+
+	for opts.SampleSize == 0 || scanned < opts.SampleSize {
+		page, err := Search(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, feature := range page.Features {
+			if feature["properties"] != nil {
+				var itemProperties map[string]any
+				if err := json.Unmarshal(*feature["properties"], &itemProperties); err != nil {
+					log.Ctx(ctx).Warn().Err(err).Str("collection", collectionID).Msg("skipping item with unreadable properties")
+					continue
+				}
+				for name, value := range itemProperties {
+					if summarySkipped[name] {
+						continue
+					}
+					if properties[name] == nil {
+						properties[name] = &valueSummary{
+							minimum: math.Inf(1),
+							maximum: math.Inf(-1),
+							values:  make(map[string]any),
+						}
+					}
+					properties[name].add(value, opts.MaxEnumValues)
+				}
+			}
+
+			if feature["assets"] != nil {
+				var itemAssets map[string]struct {
+					Type  string   `json:"type"`
+					Title string   `json:"title"`
+					Roles []string `json:"roles"`
+				}
+				if err := json.Unmarshal(*feature["assets"], &itemAssets); err == nil {
+					for key, asset := range itemAssets {
+						if assets[key] == nil {
+							assets[key] = &assetSummary{
+								types:  make(map[string]struct{}),
+								titles: make(map[string]struct{}),
+								roles:  make(map[string]struct{}),
+							}
+						}
+						assets[key].types[asset.Type] = struct{}{}
+						assets[key].titles[asset.Title] = struct{}{}
+						for _, role := range asset.Roles {
+							assets[key].roles[role] = struct{}{}
+						}
+					}
+				}
+			}
+			scanned++
+		}
+
+		if page.Next == "" || len(page.Features) == 0 {
+			break
+		}
+		params.Token = page.Next
+	}
+
+	computed := ComputedSummaries{
+		ItemsScanned: scanned,
+		Summaries:    make(map[string]any),
+		ItemAssets:   make(map[string]map[string]any),
+	}
+	for name, values := range properties {
+		if summary, ok := values.summary(); ok {
+			computed.Summaries[name] = summary
+		}
+	}
+	for key, asset := range assets {
+		itemAsset := make(map[string]any)
+		// only fields every item agrees on describe the asset key
+		if len(asset.types) == 1 {
+			for t := range asset.types {
+				if t != "" {
+					itemAsset["type"] = t
+				}
+			}
+		}
+		if len(asset.titles) == 1 {
+			for title := range asset.titles {
+				if title != "" {
+					itemAsset["title"] = title
+				}
+			}
+		}
+		if len(asset.roles) > 0 {
+			itemAsset["roles"] = sortedKeys(asset.roles)
+		}
+		computed.ItemAssets[key] = itemAsset
+	}
+
+	return &computed, nil
+}
+
+// ApplySummaries replaces the summaries and item_assets of a collection using
+// update_collection and returns the updated collection
+func ApplySummaries(ctx context.Context, collectionID string, computed *ComputedSummaries) (map[string]*json.RawMessage, error) {
+	pool := database.GetInstance(ctx)
+
+	var raw []byte
+	if err := pool.QueryRow(ctx, "SELECT content FROM pgstac.collections WHERE id = $1", collectionID).Scan(&raw); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrCollectionNotFound
+		}
+		return nil, err
+	}
+
+	collection := make(map[string]*json.RawMessage)
+	if err := json.Unmarshal(raw, &collection); err != nil {
+		return nil, err
+	}
+
+	summaries, err := json.Marshal(computed.Summaries)
+	if err != nil {
+		return nil, err
+	}
+	itemAssets, err := json.Marshal(computed.ItemAssets)
+	if err != nil {
+		return nil, err
+	}
+	summariesRaw := json.RawMessage(summaries)
+	itemAssetsRaw := json.RawMessage(itemAssets)
+	collection["summaries"] = &summariesRaw
+	collection["item_assets"] = &itemAssetsRaw
+
+	updated, err := json.Marshal(collection)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := pool.Exec(ctx, "SELECT update_collection($1::text::jsonb)", updated); err != nil {
+		log.Ctx(ctx).Error().Err(err).Str("collection", collectionID).Msg("failed to update collection summaries")
+		return nil, err
+	}
+	return collection, nil
+}
@@ -17,85 +17,81 @@ package stac
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 
+	"github.com/go-geospatial/go-stac-server/metrics"
 	json "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 )
 
-func ValidateCollectionIDsMatch(c *fiber.Ctx, obj map[string]*json.RawMessage, expected string) error {
-	if specifiedCollectionID, ok := obj["collection"]; ok {
-		var specified string
-		if err := json.Unmarshal(*specifiedCollectionID, &specified); err != nil {
-			log.Error().Err(err).Msg("invalid items json - collections parameter unmarshable")
-			c.Status(fiber.StatusBadRequest)
-			err2 := c.JSON(Message{
-				Code:        ParameterError,
-				Description: "invalid items json - collections parameter unmarshable",
-			})
-			if err2 != nil {
-				return err
-			}
-			return err
-		}
-
-		if specified != expected {
-			err := errors.New("collection parameters do not match")
-			log.Error().Str("URL-parameter", expected).Str("JSON-parameter", specified).Msg("collection parameters do not match")
-			c.Status(fiber.StatusBadRequest)
-			_ = c.JSON(Message{
-				Code:        ParameterError,
-				Description: "collection path id does not match json collection id",
-			})
-			return err
-		}
-	} else {
-		err := errors.New("invalid items json - collections parameter missing")
-		log.Error().Msg("invalid items json - collections parameter missing")
-		c.Status(fiber.StatusBadRequest)
-		_ = c.JSON(Message{
-			Code:        ParameterError,
-			Description: "invalid items json - collections parameter missing",
-		})
-		return err
+var idPattern = regexp.MustCompile(`^([a-zA-Z0-9\-_\.]+)$`)
+
+// CheckCollectionID verifies that obj has a collection field equal to expected.
+// The returned error is suitable for sending back to the client.
+func CheckCollectionID(obj map[string]*json.RawMessage, expected string) error {
+	specifiedCollectionID, ok := obj["collection"]
+	if !ok {
+		return errors.New("invalid items json - collections parameter missing")
+	}
+
+	var specified string
+	if err := json.Unmarshal(*specifiedCollectionID, &specified); err != nil {
+		return errors.New("invalid items json - collections parameter unmarshable")
+	}
+
+	if specified != expected {
+		return fmt.Errorf("collection path id '%s' does not match json collection id '%s'", expected, specified)
 	}
 
 	return nil
 }
 
-func ValidateID(c *fiber.Ctx, obj map[string]*json.RawMessage) (string, error) {
-	// validate the ID field
-	idRe := regexp.MustCompile(`^([a-zA-Z0-9\-_\.]+)$`)
+// CheckID validates the id field of obj and returns it. The returned error is
+// suitable for sending back to the client.
+func CheckID(obj map[string]*json.RawMessage) (string, error) {
 	var id string
-	if idRaw, ok := obj["id"]; ok {
-		if err := json.Unmarshal(*idRaw, &id); err != nil {
-			log.Error().Err(err).Str("id", string(*idRaw)).Msg("cannot parse id string")
-			c.Status(fiber.StatusBadRequest)
-			_ = c.JSON(Message{
-				Code:        ParameterError,
-				Description: `cannot parse id string must conform to format: '^([a-zA-Z0-9\-_\.]+)$'`,
-			})
-			return "", err
-		}
-	} else {
-		err := errors.New("id field is missing from create collection")
-		log.Error().Msg("id field is missing from create collection")
+	idRaw, ok := obj["id"]
+	if !ok {
+		return "", errors.New("id field is required")
+	}
+
+	if err := json.Unmarshal(*idRaw, &id); err != nil {
+		return "", errors.New(`cannot parse id string must conform to format: '^([a-zA-Z0-9\-_\.]+)$'`)
+	}
+
+	if !idPattern.MatchString(id) {
+		return "", errors.New(`id must conform to format '^([a-zA-Z0-9\-_\.]+)$'`)
+	}
+
+	return id, nil
+}
+
+func ValidateCollectionIDsMatch(c *fiber.Ctx, obj map[string]*json.RawMessage, expected string) error {
+	if err := CheckCollectionID(obj, expected); err != nil {
+		metrics.ValidationFailed("item_collection")
+		log.Ctx(c.UserContext()).Error().Err(err).Str("URL-parameter", expected).Msg("collection validation failed")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(Message{
 			Code:        ParameterError,
-			Description: `id field is required`,
+			Description: err.Error(),
 		})
-		return "", err
+		return err
 	}
 
-	if !idRe.MatchString(id) {
-		err := errors.New("id field contains invalid characters")
-		log.Error().Msg("id field contains invalid characters")
+	return nil
+}
+
+func ValidateID(c *fiber.Ctx, obj map[string]*json.RawMessage) (string, error) {
+	id, err := CheckID(obj)
+	if err != nil {
+		metrics.ValidationFailed("item_id")
+		log.Ctx(c.UserContext()).Error().Err(err).Msg("id validation failed")
 		c.Status(fiber.StatusBadRequest)
 		_ = c.JSON(Message{
 			Code:        ParameterError,
-			Description: `id must conform to format '^([a-zA-Z0-9\-_\.]+)$'`,
+			Description: err.Error(),
 		})
 		return "", err
 	}
@@ -16,23 +16,246 @@
 package static
 
 import (
+	"bytes"
 	"embed"
 	"net/http"
+	"strings"
+	"sync"
 
+	"github.com/go-geospatial/go-stac-server/common"
+	"github.com/go-geospatial/go-stac-server/stac"
+	json "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed files/openapi.json
 var openAPI string
 
+//go:embed files/openapi.yml
+var openAPIYML string
+
 //go:embed files/*
 var f embed.FS
 
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+	openAPIYAML     []byte
+)
+
+// loadOpenAPI prepares the OpenAPI document for the configured routes and
+// authentication methods, as JSON and as YAML
+func loadOpenAPI() {
+	openAPIOnce.Do(func() {
+		document, err := withoutWriteOperations([]byte(openAPI))
+		if err != nil {
+			log.Error().Err(err).Msg("could not remove write operations from OpenAPI document")
+			document = []byte(openAPI)
+		}
+		if openAPIDocument, err = withSecuritySchemes(document); err != nil {
+			log.Error().Err(err).Msg("could not add security schemes to OpenAPI document")
+			openAPIDocument = document
+		}
+
+		// the YAML document is only generated when the JSON one was changed
+		// so that an unfiltered document keeps its formatting
+		if bytes.Equal(openAPIDocument, []byte(openAPI)) {
+			openAPIYAML = []byte(openAPIYML)
+		} else if openAPIYAML, err = toYAML(openAPIDocument); err != nil {
+			log.Error().Err(err).Msg("could not convert OpenAPI document to YAML")
+		}
+	})
+}
+
 func OpenAPIHandler(c *fiber.Ctx) error {
+	loadOpenAPI()
+
 	c.Set("Content-Type", "application/vnd.oai.openapi+json;version=3.1")
-	return c.SendString(openAPI)
+	return c.Send(openAPIDocument)
+}
+
+// OpenAPIYAMLHandler serves the same OpenAPI document as OpenAPIHandler in
+// YAML, rather than the embedded file listing every operation
+func OpenAPIYAMLHandler(c *fiber.Ctx) error {
+	loadOpenAPI()
+
+	if openAPIYAML == nil {
+		c.Status(fiber.StatusInternalServerError)
+		return c.JSON(stac.Message{
+			Code:        stac.ServerError,
+			Description: "could not generate the OpenAPI document",
+		})
+	}
+	c.Set("Content-Type", "application/vnd.oai.openapi;version=3.1")
+	return c.Send(openAPIYAML)
+}
+
+// toYAML converts a JSON document to YAML indented like the embedded file
+func toYAML(raw []byte) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// disabledTags returns the tags of the OpenAPI operations whose routes are
+// not served
+func disabledTags() map[string]bool {
+	disabled := make(map[string]bool)
+	if !common.TransactionsEnabled() {
+		disabled["Transaction Extension"] = true
+		disabled["Ingest Jobs"] = true
+	}
+	if !common.AdminEnabled() {
+		disabled["Administration"] = true
+	}
+	return disabled
+}
+
+// withoutWriteOperations removes the operations of disabled write routes,
+// such as the transaction extension, from the OpenAPI document
+func withoutWriteOperations(raw []byte) ([]byte, error) {
+	disabled := disabledTags()
+	if len(disabled) == 0 {
+		return raw, nil
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	paths, _ := doc["paths"].(map[string]any)
+	for name, path := range paths {
+		operations, ok := path.(map[string]any)
+		if !ok {
+			continue
+		}
+		for method, operation := range operations {
+			op, ok := operation.(map[string]any)
+			if !ok {
+				continue
+			}
+			tags, _ := op["tags"].([]any)
+			for _, tag := range tags {
+				if name, _ := tag.(string); disabled[name] {
+					delete(operations, method)
+					break
+				}
+			}
+		}
+		remaining := 0
+		for _, method := range []string{"get", "put", "post", "delete", "patch", "head", "options"} {
+			if _, ok := operations[method]; ok {
+				remaining++
+			}
+		}
+		if remaining == 0 {
+			delete(paths, name)
+		}
+	}
+
+	if tags, ok := doc["tags"].([]any); ok {
+		kept := make([]any, 0, len(tags))
+		for _, tag := range tags {
+			if t, ok := tag.(map[string]any); ok {
+				if name, _ := t["name"].(string); disabled[name] {
+					continue
+				}
+			}
+			kept = append(kept, tag)
+		}
+		doc["tags"] = kept
+	}
+
+	return json.Marshal(doc)
+}
+
+// withSecuritySchemes describes the configured authentication methods in the
+// OpenAPI document so Swagger UI can authorize requests
+func withSecuritySchemes(raw []byte) ([]byte, error) {
+	if !viper.GetBool("auth.enabled") {
+		return raw, nil
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+
+	schemes := map[string]any{
+		"ApiKeyAuth": map[string]any{
+			"type": "apiKey",
+			"in":   "header",
+			"name": "X-API-Key",
+		},
+	}
+	security := []any{map[string]any{"ApiKeyAuth": []any{}}}
+	if viper.GetString("auth.jwt.jwksUrl") != "" || viper.GetString("auth.jwt.jwksFile") != "" {
+		schemes["BearerAuth"] = map[string]any{
+			"type":         "http",
+			"scheme":       "bearer",
+			"bearerFormat": "JWT",
+		}
+		security = append(security, map[string]any{"BearerAuth": []any{}})
+		if issuer := viper.GetString("auth.jwt.issuer"); issuer != "" {
+			schemes["OpenIdConnect"] = map[string]any{
+				"type":             "openIdConnect",
+				"openIdConnectUrl": strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration",
+			}
+			security = append(security, map[string]any{"OpenIdConnect": []any{}})
+		}
+	}
+
+	components, _ := doc["components"].(map[string]any)
+	if components == nil {
+		components = make(map[string]any)
+		doc["components"] = components
+	}
+	components["securitySchemes"] = schemes
+
+	// operations that already require authentication accept any configured scheme
+	paths, _ := doc["paths"].(map[string]any)
+	for _, path := range paths {
+		operations, _ := path.(map[string]any)
+		for _, operation := range operations {
+			op, ok := operation.(map[string]any)
+			if !ok {
+				continue
+			}
+			if _, secured := op["security"]; secured || viper.GetBool("auth.protectReads") {
+				op["security"] = security
+			}
+		}
+	}
+
+	// health checks and metrics stay public
+	for _, public := range []string{"/healthz", "/livez", "/readyz", "/metrics"} {
+		if operations, ok := paths[public].(map[string]any); ok {
+			for _, operation := range operations {
+				if op, ok := operation.(map[string]any); ok {
+					delete(op, "security")
+				}
+			}
+		}
+	}
+
+	return json.Marshal(doc)
 }
 
 func InitStaticFiles(app *fiber.App) {
@@ -0,0 +1,75 @@
+// Copyright 2021-2023
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package static
+
+import (
+	"reflect"
+	"testing"
+
+	json "github.com/goccy/go-json"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+func TestReadOnlyOpenAPIYAML(t *testing.T) {
+	viper.Set("server.readOnly", true)
+	defer viper.Set("server.readOnly", nil)
+
+	document, err := withoutWriteOperations([]byte(openAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := toYAML(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fromJSON, fromYAML, yamlAsJSON map[string]any
+	if err := json.Unmarshal(document, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(raw, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	// compare through JSON so integers decode to the same types
+	converted, err := json.Marshal(fromYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(converted, &yamlAsJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, yamlAsJSON) {
+		t.Error("YAML document differs from the JSON document it was generated from")
+	}
+
+	paths, _ := fromYAML["paths"].(map[string]any)
+	if _, ok := paths["/search"]; !ok {
+		t.Error("read-only YAML document lost /search")
+	}
+	for name, path := range paths {
+		operations, _ := path.(map[string]any)
+		for method, operation := range operations {
+			op, _ := operation.(map[string]any)
+			tags, _ := op["tags"].([]any)
+			for _, tag := range tags {
+				if disabledTags()[tag.(string)] {
+					t.Errorf("read-only YAML document still has %s %s tagged %s", method, name, tag)
+				}
+			}
+		}
+	}
+}